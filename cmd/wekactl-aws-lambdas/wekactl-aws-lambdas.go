@@ -7,8 +7,8 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/weka/go-cloud-lib/protocol"
 	"os"
-	"strconv"
 	"wekactl/internal/aws/lambdas"
+	"wekactl/internal/aws/lambdas/metrics"
 	"wekactl/internal/aws/lambdas/scale_down"
 	"wekactl/internal/aws/lambdas/terminate"
 	"wekactl/internal/aws/lambdas/transient"
@@ -30,16 +30,10 @@ func joinHandler(ctx context.Context) (events.APIGatewayProxyResponse, error) {
 }
 
 func fetchHandler() (protocol.HostGroupInfoResponse, error) {
-	useDynamoDBEndpoint, err := strconv.ParseBool(os.Getenv("USE_DYNAMODB_ENDPOINT"))
-	if err != nil {
-		return protocol.HostGroupInfoResponse{}, err
-	}
 	result, err := lambdas.GetFetchDataParams(
 		os.Getenv("CLUSTER_NAME"),
 		os.Getenv("ASG_NAME"),
-		os.Getenv("TABLE_NAME"),
 		os.Getenv("ROLE"),
-		useDynamoDBEndpoint,
 	)
 	if err != nil {
 		return protocol.HostGroupInfoResponse{}, err
@@ -60,6 +54,8 @@ func main() {
 		lambda.Start(terminate.Handler)
 	case "transient":
 		lambda.Start(transient.Handler)
+	case "metrics":
+		lambda.Start(metrics.Handler)
 	default:
 		lambda.Start(func() error { return errors.New("unsupported lambda command") })
 	}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"os"
 	"wekactl/internal/cli/aws"
 	"wekactl/internal/cli/cluster"
+	"wekactl/internal/cli/config"
 	"wekactl/internal/cli/debug"
 	"wekactl/internal/cli/hostgroup"
+	"wekactl/internal/cli/render"
 	"wekactl/internal/cli/version"
 	"wekactl/internal/env"
 )
@@ -21,6 +24,9 @@ var rootCmd = &cobra.Command{
 			log.Debug().Msgf("ignoring cobra error %q", err.Error())
 		}
 	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return config.Resolve(cmd)
+	},
 	SilenceUsage: true,
 }
 
@@ -35,24 +41,21 @@ func init() {
 	rootCmd.AddCommand(hostgroup.HostGroup)
 	rootCmd.AddCommand(aws.AWS)
 	rootCmd.AddCommand(debug.Debug)
+	rootCmd.AddCommand(render.Render)
 	rootCmd.AddCommand(version.Version)
+	rootCmd.AddCommand(config.Config)
 
 	rootCmd.PersistentFlags().StringVarP(&env.Config.Provider, "provider", "c", "aws", "Cloud provider")
 	rootCmd.PersistentFlags().StringVarP(&env.Config.Region, "region", "r", "", "Region")
+	rootCmd.PersistentFlags().StringVarP(&env.Config.Profile, "profile", "", "", "wekactl configuration profile")
+	rootCmd.PersistentFlags().StringVarP(&env.Config.AwsProfile, "aws-profile", "", "", "AWS shared config profile")
+	rootCmd.PersistentFlags().StringVarP(&env.Config.Output, "output", "o", env.OutputTable, fmt.Sprintf("Output format, one of: %v", env.Outputs))
+	rootCmd.PersistentFlags().StringVarP(&env.Config.LogLevel, "log-level", "", "", "Log level, LOG_LEVEL environment variable is used if not set")
 }
 
+// configureLogging sets the log writer, the level is set once flags are resolved, see config.Resolve
 func configureLogging() {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
-	logLevel := os.Getenv("LOG_LEVEL")
-	if logLevel == "" {
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	} else {
-		level, err := zerolog.ParseLevel(logLevel)
-		if err != nil {
-			log.Fatal().Err(err)
-		}
-		zerolog.SetGlobalLevel(level)
-	}
 }
 
 func main() {
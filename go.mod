@@ -17,6 +17,7 @@ require (
 	golang.org/x/sync v0.3.0
 	golang.org/x/term v0.18.0
 	gopkg.in/errgo.v2 v2.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
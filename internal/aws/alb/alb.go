@@ -1,18 +1,24 @@
 package alb
 
 import (
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/lithammer/dedent"
+	"strconv"
 	"wekactl/internal/aws/common"
 	"wekactl/internal/cluster"
 	"wekactl/internal/connectors"
+	"wekactl/internal/env"
 	strings2 "wekactl/internal/lib/strings"
 )
 
 const ListenerTypeTagKey = "wekactl.io/listener_type"
+const apiPort = 14000
+const apiProtocol = "HTTP"
+const healthCheckPath = "/api/v2/healthcheck/"
 
 func GetApplicationLoadBalancerName(clusterName cluster.ClusterName) string {
 	return strings2.ElfHashSuffixed(common.GenerateResourceName(clusterName, ""), 32)
@@ -111,11 +117,11 @@ func CreateTargetGroup(tags []*elbv2.Tag, targetName, vpcId string) (arn string,
 
 	targetOutput, err := svc.CreateTargetGroup(&elbv2.CreateTargetGroupInput{
 		Name:            aws.String(targetName),
-		Port:            aws.Int64(14000),
-		Protocol:        aws.String("HTTP"),
+		Port:            aws.Int64(apiPort),
+		Protocol:        aws.String(apiProtocol),
 		VpcId:           aws.String(vpcId),
 		Tags:            tags,
-		HealthCheckPath: aws.String("/api/v2/healthcheck/"),
+		HealthCheckPath: aws.String(healthCheckPath),
 	})
 	if err != nil {
 		return
@@ -160,8 +166,8 @@ func CreateListener(tags []*elbv2.Tag, albArn, targetArn string) error {
 			},
 		},
 		LoadBalancerArn: &albArn,
-		Port:            aws.Int64(14000),
-		Protocol:        aws.String("HTTP"),
+		Port:            aws.Int64(apiPort),
+		Protocol:        aws.String(apiProtocol),
 		Tags:            tags,
 	})
 
@@ -292,7 +298,11 @@ func GetListenerVersion(albName, requestedListenerType string) (version string,
 }
 
 func GetApplicationLoadBalancerDns(clusterName cluster.ClusterName) (dns string, err error) {
-	svc := connectors.GetAWSSession().ELBV2
+	return GetRegionApplicationLoadBalancerDns(env.Config.Region, clusterName)
+}
+
+func GetRegionApplicationLoadBalancerDns(region string, clusterName cluster.ClusterName) (dns string, err error) {
+	svc := connectors.GetRegionAWSSession(region).ELBV2
 
 	loadBalancerOutput, err := svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
 		Names: []*string{
@@ -426,3 +436,184 @@ func DeleteAlb(applicationLoadBalancer *elbv2.LoadBalancer, listener *elbv2.List
 
 	return
 }
+
+func getApiListener(albName string) (listener *elbv2.Listener, err error) {
+	svc := connectors.GetAWSSession().ELBV2
+
+	arn, err := GetApplicationLoadBalancerArn(albName)
+	if err != nil || arn == "" {
+		return
+	}
+
+	listenersOutput, err := svc.DescribeListeners(&elbv2.DescribeListenersInput{
+		LoadBalancerArn: &arn,
+	})
+	if err != nil {
+		return
+	}
+
+	var listenerType string
+	for _, albListener := range listenersOutput.Listeners {
+		listenerType, err = getResourceTagValue(*albListener.ListenerArn, ListenerTypeTagKey)
+		if err != nil {
+			return
+		}
+		if listenerType == "api" {
+			listener = albListener
+			return
+		}
+	}
+	return
+}
+
+// GetApplicationLoadBalancerDrift compares the api target group and listener with the ones CreateTargetGroup and
+// CreateListener set
+func GetApplicationLoadBalancerDrift(clusterName cluster.ClusterName) (drifts []string, err error) {
+	svc := connectors.GetAWSSession().ELBV2
+
+	targetArn, err := GetTargetGroupArn(clusterName)
+	if err != nil {
+		return
+	}
+	targetOutput, err := svc.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+		TargetGroupArns: []*string{&targetArn},
+	})
+	if err != nil {
+		return
+	}
+	targetGroup := targetOutput.TargetGroups[0]
+	if aws.Int64Value(targetGroup.Port) != apiPort || aws.StringValue(targetGroup.Protocol) != apiProtocol {
+		drifts = append(drifts, fmt.Sprintf(
+			"target group is %s:%d instead of %s:%d, it must be recreated",
+			aws.StringValue(targetGroup.Protocol), aws.Int64Value(targetGroup.Port), apiProtocol, apiPort))
+	}
+	if aws.StringValue(targetGroup.HealthCheckPath) != healthCheckPath {
+		drifts = append(drifts, fmt.Sprintf("target group health check path is %s instead of %s", aws.StringValue(targetGroup.HealthCheckPath), healthCheckPath))
+	}
+
+	listener, err := getApiListener(GetApplicationLoadBalancerName(clusterName))
+	if err != nil {
+		return
+	}
+	if listener == nil {
+		drifts = append(drifts, "api listener is missing")
+		return
+	}
+	if aws.Int64Value(listener.Port) != apiPort || aws.StringValue(listener.Protocol) != apiProtocol {
+		drifts = append(drifts, fmt.Sprintf(
+			"listener is %s:%d instead of %s:%d",
+			aws.StringValue(listener.Protocol), aws.Int64Value(listener.Port), apiProtocol, apiPort))
+	}
+	if len(listener.DefaultActions) != 1 || aws.StringValue(listener.DefaultActions[0].Type) != "forward" ||
+		aws.StringValue(listener.DefaultActions[0].TargetGroupArn) != targetArn {
+		drifts = append(drifts, "listener does not forward to the api target group")
+	}
+	return
+}
+
+// ReconcileApplicationLoadBalancer restores the target group health check and the api listener, the target group
+// port and protocol can't be modified
+func ReconcileApplicationLoadBalancer(clusterName cluster.ClusterName) error {
+	svc := connectors.GetAWSSession().ELBV2
+
+	targetArn, err := GetTargetGroupArn(clusterName)
+	if err != nil {
+		return err
+	}
+	_, err = svc.ModifyTargetGroup(&elbv2.ModifyTargetGroupInput{
+		TargetGroupArn:  &targetArn,
+		HealthCheckPath: aws.String(healthCheckPath),
+	})
+	if err != nil {
+		return err
+	}
+
+	listener, err := getApiListener(GetApplicationLoadBalancerName(clusterName))
+	if err != nil || listener == nil {
+		return err
+	}
+	_, err = svc.ModifyListener(&elbv2.ModifyListenerInput{
+		ListenerArn: listener.ListenerArn,
+		Port:        aws.Int64(apiPort),
+		Protocol:    aws.String(apiProtocol),
+		DefaultActions: []*elbv2.Action{
+			{
+				TargetGroupArn: &targetArn,
+				Type:           aws.String("forward"),
+			},
+		},
+	})
+	return err
+}
+
+// UpdateApplicationLoadBalancerTags tags the load balancer, its api target group and its api listener
+func UpdateApplicationLoadBalancerTags(clusterName cluster.ClusterName, tags []*elbv2.Tag, removedKeys []string) error {
+	svc := connectors.GetAWSSession().ELBV2
+
+	var arns []*string
+	albArn, err := GetApplicationLoadBalancerArn(GetApplicationLoadBalancerName(clusterName))
+	if err != nil {
+		return err
+	}
+	targetArn, err := GetTargetGroupArn(clusterName)
+	if err != nil {
+		return err
+	}
+	listener, err := getApiListener(GetApplicationLoadBalancerName(clusterName))
+	if err != nil {
+		return err
+	}
+	for _, arn := range []string{albArn, targetArn} {
+		if arn != "" {
+			arns = append(arns, aws.String(arn))
+		}
+	}
+	if listener != nil {
+		arns = append(arns, listener.ListenerArn)
+	}
+	if len(arns) == 0 {
+		return nil
+	}
+
+	if len(removedKeys) > 0 {
+		_, err = svc.RemoveTags(&elbv2.RemoveTagsInput{ResourceArns: arns, TagKeys: aws.StringSlice(removedKeys)})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = svc.AddTags(&elbv2.AddTagsInput{ResourceArns: arns, Tags: tags})
+	return err
+}
+
+// GetApplicationLoadBalancersQuota returns the number of application load balancers in the region and their quota
+func GetApplicationLoadBalancersQuota() (used, quota int64, err error) {
+	svc := connectors.GetAWSSession().ELBV2
+	limitsOutput, err := svc.DescribeAccountLimits(&elbv2.DescribeAccountLimitsInput{})
+	if err != nil {
+		return
+	}
+	found := false
+	for _, limit := range limitsOutput.Limits {
+		if *limit.Name == "application-load-balancers" {
+			quota, err = strconv.ParseInt(*limit.Max, 10, 64)
+			if err != nil {
+				return
+			}
+			found = true
+		}
+	}
+	if !found {
+		err = errors.New("application load balancers quota is missing from the account limits")
+		return
+	}
+
+	err = svc.DescribeLoadBalancersPages(&elbv2.DescribeLoadBalancersInput{}, func(page *elbv2.DescribeLoadBalancersOutput, lastPage bool) bool {
+		for _, loadBalancer := range page.LoadBalancers {
+			if *loadBalancer.Type == elbv2.LoadBalancerTypeEnumApplication {
+				used++
+			}
+		}
+		return true
+	})
+	return
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"strings"
 	"time"
 	"wekactl/internal/aws/common"
 	"wekactl/internal/cluster"
@@ -40,16 +41,23 @@ const policyTemplate = `{
     ]
 }`
 
+// GetRestApiPolicy returns the resource policy of the join api, private apis may only be invoked from the cluster vpc,
+// public ones have no policy
+func GetRestApiPolicy(vpcId string) string {
+	if vpcId == "" {
+		return ""
+	}
+	return fmt.Sprintf(policyTemplate, vpcId)
+}
+
 func createRestApiGateway(tags cluster.TagsRefsValues, lambdaUri string, apiGatewayName string, vpcId string) (restApiGateway RestApiGateway, err error) {
 	svc := connectors.GetAWSSession().ApiGateway
 
-	var endpointType, policy string
+	endpointType := "EDGE"
 	if vpcId != "" {
 		endpointType = "PRIVATE"
-		policy = fmt.Sprintf(policyTemplate, vpcId)
-	} else {
-		endpointType = "EDGE"
 	}
+	policy := GetRestApiPolicy(vpcId)
 
 	restApi, err := svc.CreateRestApi(&apigateway.CreateRestApiInput{
 		Name:         aws.String(apiGatewayName),
@@ -350,6 +358,21 @@ func GetRestApiGateway(resourceName string) (restApiGateway RestApiGateway, err
 	return
 }
 
+// GetRestApiGatewayPolicy returns the deployed resource policy of the api, api gateway returns it with escaped quotes
+// and with its "*" resources replaced by the api arn
+func GetRestApiGatewayPolicy(resourceName string) (policy string, err error) {
+	restApi, err := GetRestApi(resourceName)
+	if err != nil {
+		return
+	}
+	if restApi == nil {
+		err = errors.New("api gateway wasn't found")
+		return
+	}
+	policy = strings.ReplaceAll(aws.StringValue(restApi.Policy), `\"`, `"`)
+	return
+}
+
 func GetRestApiGatewayTags(resourceName string) (tags cluster.Tags, err error) {
 	restApi, err := GetRestApi(resourceName)
 	if err != nil {
@@ -387,3 +410,23 @@ func DeleteApiGateways(restApis []*apigateway.RestApi) error {
 	}
 	return nil
 }
+
+func UpdateRestApiGatewayTags(resourceName string, tags cluster.TagsRefsValues, removedKeys []string) error {
+	svc := connectors.GetAWSSession().ApiGateway
+	restApi, err := GetRestApi(resourceName)
+	if err != nil {
+		return err
+	}
+	if restApi == nil {
+		return errors.New("api gateway wasn't found")
+	}
+	arn := fmt.Sprintf("arn:aws:apigateway:%s::/restapis/%s", env.Config.Region, *restApi.Id)
+	if len(removedKeys) > 0 {
+		_, err = svc.UntagResource(&apigateway.UntagResourceInput{ResourceArn: &arn, TagKeys: aws.StringSlice(removedKeys)})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = svc.TagResource(&apigateway.TagResourceInput{ResourceArn: &arn, Tags: tags})
+	return err
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/rs/zerolog/log"
+	strings2 "strings"
 	"time"
 	"wekactl/internal/aws/common"
 	"wekactl/internal/cluster"
@@ -18,7 +19,25 @@ import (
 
 var KeepInstances bool
 
-func CreateAutoScalingGroup(tags []*autoscaling.Tag, launchTemplateName string, maxSize int64, autoScalingGroupName string) (err error) {
+// suspendedProcesses are never run by the auto scaling group, weka itself decides which instances to replace, and
+// rebalancing between availability zones would terminate cluster backends
+var suspendedProcesses = []*string{
+	aws.String("ReplaceUnhealthy"),
+	aws.String("AZRebalance"),
+}
+
+// setAutoScalingGroupTagsResource sets the auto scaling group as the tags resource, the tags propagate at launch so
+// that every instance gets the cluster tags, whichever of create, update or tags set wrote them last
+func setAutoScalingGroupTagsResource(autoScalingGroupName string, tags []*autoscaling.Tag) []*autoscaling.Tag {
+	for _, tag := range tags {
+		tag.ResourceId = &autoScalingGroupName
+		tag.ResourceType = aws.String("auto-scaling-group")
+		tag.PropagateAtLaunch = aws.Bool(true)
+	}
+	return tags
+}
+
+func CreateAutoScalingGroup(tags []*autoscaling.Tag, launchTemplateName string, maxSize int64, autoScalingGroupName string, subnets []string) (err error) {
 	svc := connectors.GetAWSSession().ASG
 	input := &autoscaling.CreateAutoScalingGroupInput{
 		AutoScalingGroupName:             &autoScalingGroupName,
@@ -27,9 +46,10 @@ func CreateAutoScalingGroup(tags []*autoscaling.Tag, launchTemplateName string,
 			LaunchTemplateName: aws.String(launchTemplateName),
 			Version:            aws.String("1"),
 		},
-		MinSize: aws.Int64(0),
-		MaxSize: aws.Int64(maxSize),
-		Tags:    tags,
+		MinSize:           aws.Int64(0),
+		MaxSize:           aws.Int64(maxSize),
+		VPCZoneIdentifier: aws.String(strings2.Join(subnets, ",")),
+		Tags:              setAutoScalingGroupTagsResource(autoScalingGroupName, tags),
 	}
 	_, err = svc.CreateAutoScalingGroup(input)
 	if err != nil {
@@ -37,38 +57,41 @@ func CreateAutoScalingGroup(tags []*autoscaling.Tag, launchTemplateName string,
 	}
 	log.Debug().Msgf("AutoScalingGroup: \"%s\" was created successfully!", autoScalingGroupName)
 
-	log.Debug().Msgf("AutoScalingGroup: \"%s\" suspending ReplaceUnhealthy...", autoScalingGroupName)
+	log.Debug().Msgf("AutoScalingGroup: \"%s\" suspending processes...", autoScalingGroupName)
 	_, err = svc.SuspendProcesses(&autoscaling.ScalingProcessQuery{
 		AutoScalingGroupName: &autoScalingGroupName,
-		ScalingProcesses: []*string{
-			aws.String("ReplaceUnhealthy"),
-		},
+		ScalingProcesses:     suspendedProcesses,
 	})
 
 	return
 }
 
-func UpdateAutoScalingGroup(launchTemplateName, autoScalingGroupName string, tags []*autoscaling.Tag) (err error) {
+func UpdateAutoScalingGroup(launchTemplateName, autoScalingGroupName string, subnets []string, tags []*autoscaling.Tag) (err error) {
 	svc := connectors.GetAWSSession().ASG
 
+	// suspending first, so that adding subnets in other availability zones doesn't rebalance existing instances
+	_, err = svc.SuspendProcesses(&autoscaling.ScalingProcessQuery{
+		AutoScalingGroupName: &autoScalingGroupName,
+		ScalingProcesses:     suspendedProcesses,
+	})
+	if err != nil {
+		return
+	}
+
 	_, err = svc.UpdateAutoScalingGroup(&autoscaling.UpdateAutoScalingGroupInput{
 		AutoScalingGroupName: &autoScalingGroupName,
 		LaunchTemplate: &autoscaling.LaunchTemplateSpecification{
 			LaunchTemplateName: aws.String(launchTemplateName),
 			Version:            aws.String("$Latest"),
 		},
+		VPCZoneIdentifier: aws.String(strings2.Join(subnets, ",")),
 	})
 	if err != nil {
 		return
 	}
 
-	for _, tag := range tags {
-		tag.ResourceId = &autoScalingGroupName
-		tag.ResourceType = aws.String("auto-scaling-group")
-		tag.PropagateAtLaunch = aws.Bool(false)
-	}
 	_, err = svc.CreateOrUpdateTags(&autoscaling.CreateOrUpdateTagsInput{
-		Tags: tags,
+		Tags: setAutoScalingGroupTagsResource(autoScalingGroupName, tags),
 	})
 	if err != nil {
 		return
@@ -275,6 +298,11 @@ func GetAsgTagValue(asg *autoscaling.Group, key string) (value string) {
 }
 
 func GetAutoScalingGroupVersion(autoScalingGroupName string) (version string, err error) {
+	return GetAutoScalingGroupTagValue(autoScalingGroupName, cluster.VersionTagKey)
+}
+
+// GetAutoScalingGroupTagValue returns empty value if the tag or the auto scaling group don't exist
+func GetAutoScalingGroupTagValue(autoScalingGroupName, key string) (value string, err error) {
 	svc := connectors.GetAWSSession().ASG
 
 	asgOutput, err := svc.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
@@ -285,7 +313,7 @@ func GetAutoScalingGroupVersion(autoScalingGroupName string) (version string, er
 	}
 
 	for _, asg := range asgOutput.AutoScalingGroups {
-		version = GetAsgTagValue(asg, cluster.VersionTagKey)
+		value = GetAsgTagValue(asg, key)
 	}
 	return
 }
@@ -328,3 +356,151 @@ func DeleteAutoScalingGroups(autoScalingGroups []*autoscaling.Group) error {
 	}
 	return nil
 }
+
+// GetAutoScalingGroupSubnets returns the subnets of the auto scaling group, empty for groups that take the subnet from
+// their launch template
+func GetAutoScalingGroupSubnets(asg *autoscaling.Group) (subnets []string) {
+	if asg.VPCZoneIdentifier == nil {
+		return
+	}
+	for _, subnet := range strings2.Split(*asg.VPCZoneIdentifier, ",") {
+		if subnet = strings2.TrimSpace(subnet); subnet != "" {
+			subnets = append(subnets, subnet)
+		}
+	}
+	return
+}
+
+func GetAutoScalingGroup(autoScalingGroupName string) (asg *autoscaling.Group, err error) {
+	svc := connectors.GetAWSSession().ASG
+	asgOutput, err := svc.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{&autoScalingGroupName},
+	})
+	if err != nil {
+		return
+	}
+	if len(asgOutput.AutoScalingGroups) == 0 {
+		err = errors.New(fmt.Sprintf("auto scaling group %s was not found", autoScalingGroupName))
+		return
+	}
+	asg = asgOutput.AutoScalingGroups[0]
+	return
+}
+
+func SetDesiredCapacity(autoScalingGroupName string, desiredCapacity int64) error {
+	svc := connectors.GetAWSSession().ASG
+	_, err := svc.SetDesiredCapacity(&autoscaling.SetDesiredCapacityInput{
+		AutoScalingGroupName: &autoScalingGroupName,
+		DesiredCapacity:      &desiredCapacity,
+	})
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("auto scaling group %s desired capacity was set to %d", autoScalingGroupName, desiredCapacity)
+	return nil
+}
+
+// GetAutoScalingGroupDrift compares the auto scaling group launch template and suspended processes with the ones
+// CreateAutoScalingGroup and UpdateAutoScalingGroup set
+func GetAutoScalingGroupDrift(launchTemplateName, autoScalingGroupName string) (drifts []string, err error) {
+	asg, err := GetAutoScalingGroup(autoScalingGroupName)
+	if err != nil {
+		return
+	}
+
+	if asg.LaunchTemplate == nil || aws.StringValue(asg.LaunchTemplate.LaunchTemplateName) != launchTemplateName {
+		drifts = append(drifts, fmt.Sprintf("launch template is not %s", launchTemplateName))
+	}
+
+	suspended := make(map[string]bool)
+	for _, process := range asg.SuspendedProcesses {
+		suspended[aws.StringValue(process.ProcessName)] = true
+	}
+	for _, process := range suspendedProcesses {
+		if !suspended[*process] {
+			drifts = append(drifts, fmt.Sprintf("process %s is not suspended", *process))
+		}
+	}
+	return
+}
+
+// GetInstanceIdsMissingTag returns the auto scaling group instances that don't have the tag key, e.g. the instances
+// attached on import, which weren't launched from the auto scaling group
+func GetInstanceIdsMissingTag(autoScalingGroupName, key string) (instanceIds []*string, err error) {
+	asgInstanceIds, err := common.GetAutoScalingGroupInstanceIds(autoScalingGroupName)
+	if err != nil || len(asgInstanceIds) == 0 {
+		return
+	}
+	instances, err := common.GetInstances(asgInstanceIds)
+	if err != nil {
+		return
+	}
+	for _, instance := range instances {
+		tagged := false
+		for _, tag := range instance.Tags {
+			if *tag.Key == key {
+				tagged = true
+				break
+			}
+		}
+		if !tagged {
+			instanceIds = append(instanceIds, instance.InstanceId)
+		}
+	}
+	return
+}
+
+// UpdateAutoScalingGroupTags sets the tags on the auto scaling group, propagated at launch, and on its running
+// instances with their volumes and network interfaces, the instances Name is left as is
+func UpdateAutoScalingGroupTags(autoScalingGroupName string, tags cluster.Tags, removedKeys []string) (err error) {
+	svc := connectors.GetAWSSession().ASG
+
+	if len(removedKeys) > 0 {
+		var deletedTags []*autoscaling.Tag
+		for _, key := range removedKeys {
+			deletedTags = append(deletedTags, &autoscaling.Tag{
+				Key:          aws.String(key),
+				ResourceId:   &autoScalingGroupName,
+				ResourceType: aws.String("auto-scaling-group"),
+			})
+		}
+		_, err = svc.DeleteTags(&autoscaling.DeleteTagsInput{Tags: deletedTags})
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = svc.CreateOrUpdateTags(&autoscaling.CreateOrUpdateTagsInput{
+		Tags: setAutoScalingGroupTagsResource(autoScalingGroupName, tags.AsAsg()),
+	})
+	if err != nil {
+		return
+	}
+
+	instanceIds, err := common.GetAutoScalingGroupInstanceIds(autoScalingGroupName)
+	if err != nil || len(instanceIds) == 0 {
+		return
+	}
+	instances, err := common.GetInstances(instanceIds)
+	if err != nil {
+		return
+	}
+	instanceTags := tags.Clone()
+	delete(instanceTags, "Name")
+	resourceIds := append(instanceIds, common.GetInstancesAttachmentIds(instances)...)
+	err = common.UpdateEc2Tags(resourceIds, instanceTags.AsEc2(), removedKeys)
+	if err != nil {
+		return
+	}
+	log.Debug().Msgf("auto scaling group %s and its %d instances were tagged", autoScalingGroupName, len(instanceIds))
+	return
+}
+
+// GetAutoScalingGroupsQuota returns the number of auto scaling groups in the region and their quota
+func GetAutoScalingGroupsQuota() (used, quota int64, err error) {
+	limitsOutput, err := connectors.GetAWSSession().ASG.DescribeAccountLimits(&autoscaling.DescribeAccountLimitsInput{})
+	if err != nil {
+		return
+	}
+	return *limitsOutput.NumberOfAutoScalingGroups, *limitsOutput.MaxNumberOfAutoScalingGroups, nil
+}
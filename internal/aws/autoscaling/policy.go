@@ -0,0 +1,223 @@
+package autoscaling
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/rs/zerolog/log"
+	"sort"
+	strings2 "strings"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/cluster"
+	"wekactl/internal/connectors"
+)
+
+// ScalingPolicyVersionTagKey holds the version of the scaling policies managed by wekactl, policies can't be tagged
+const ScalingPolicyVersionTagKey = "wekactl.io/scaling_policy_version"
+
+// ScalingMetric is either an auto scaling group EC2 metric or a weka metric published by the metrics lambda
+type ScalingMetric struct {
+	PredefinedMetricType string
+	Namespace            string
+	MetricName           string
+}
+
+// ScalingMetrics are the metrics scaling policies can be based on
+var ScalingMetrics = map[string]ScalingMetric{
+	"cpu": {
+		PredefinedMetricType: autoscaling.MetricTypeAsgaverageCpuutilization,
+		Namespace:            "AWS/EC2",
+		MetricName:           "CPUUtilization",
+	},
+	"network-in": {
+		PredefinedMetricType: autoscaling.MetricTypeAsgaverageNetworkIn,
+		Namespace:            "AWS/EC2",
+		MetricName:           "NetworkIn",
+	},
+	"network-out": {
+		PredefinedMetricType: autoscaling.MetricTypeAsgaverageNetworkOut,
+		Namespace:            "AWS/EC2",
+		MetricName:           "NetworkOut",
+	},
+	"weka-capacity-used-percent": {
+		Namespace:  common.WekaMetricsNamespace,
+		MetricName: "CapacityUsedPercent",
+	},
+	"weka-throughput": {
+		Namespace:  common.WekaMetricsNamespace,
+		MetricName: "Throughput",
+	},
+	"weka-iops": {
+		Namespace:  common.WekaMetricsNamespace,
+		MetricName: "Iops",
+	},
+}
+
+// IsWekaMetric tells whether the metric is published by the metrics lambda rather than by EC2
+func (m ScalingMetric) IsWekaMetric() bool {
+	return m.Namespace == common.WekaMetricsNamespace
+}
+
+// Dimension returns the metric dimension, auto scaling group for EC2 metrics and cluster for weka metrics
+func (m ScalingMetric) Dimension(autoScalingGroupName string, clusterName cluster.ClusterName) (name, value string) {
+	if m.IsWekaMetric() {
+		return "ClusterName", string(clusterName)
+	}
+	return "AutoScalingGroupName", autoScalingGroupName
+}
+
+func ScalingMetricNames() (names []string) {
+	for name := range ScalingMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func GetScalingMetric(name string) (metric ScalingMetric, err error) {
+	metric, ok := ScalingMetrics[name]
+	if !ok {
+		err = errors.New(fmt.Sprintf("unsupported metric %s, supported metrics: %s", name, strings2.Join(ScalingMetricNames(), ", ")))
+	}
+	return
+}
+
+// GetScalingPolicies returns the auto scaling group policies whose name starts with the given prefix
+func GetScalingPolicies(autoScalingGroupName, namePrefix string) (policies []*autoscaling.ScalingPolicy, err error) {
+	svc := connectors.GetAWSSession().ASG
+	err = svc.DescribePoliciesPages(&autoscaling.DescribePoliciesInput{
+		AutoScalingGroupName: &autoScalingGroupName,
+	}, func(page *autoscaling.DescribePoliciesOutput, lastPage bool) bool {
+		for _, policy := range page.ScalingPolicies {
+			if strings2.HasPrefix(*policy.PolicyName, namePrefix) {
+				policies = append(policies, policy)
+			}
+		}
+		return true
+	})
+	return
+}
+
+func PutTargetTrackingPolicy(autoScalingGroupName, policyName string, clusterName cluster.ClusterName, metric ScalingMetric, target float64, warmup int64) error {
+	svc := connectors.GetAWSSession().ASG
+	configuration := &autoscaling.TargetTrackingConfiguration{
+		TargetValue: &target,
+	}
+	if metric.PredefinedMetricType != "" {
+		configuration.PredefinedMetricSpecification = &autoscaling.PredefinedMetricSpecification{
+			PredefinedMetricType: aws.String(metric.PredefinedMetricType),
+		}
+	} else {
+		dimensionName, dimensionValue := metric.Dimension(autoScalingGroupName, clusterName)
+		configuration.CustomizedMetricSpecification = &autoscaling.CustomizedMetricSpecification{
+			Namespace:  aws.String(metric.Namespace),
+			MetricName: aws.String(metric.MetricName),
+			Statistic:  aws.String(autoscaling.MetricStatisticAverage),
+			Dimensions: []*autoscaling.MetricDimension{
+				{
+					Name:  &dimensionName,
+					Value: &dimensionValue,
+				},
+			},
+		}
+	}
+	_, err := svc.PutScalingPolicy(&autoscaling.PutScalingPolicyInput{
+		AutoScalingGroupName:        &autoScalingGroupName,
+		PolicyName:                  &policyName,
+		PolicyType:                  aws.String("TargetTrackingScaling"),
+		EstimatedInstanceWarmup:     &warmup,
+		TargetTrackingConfiguration: configuration,
+	})
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("target tracking policy %s was put on auto scaling group %s", policyName, autoScalingGroupName)
+	return nil
+}
+
+// PutStepPolicy puts a policy changing the capacity by adjustment whenever its alarm fires
+func PutStepPolicy(autoScalingGroupName, policyName string, adjustment, warmup int64) (arn string, err error) {
+	svc := connectors.GetAWSSession().ASG
+	step := &autoscaling.StepAdjustment{
+		ScalingAdjustment: &adjustment,
+	}
+	if adjustment > 0 {
+		step.MetricIntervalLowerBound = aws.Float64(0)
+	} else {
+		step.MetricIntervalUpperBound = aws.Float64(0)
+	}
+	output, err := svc.PutScalingPolicy(&autoscaling.PutScalingPolicyInput{
+		AutoScalingGroupName:    &autoScalingGroupName,
+		PolicyName:              &policyName,
+		PolicyType:              aws.String("StepScaling"),
+		AdjustmentType:          aws.String("ChangeInCapacity"),
+		EstimatedInstanceWarmup: &warmup,
+		StepAdjustments:         []*autoscaling.StepAdjustment{step},
+	})
+	if err != nil {
+		return
+	}
+	arn = *output.PolicyARN
+	log.Debug().Msgf("step policy %s was put on auto scaling group %s", policyName, autoScalingGroupName)
+	return
+}
+
+func DeleteScalingPolicies(policies []*autoscaling.ScalingPolicy) error {
+	svc := connectors.GetAWSSession().ASG
+	for _, policy := range policies {
+		_, err := svc.DeletePolicy(&autoscaling.DeletePolicyInput{
+			AutoScalingGroupName: policy.AutoScalingGroupName,
+			PolicyName:           policy.PolicyName,
+		})
+		if err != nil {
+			return err
+		}
+		log.Debug().Msgf("scaling policy %s was deleted", *policy.PolicyName)
+	}
+	return nil
+}
+
+func SetAutoScalingGroupSize(autoScalingGroupName string, minSize, maxSize int64) error {
+	svc := connectors.GetAWSSession().ASG
+	_, err := svc.UpdateAutoScalingGroup(&autoscaling.UpdateAutoScalingGroupInput{
+		AutoScalingGroupName: &autoScalingGroupName,
+		MinSize:              &minSize,
+		MaxSize:              &maxSize,
+	})
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("auto scaling group %s size was set to min %d max %d", autoScalingGroupName, minSize, maxSize)
+	return nil
+}
+
+func SetAutoScalingGroupTag(autoScalingGroupName, key, value string) error {
+	svc := connectors.GetAWSSession().ASG
+	_, err := svc.CreateOrUpdateTags(&autoscaling.CreateOrUpdateTagsInput{
+		Tags: []*autoscaling.Tag{
+			{
+				Key:               &key,
+				Value:             &value,
+				ResourceId:        &autoScalingGroupName,
+				ResourceType:      aws.String("auto-scaling-group"),
+				PropagateAtLaunch: aws.Bool(false),
+			},
+		},
+	})
+	return err
+}
+
+func DeleteAutoScalingGroupTag(autoScalingGroupName, key string) error {
+	svc := connectors.GetAWSSession().ASG
+	_, err := svc.DeleteTags(&autoscaling.DeleteTagsInput{
+		Tags: []*autoscaling.Tag{
+			{
+				Key:          &key,
+				ResourceId:   &autoScalingGroupName,
+				ResourceType: aws.String("auto-scaling-group"),
+			},
+		},
+	})
+	return err
+}
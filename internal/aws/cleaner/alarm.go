@@ -0,0 +1,32 @@
+package cleaner
+
+import (
+	"wekactl/internal/aws/cloudwatch"
+	"wekactl/internal/cluster"
+	"wekactl/internal/logging"
+)
+
+type MetricAlarm struct {
+	AlarmNames  []string
+	ClusterName cluster.ClusterName
+}
+
+func (m *MetricAlarm) Fetch() error {
+	alarmNames, err := cloudwatch.GetClusterMetricAlarms(m.ClusterName)
+	if err != nil {
+		return err
+	}
+	m.AlarmNames = alarmNames
+	return nil
+}
+
+func (m *MetricAlarm) Delete() error {
+	return cloudwatch.DeleteMetricAlarms(m.AlarmNames)
+}
+
+func (m *MetricAlarm) Print() {
+	logging.UserInfo("CloudWatch Alarms:")
+	for _, alarmName := range m.AlarmNames {
+		logging.UserInfo("\t- %s", alarmName)
+	}
+}
@@ -9,21 +9,32 @@ import (
 
 type IamProfile struct {
 	ClusterName cluster.ClusterName
+	RolesPath   string            // cluster settings roles path prefix, empty for the default one
+	IamRoles    map[string]string // roles supplied on import by name, they are externally owned and never deleted
 	Roles       []*iam.Role
 }
 
 func (i *IamProfile) Fetch() error {
-	roles, err := iam2.GetClusterRoles(i.ClusterName)
+	roles, err := iam2.GetClusterRoles(i.ClusterName, iam2.RolesPath(i.ClusterName, i.RolesPath))
 	if err != nil {
 		return err
 	}
-	i.Roles = roles
+	externalRoles := map[string]bool{}
+	for _, roleArn := range i.IamRoles {
+		externalRoles[roleArn] = true
+	}
+	i.Roles = nil
+	for _, role := range roles {
+		if !externalRoles[*role.Arn] {
+			i.Roles = append(i.Roles, role)
+		}
+	}
 
 	return nil
 }
 
 func (i *IamProfile) Delete() error {
-	return iam2.DeleteRoles(i.ClusterName, i.Roles)
+	return iam2.DeleteRoles(iam2.RolesPath(i.ClusterName, i.RolesPath), i.Roles)
 }
 
 func (i *IamProfile) Print() {
@@ -0,0 +1,37 @@
+package cleaner
+
+import (
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/cluster"
+	"wekactl/internal/logging"
+)
+
+type NetworkInterface struct {
+	NetworkInterfaces []*ec2.NetworkInterface
+	ClusterName       cluster.ClusterName
+}
+
+func (n *NetworkInterface) Fetch() error {
+	networkInterfaces, err := common.GetClusterNetworkInterfaces(n.ClusterName, ec2.NetworkInterfaceStatusAvailable)
+	if err != nil {
+		return err
+	}
+	n.NetworkInterfaces = networkInterfaces
+	return nil
+}
+
+func (n *NetworkInterface) Delete() error {
+	_, errs := common.DeleteNetworkInterfaces(n.NetworkInterfaces)
+	if len(errs) != 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (n *NetworkInterface) Print() {
+	logging.UserInfo("NetworkInterfaces:")
+	for _, networkInterface := range n.NetworkInterfaces {
+		logging.UserInfo("\t- %s", *networkInterface.NetworkInterfaceId)
+	}
+}
@@ -0,0 +1,35 @@
+package cleaner
+
+import (
+	"wekactl/internal/aws/common"
+	"wekactl/internal/cluster"
+	"wekactl/internal/logging"
+)
+
+type VpcEndpoint struct {
+	EndpointIds []string
+	ClusterName cluster.ClusterName
+}
+
+func (v *VpcEndpoint) Fetch() error {
+	endpoints, err := common.GetClusterVpcEndpoints(v.ClusterName)
+	if err != nil {
+		return err
+	}
+	v.EndpointIds = nil
+	for _, endpoint := range endpoints {
+		v.EndpointIds = append(v.EndpointIds, *endpoint.VpcEndpointId)
+	}
+	return nil
+}
+
+func (v *VpcEndpoint) Delete() error {
+	return common.DeleteVpcEndpoints(v.EndpointIds)
+}
+
+func (v *VpcEndpoint) Print() {
+	logging.UserInfo("VpcEndpoints:")
+	for _, endpointId := range v.EndpointIds {
+		logging.UserInfo("\t- %s", endpointId)
+	}
+}
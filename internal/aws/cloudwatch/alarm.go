@@ -0,0 +1,130 @@
+package cloudwatch
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/rs/zerolog/log"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/cluster"
+	"wekactl/internal/connectors"
+)
+
+// PutScalingAlarm puts an alarm on the average of the metric, invoking the action (a scaling policy) after two minutes
+// the threshold is crossed
+func PutScalingAlarm(tags []*cloudwatch.Tag, alarmName, namespace, metricName, dimensionName, dimensionValue, comparisonOperator string, threshold float64, actionArn string) error {
+	svc := connectors.GetAWSSession().CloudWatch
+	_, err := svc.PutMetricAlarm(&cloudwatch.PutMetricAlarmInput{
+		AlarmName:          &alarmName,
+		Namespace:          &namespace,
+		MetricName:         &metricName,
+		Statistic:          aws.String(cloudwatch.StatisticAverage),
+		Period:             aws.Int64(60),
+		EvaluationPeriods:  aws.Int64(2),
+		Threshold:          &threshold,
+		ComparisonOperator: &comparisonOperator,
+		Dimensions: []*cloudwatch.Dimension{
+			{
+				Name:  &dimensionName,
+				Value: &dimensionValue,
+			},
+		},
+		AlarmActions: []*string{&actionArn},
+		Tags:         tags,
+	})
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("cloudwatch alarm %s was put successfully!", alarmName)
+	return nil
+}
+
+// GetMetricAlarms returns the alarms whose name starts with the given prefix
+func GetMetricAlarms(namePrefix string) (alarmNames []string, err error) {
+	svc := connectors.GetAWSSession().CloudWatch
+	err = svc.DescribeAlarmsPages(&cloudwatch.DescribeAlarmsInput{
+		AlarmNamePrefix: &namePrefix,
+	}, func(page *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+		for _, alarm := range page.MetricAlarms {
+			alarmNames = append(alarmNames, *alarm.AlarmName)
+		}
+		return true
+	})
+	return
+}
+
+func DeleteMetricAlarms(alarmNames []string) error {
+	if len(alarmNames) == 0 {
+		return nil
+	}
+	svc := connectors.GetAWSSession().CloudWatch
+	_, err := svc.DeleteAlarms(&cloudwatch.DeleteAlarmsInput{
+		AlarmNames: aws.StringSlice(alarmNames),
+	})
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("cloudwatch alarms %v were deleted successfully!", alarmNames)
+	return nil
+}
+
+// GetClusterMetricAlarms returns the alarms created by wekactl for the cluster
+func GetClusterMetricAlarms(clusterName cluster.ClusterName) (alarmNames []string, err error) {
+	svc := connectors.GetAWSSession().CloudWatch
+	var alarms []*cloudwatch.MetricAlarm
+	err = svc.DescribeAlarmsPages(&cloudwatch.DescribeAlarmsInput{
+		AlarmNamePrefix: aws.String(common.GenerateResourceName(clusterName, "")),
+	}, func(page *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+		alarms = append(alarms, page.MetricAlarms...)
+		return true
+	})
+	if err != nil {
+		return
+	}
+
+	for _, alarm := range alarms {
+		var tagsOutput *cloudwatch.ListTagsForResourceOutput
+		tagsOutput, err = svc.ListTagsForResource(&cloudwatch.ListTagsForResourceInput{
+			ResourceARN: alarm.AlarmArn,
+		})
+		if err != nil {
+			return
+		}
+		for _, tag := range tagsOutput.Tags {
+			if *tag.Key == cluster.ClusterNameTagKey && *tag.Value == string(clusterName) {
+				alarmNames = append(alarmNames, *alarm.AlarmName)
+				break
+			}
+		}
+	}
+	return
+}
+
+// UpdateMetricAlarmsTags tags the alarms whose name starts with the given prefix
+func UpdateMetricAlarmsTags(namePrefix string, tags []*cloudwatch.Tag, removedKeys []string) error {
+	svc := connectors.GetAWSSession().CloudWatch
+	var alarmArns []*string
+	err := svc.DescribeAlarmsPages(&cloudwatch.DescribeAlarmsInput{
+		AlarmNamePrefix: &namePrefix,
+	}, func(page *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+		for _, alarm := range page.MetricAlarms {
+			alarmArns = append(alarmArns, alarm.AlarmArn)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, arn := range alarmArns {
+		if len(removedKeys) > 0 {
+			_, err = svc.UntagResource(&cloudwatch.UntagResourceInput{ResourceARN: arn, TagKeys: aws.StringSlice(removedKeys)})
+			if err != nil {
+				return err
+			}
+		}
+		_, err = svc.TagResource(&cloudwatch.TagResourceInput{ResourceARN: arn, Tags: tags})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
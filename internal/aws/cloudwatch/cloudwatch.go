@@ -1,6 +1,7 @@
 package cloudwatch
 
 import (
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
 	"github.com/google/uuid"
@@ -9,6 +10,9 @@ import (
 	"wekactl/internal/connectors"
 )
 
+const ruleScheduleExpression = "rate(1 minute)"
+const ruleState = "ENABLED"
+
 func PutTargets(arn *string, roleArn, ruleName string) error {
 	svc := connectors.GetAWSSession().CloudWatchEvents
 
@@ -29,8 +33,8 @@ func CreateCloudWatchEventRule(tags []*cloudwatchevents.Tag, arn *string, roleAr
 	svc := connectors.GetAWSSession().CloudWatchEvents
 	_, err := svc.PutRule(&cloudwatchevents.PutRuleInput{
 		Name:               &ruleName,
-		ScheduleExpression: aws.String("rate(1 minute)"),
-		State:              aws.String("ENABLED"),
+		ScheduleExpression: aws.String(ruleScheduleExpression),
+		State:              aws.String(ruleState),
 		Tags:               tags,
 	})
 	if err != nil {
@@ -47,6 +51,54 @@ func CreateCloudWatchEventRule(tags []*cloudwatchevents.Tag, arn *string, roleAr
 	return nil
 }
 
+// CreateLambdaEventRule creates a rule invoking the lambda every minute, the lambda must allow the rule to invoke it
+func CreateLambdaEventRule(tags []*cloudwatchevents.Tag, lambdaArn, ruleName string) (ruleArn string, err error) {
+	svc := connectors.GetAWSSession().CloudWatchEvents
+	ruleOutput, err := svc.PutRule(&cloudwatchevents.PutRuleInput{
+		Name:               &ruleName,
+		ScheduleExpression: aws.String(ruleScheduleExpression),
+		State:              aws.String(ruleState),
+		Tags:               tags,
+	})
+	if err != nil {
+		return
+	}
+	ruleArn = *ruleOutput.RuleArn
+	log.Debug().Msgf("cloudwatch rule %s was created successfully!", ruleName)
+
+	err = PutLambdaTarget(lambdaArn, ruleName)
+	if err != nil {
+		return
+	}
+	log.Debug().Msgf("cloudwatch lambda target was set successfully!")
+	return
+}
+
+func PutLambdaTarget(lambdaArn, ruleName string) error {
+	svc := connectors.GetAWSSession().CloudWatchEvents
+	_, err := svc.PutTargets(&cloudwatchevents.PutTargetsInput{
+		Rule: &ruleName,
+		Targets: []*cloudwatchevents.Target{
+			{
+				Arn: &lambdaArn,
+				// fixed id, so that putting the target again replaces it
+				Id: aws.String("lambda"),
+			},
+		},
+	})
+	return err
+}
+
+func GetCloudWatchEventRuleArn(ruleName string) (arn string, err error) {
+	svc := connectors.GetAWSSession().CloudWatchEvents
+	ruleOutput, err := svc.DescribeRule(&cloudwatchevents.DescribeRuleInput{Name: &ruleName})
+	if err != nil {
+		return
+	}
+	arn = *ruleOutput.Arn
+	return
+}
+
 func DeleteCloudWatchEventRule(ruleName string) error {
 	svc := connectors.GetAWSSession().CloudWatchEvents
 
@@ -157,3 +209,83 @@ func GetCloudWatchEventRuleRoleArn(ruleName string) (arn string, err error) {
 	arn = *targetsOutput.Targets[0].RoleArn
 	return
 }
+
+// GetCloudWatchEventRuleDrift compares the rule schedule and targets with the ones CreateCloudWatchEventRule sets
+func GetCloudWatchEventRuleDrift(arn, roleArn, ruleName string) (drifts []string, err error) {
+	svc := connectors.GetAWSSession().CloudWatchEvents
+
+	ruleOutput, err := svc.DescribeRule(&cloudwatchevents.DescribeRuleInput{Name: &ruleName})
+	if err != nil {
+		return
+	}
+	if aws.StringValue(ruleOutput.ScheduleExpression) != ruleScheduleExpression {
+		drifts = append(drifts, fmt.Sprintf("schedule is %s instead of %s", aws.StringValue(ruleOutput.ScheduleExpression), ruleScheduleExpression))
+	}
+	if aws.StringValue(ruleOutput.State) != ruleState {
+		drifts = append(drifts, fmt.Sprintf("state is %s instead of %s", aws.StringValue(ruleOutput.State), ruleState))
+	}
+
+	targetsOutput, err := svc.ListTargetsByRule(&cloudwatchevents.ListTargetsByRuleInput{Rule: &ruleName})
+	if err != nil {
+		return
+	}
+	if len(targetsOutput.Targets) != 1 {
+		drifts = append(drifts, fmt.Sprintf("rule has %d targets instead of 1", len(targetsOutput.Targets)))
+	}
+	for _, target := range targetsOutput.Targets {
+		if aws.StringValue(target.Arn) != arn {
+			drifts = append(drifts, fmt.Sprintf("unexpected target %s", aws.StringValue(target.Arn)))
+		} else if aws.StringValue(target.RoleArn) != roleArn {
+			drifts = append(drifts, fmt.Sprintf("target role is %s instead of %s", aws.StringValue(target.RoleArn), roleArn))
+		}
+	}
+	return
+}
+
+// ReconcileCloudWatchEventRule restores the rule schedule and replaces all its targets by the state machine one
+func ReconcileCloudWatchEventRule(arn *string, roleArn, ruleName string) error {
+	svc := connectors.GetAWSSession().CloudWatchEvents
+
+	_, err := svc.PutRule(&cloudwatchevents.PutRuleInput{
+		Name:               &ruleName,
+		ScheduleExpression: aws.String(ruleScheduleExpression),
+		State:              aws.String(ruleState),
+	})
+	if err != nil {
+		return err
+	}
+
+	targetsOutput, err := svc.ListTargetsByRule(&cloudwatchevents.ListTargetsByRuleInput{Rule: &ruleName})
+	if err != nil {
+		return err
+	}
+	var targetIds []*string
+	for _, target := range targetsOutput.Targets {
+		targetIds = append(targetIds, target.Id)
+	}
+	if len(targetIds) > 0 {
+		_, err = svc.RemoveTargets(&cloudwatchevents.RemoveTargetsInput{Rule: &ruleName, Ids: targetIds})
+		if err != nil {
+			return err
+		}
+	}
+	log.Debug().Msgf("cloudwatch rule %s targets were removed", ruleName)
+
+	return PutTargets(arn, roleArn, ruleName)
+}
+
+func UpdateCloudWatchEventRuleTags(ruleName string, tags []*cloudwatchevents.Tag, removedKeys []string) error {
+	svc := connectors.GetAWSSession().CloudWatchEvents
+	arn, err := GetCloudWatchEventRuleArn(ruleName)
+	if err != nil {
+		return err
+	}
+	if len(removedKeys) > 0 {
+		_, err = svc.UntagResource(&cloudwatchevents.UntagResourceInput{ResourceARN: &arn, TagKeys: aws.StringSlice(removedKeys)})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = svc.TagResource(&cloudwatchevents.TagResourceInput{ResourceARN: &arn, Tags: tags})
+	return err
+}
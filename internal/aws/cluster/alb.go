@@ -74,6 +74,14 @@ func (a *ApplicationLoadBalancer) Fetch() error {
 	return nil
 }
 
+func (a *ApplicationLoadBalancer) Verify() ([]string, error) {
+	return alb.GetApplicationLoadBalancerDrift(a.ClusterName)
+}
+
+func (a *ApplicationLoadBalancer) Reconcile(tags cluster.Tags) error {
+	return alb.ReconcileApplicationLoadBalancer(a.ClusterName)
+}
+
 func (a *ApplicationLoadBalancer) Init() {
 	log.Debug().Msgf("Initializing cluster %s ALB ...", string(a.ClusterName))
 	return
@@ -94,6 +102,10 @@ func (a *ApplicationLoadBalancer) TargetVersion() string {
 	return albVersion
 }
 
+func (a *ApplicationLoadBalancer) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return alb.UpdateApplicationLoadBalancerTags(a.ClusterName, tags.AsAlb(), removedKeys)
+}
+
 func (a *ApplicationLoadBalancer) Create(tags cluster.Tags) (err error) {
 	//TODO: consider separating into 3 different resources
 
@@ -35,9 +35,10 @@ func (a *ApiGateway) Init() {
 	log.Debug().Msgf("Initializing hostgroup %s api gateway ...", string(a.HostGroupInfo.Name))
 	a.Backend.TableName = a.TableName
 	a.Backend.HostGroupInfo = a.HostGroupInfo
-	a.Backend.Permissions = iam.GetJoinAndFetchLambdaPolicy()
 	a.Backend.Type = lambdas.LambdaJoin
+	a.Backend.Permissions = iam.GetJoinAndFetchLambdaPolicy(policyScope(a.HostGroupInfo, a.TableName, a.ASGName), a.Backend.ResourceName())
 	a.Backend.ASGName = a.ASGName
+	a.Backend.ClusterSettings = a.ClusterSettings
 	a.Backend.Init()
 }
 
@@ -74,6 +75,10 @@ func (a *ApiGateway) TargetVersion() string {
 	return joinApiVersion
 }
 
+func (a *ApiGateway) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return apigateway.UpdateRestApiGatewayTags(a.ResourceName(), tags.AsStringRefs(), removedKeys)
+}
+
 func (a *ApiGateway) Create(tags cluster.Tags) error {
 	vpcId := ""
 	if a.ClusterSettings.PrivateSubnet {
@@ -0,0 +1,125 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"wekactl/internal/aws/autoscaling"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/cluster"
+	strings2 "wekactl/internal/lib/strings"
+)
+
+type AutoscaleParams struct {
+	Name          cluster.ClusterName
+	HostGroupName common.HostGroupName
+	PolicyType    string
+	Metric        string
+	Target        float64
+	Min           int64
+	Max           int64
+	Cooldown      time.Duration
+	// ScaleInTarget applies to step policies only, half of Target when 0
+	ScaleInTarget float64
+}
+
+func (p AutoscaleParams) settings() db.HostGroupAutoscaling {
+	return db.HostGroupAutoscaling{
+		PolicyType:    p.PolicyType,
+		Metric:        p.Metric,
+		Target:        p.Target,
+		Min:           p.Min,
+		Max:           p.Max,
+		Cooldown:      int64(p.Cooldown.Seconds()),
+		ScaleInTarget: p.ScaleInTarget,
+	}
+}
+
+func validateAutoscaleParams(hostGroup HostGroup, params AutoscaleParams) error {
+	if !strings2.AnyOf(params.PolicyType, PolicyTypeTargetTracking, PolicyTypeStep) {
+		return errors.New(fmt.Sprintf("unsupported policy type %s, supported types: %s, %s", params.PolicyType, PolicyTypeTargetTracking, PolicyTypeStep))
+	}
+	if _, err := autoscaling.GetScalingMetric(params.Metric); err != nil {
+		return err
+	}
+	if params.Target <= 0 {
+		return errors.New("target must be positive")
+	}
+	if params.ScaleInTarget != 0 && params.PolicyType != PolicyTypeStep {
+		return errors.New(fmt.Sprintf("scale in target applies only to %s policies", PolicyTypeStep))
+	}
+	if params.ScaleInTarget < 0 || params.ScaleInTarget >= params.Target {
+		return errors.New(fmt.Sprintf("scale in target %g can't be negative and must be below the target %g", params.ScaleInTarget, params.Target))
+	}
+	if params.Cooldown < 0 {
+		return errors.New("cooldown can't be negative")
+	}
+	if params.Min < 0 || params.Max < params.Min {
+		return errors.New(fmt.Sprintf("invalid size range min %d max %d", params.Min, params.Max))
+	}
+	if hostGroup.HostGroupInfo.Role == common.RoleBackend && params.Min < common.MinBackendsNumber {
+		return errors.New(fmt.Sprintf("min size %d is below the minimum of %d backends", params.Min, common.MinBackendsNumber))
+	}
+	return nil
+}
+
+// ensureHostGroupScaling reconciles the hostgroup scaling policies with the given settings, then deploys or removes the
+// metrics publisher depending on whether they are based on a weka metric
+func ensureHostGroupScaling(awsCluster AWSCluster, hostGroup HostGroup, settings db.HostGroupAutoscaling) error {
+	hostGroup.Autoscaling = settings
+	hostGroup.Init()
+	err := cluster.EnsureResource(&hostGroup.AutoscalingGroup.ScalingPolicy, awsCluster.ClusterSettings, false)
+	if err != nil {
+		return err
+	}
+	if hostGroup.HostGroupInfo.Role != common.RoleBackend {
+		return nil
+	}
+	return cluster.EnsureResource(&hostGroup.AutoscalingGroup.MetricsPublisher, awsCluster.ClusterSettings, false)
+}
+
+// SetHostGroupAutoscaling saves the hostgroup scaling policy settings and reconciles the auto scaling group policies,
+// cluster update keeps reconciling them from the saved settings
+func SetHostGroupAutoscaling(params AutoscaleParams) error {
+	awsCluster, err := GetCluster(params.Name, true)
+	if err != nil {
+		return err
+	}
+	hostGroup, err := getHostGroup(awsCluster, params.HostGroupName)
+	if err != nil {
+		return err
+	}
+	err = validateAutoscaleParams(hostGroup, params)
+	if err != nil {
+		return err
+	}
+	// the metrics publisher is deployed from the generated configuration of this binary
+	err = checkDowngrade(awsCluster.ClusterSettings, false)
+	if err != nil {
+		return err
+	}
+
+	err = db.SaveHostGroupAutoscaling(db.GetTableName(params.Name), string(params.HostGroupName), params.settings())
+	if err != nil {
+		return err
+	}
+	return ensureHostGroupScaling(awsCluster, hostGroup, params.settings())
+}
+
+// UnsetHostGroupAutoscaling removes the wekactl managed scaling policies of the hostgroup, its max size is kept
+func UnsetHostGroupAutoscaling(name cluster.ClusterName, hostGroupName common.HostGroupName) error {
+	awsCluster, err := GetCluster(name, true)
+	if err != nil {
+		return err
+	}
+	hostGroup, err := getHostGroup(awsCluster, hostGroupName)
+	if err != nil {
+		return err
+	}
+	err = db.DeleteHostGroupAutoscaling(db.GetTableName(name), string(hostGroupName))
+	if err != nil {
+		return err
+	}
+	return ensureHostGroupScaling(awsCluster, hostGroup, db.HostGroupAutoscaling{})
+}
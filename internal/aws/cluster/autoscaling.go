@@ -9,16 +9,19 @@ import (
 	"wekactl/internal/cluster"
 )
 
-const autoscalingVersion = "v2"
+const autoscalingVersion = "v3"
 
 type AutoscalingGroup struct {
 	HostGroupInfo          common.HostGroupInfo
 	HostGroupParams        common.HostGroupParams
 	LaunchTemplate         LaunchTemplate
 	ScaleMachineCloudWatch CloudWatch
+	ScalingPolicy          ScalingPolicy
+	MetricsPublisher       MetricsPublisher
 	TableName              string
 	Version                string
 	ClusterSettings        db.ClusterSettings
+	Autoscaling            db.HostGroupAutoscaling
 }
 
 func (a *AutoscalingGroup) Tags() cluster.Tags {
@@ -27,7 +30,11 @@ func (a *AutoscalingGroup) Tags() cluster.Tags {
 }
 
 func (a *AutoscalingGroup) SubResources() []cluster.Resource {
-	return []cluster.Resource{&a.LaunchTemplate, &a.ScaleMachineCloudWatch}
+	resources := []cluster.Resource{&a.LaunchTemplate, &a.ScaleMachineCloudWatch}
+	if a.HostGroupInfo.Role == common.RoleBackend {
+		resources = append(resources, &a.MetricsPublisher)
+	}
+	return append(resources, &a.ScalingPolicy)
 }
 
 func (a *AutoscalingGroup) ResourceName() string {
@@ -51,14 +58,39 @@ func (a *AutoscalingGroup) TargetVersion() string {
 	return autoscalingVersion
 }
 
+func (a *AutoscalingGroup) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return autoscaling.UpdateAutoScalingGroupTags(a.ResourceName(), tags, removedKeys)
+}
+
 func (a *AutoscalingGroup) Create(tags cluster.Tags) error {
 	return autoscaling.CreateAutoScalingGroup(
-		tags.AsAsg(), a.LaunchTemplate.ResourceName(), a.HostGroupParams.MaxSize, a.ResourceName())
+		tags.AsAsg(), a.LaunchTemplate.ResourceName(), a.HostGroupParams.MaxSize, a.ResourceName(), a.HostGroupParams.GetSubnets())
 }
 
 func (a *AutoscalingGroup) Update(tags cluster.Tags) error {
 	return autoscaling.UpdateAutoScalingGroup(
-		a.LaunchTemplate.ResourceName(), a.ResourceName(), tags.AsAsg())
+		a.LaunchTemplate.ResourceName(), a.ResourceName(), a.HostGroupParams.GetSubnets(), tags.AsAsg())
+}
+
+func (a *AutoscalingGroup) Verify() ([]string, error) {
+	return autoscaling.GetAutoScalingGroupDrift(a.LaunchTemplate.ResourceName(), a.ResourceName())
+}
+
+// Reconcile goes through the regular update, which suspends the processes again and points back to the launch template
+func (a *AutoscalingGroup) Reconcile(tags cluster.Tags) error {
+	return a.Update(tags)
+}
+
+// untaggedInstanceIds returns the hostgroup instances missing the cluster name tag, the terminate lambda role may only
+// terminate tagged instances
+func (a *AutoscalingGroup) untaggedInstanceIds() ([]*string, error) {
+	return autoscaling.GetInstanceIdsMissingTag(a.ResourceName(), cluster.ClusterNameTagKey)
+}
+
+// tagInstances sets the cluster name tag on the given instances, other tags are left as is
+func (a *AutoscalingGroup) tagInstances(instanceIds []*string) error {
+	tags := cluster.Tags{cluster.ClusterNameTagKey: string(a.HostGroupInfo.ClusterName)}
+	return common.UpdateEc2Tags(instanceIds, tags.AsEc2(), nil)
 }
 
 func (a *AutoscalingGroup) Init() {
@@ -73,6 +105,16 @@ func (a *AutoscalingGroup) Init() {
 	a.ScaleMachineCloudWatch.HostGroupParams = a.HostGroupParams
 	a.ScaleMachineCloudWatch.TableName = a.TableName
 	a.ScaleMachineCloudWatch.ASGName = a.ResourceName()
-	a.ScaleMachineCloudWatch.UsedDynamoDBEndpoint = a.ClusterSettings.UseDynamoDBEndpoint
+	a.ScaleMachineCloudWatch.ClusterSettings = a.ClusterSettings
 	a.ScaleMachineCloudWatch.Init()
+	a.MetricsPublisher.HostGroupInfo = a.HostGroupInfo
+	a.MetricsPublisher.HostGroupParams = a.HostGroupParams
+	a.MetricsPublisher.TableName = a.TableName
+	a.MetricsPublisher.ASGName = a.ResourceName()
+	a.MetricsPublisher.ClusterSettings = a.ClusterSettings
+	a.MetricsPublisher.Autoscaling = a.Autoscaling
+	a.MetricsPublisher.Init()
+	a.ScalingPolicy.HostGroupInfo = a.HostGroupInfo
+	a.ScalingPolicy.ASGName = a.ResourceName()
+	a.ScalingPolicy.Init()
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"wekactl/internal/aws/cloudwatch"
 	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
 	"wekactl/internal/aws/iam"
 	"wekactl/internal/aws/scalemachine"
 	"wekactl/internal/cluster"
@@ -13,14 +14,14 @@ import (
 const cloudwatchVersion = "v1"
 
 type CloudWatch struct {
-	HostGroupInfo        common.HostGroupInfo
-	HostGroupParams      common.HostGroupParams
-	ScaleMachine         ScaleMachine
-	Profile              IamProfile
-	TableName            string
-	Version              string
-	ASGName              string
-	UsedDynamoDBEndpoint bool
+	HostGroupInfo   common.HostGroupInfo
+	HostGroupParams common.HostGroupParams
+	ScaleMachine    ScaleMachine
+	Profile         IamProfile
+	TableName       string
+	Version         string
+	ASGName         string
+	ClusterSettings db.ClusterSettings
 }
 
 func (c *CloudWatch) Tags() cluster.Tags {
@@ -51,7 +52,7 @@ func (c *CloudWatch) Fetch() error {
 	}
 
 	if c.Profile.Arn == "" {
-		profileArn, err := iam.GetIamRoleArn(c.HostGroupInfo.ClusterName, c.Profile.resourceNameBase())
+		profileArn, err := iam.GetIamRoleArn(c.Profile.rolesPath(), c.Profile.resourceNameBase())
 		if err != nil {
 			return err
 		}
@@ -79,6 +80,10 @@ func (c *CloudWatch) TargetVersion() string {
 	return cloudwatchVersion
 }
 
+func (c *CloudWatch) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return cloudwatch.UpdateCloudWatchEventRuleTags(c.ResourceName(), tags.AsCloudWatch(), removedKeys)
+}
+
 func (c *CloudWatch) Create(tags cluster.Tags) (err error) {
 	return cloudwatch.CreateCloudWatchEventRule(tags.AsCloudWatch(), &c.ScaleMachine.Arn, c.Profile.Arn, c.ResourceName())
 }
@@ -87,6 +92,14 @@ func (c *CloudWatch) Update(tags cluster.Tags) error {
 	return cloudwatch.PutTargets(&c.ScaleMachine.Arn, c.Profile.Arn, c.ResourceName())
 }
 
+func (c *CloudWatch) Verify() ([]string, error) {
+	return cloudwatch.GetCloudWatchEventRuleDrift(c.ScaleMachine.Arn, c.Profile.Arn, c.ResourceName())
+}
+
+func (c *CloudWatch) Reconcile(tags cluster.Tags) error {
+	return cloudwatch.ReconcileCloudWatchEventRule(&c.ScaleMachine.Arn, c.Profile.Arn, c.ResourceName())
+}
+
 func (c *CloudWatch) Init() {
 	log.Debug().Msgf("Initializing hostgroup %s cloudwatch ...", string(c.HostGroupInfo.Name))
 	c.Profile.Name = "cw"
@@ -94,13 +107,14 @@ func (c *CloudWatch) Init() {
 	c.Profile.TableName = c.TableName
 	c.Profile.AssumeRolePolicy = iam.GetCloudWatchEventAssumeRolePolicy()
 	c.Profile.HostGroupInfo = c.HostGroupInfo
-	c.Profile.Policy = iam.GetCloudWatchEventRolePolicy()
+	c.Profile.ClusterSettings = c.ClusterSettings
+	c.Profile.Policy = iam.GetCloudWatchEventRolePolicy(policyScope(c.HostGroupInfo, c.TableName, c.ASGName), c.ScaleMachine.ResourceName())
 	c.Profile.Init()
 
 	c.ScaleMachine.TableName = c.TableName
 	c.ScaleMachine.HostGroupInfo = c.HostGroupInfo
 	c.ScaleMachine.HostGroupParams = c.HostGroupParams
 	c.ScaleMachine.ASGName = c.ASGName
-	c.ScaleMachine.UseDynamoDBEndpoint = c.UsedDynamoDBEndpoint
+	c.ScaleMachine.ClusterSettings = c.ClusterSettings
 	c.ScaleMachine.Init()
 }
@@ -5,14 +5,16 @@ import (
 	"wekactl/internal/aws/common"
 	"wekactl/internal/aws/db"
 	"wekactl/internal/cluster"
+	"wekactl/internal/lib/strings"
 )
 
 type AWSCluster struct {
-	Name            cluster.ClusterName
-	ClusterSettings db.ClusterSettings
-	HostGroups      []HostGroup
-	TableName       string
-	ALB             ApplicationLoadBalancer
+	Name             cluster.ClusterName
+	ClusterSettings  db.ClusterSettings
+	HostGroups       []HostGroup
+	TableName        string
+	ALB              ApplicationLoadBalancer
+	DynamoDBEndpoint DynamoDBEndpoint
 }
 
 func (c *AWSCluster) Tags() cluster.Tags {
@@ -20,9 +22,12 @@ func (c *AWSCluster) Tags() cluster.Tags {
 }
 
 func (c *AWSCluster) SubResources() []cluster.Resource {
-	resources := []cluster.Resource{
-		&c.ALB,
+	var resources []cluster.Resource
+	// when UseDynamoDBEndpoint is set the vpc is expected to already route DynamoDB traffic of the scale lambdas
+	if !c.ClusterSettings.UseDynamoDBEndpoint {
+		resources = append(resources, &c.DynamoDBEndpoint)
 	}
+	resources = append(resources, &c.ALB)
 	for i := range c.HostGroups {
 		resources = append(resources, &c.HostGroups[i])
 	}
@@ -51,6 +56,17 @@ func (c *AWSCluster) Init() {
 		c.HostGroups[i].Init()
 	}
 
+	c.DynamoDBEndpoint.ClusterName = c.Name
+	c.DynamoDBEndpoint.VpcId = c.ClusterSettings.VpcId
+	c.DynamoDBEndpoint.Subnets = nil
+	for _, hostGroup := range c.HostGroups {
+		// scale lambda runs in the hostgroup subnet
+		if subnet := hostGroup.HostGroupParams.Subnet; subnet != "" && !strings.AnyOf(subnet, c.DynamoDBEndpoint.Subnets...) {
+			c.DynamoDBEndpoint.Subnets = append(c.DynamoDBEndpoint.Subnets, subnet)
+		}
+	}
+	c.DynamoDBEndpoint.Init()
+
 	c.ALB.ClusterName = c.Name
 	c.ALB.VpcSubnets = []string{c.ClusterSettings.Subnet, c.ClusterSettings.AdditionalSubnet}
 	c.ALB.VpcId = c.ClusterSettings.VpcId
@@ -68,6 +84,10 @@ func (c *AWSCluster) TargetVersion() string {
 	return ""
 }
 
+func (c *AWSCluster) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return nil
+}
+
 func (c *AWSCluster) Create(tags cluster.Tags) (err error) {
 	return nil
 }
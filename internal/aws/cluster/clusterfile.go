@@ -0,0 +1,488 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sort"
+	"time"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/lib/strings"
+	"wekactl/internal/logging"
+)
+
+// ClusterFileVersion is the schema version of the cluster file, bumped on incompatible changes
+const ClusterFileVersion = "v1"
+
+const clusterFileHeader = `# wekactl cluster file, written by "wekactl cluster export" and applied by "wekactl cluster apply".
+# network and hostgroups instance params are read-only, except for hostgroups subnets which can be added.
+# Instance type and image are changed by "wekactl hostgroup roll".
+`
+
+// ClusterFile is the declarative representation of the cluster settings kept in DB
+type ClusterFile struct {
+	// Version of this schema
+	Version string `yaml:"version"`
+	Name    string `yaml:"name"`
+	Region  string `yaml:"region"`
+	// Network is read-only, the cluster can't be moved to another vpc
+	Network       ClusterFileNetwork       `yaml:"network"`
+	Dns           ClusterFileDns           `yaml:"dns,omitempty"`
+	FailureDomain ClusterFileFailureDomain `yaml:"failureDomain,omitempty"`
+	// Tags are added to every cluster resource
+	Tags       map[string]string               `yaml:"tags,omitempty"`
+	HostGroups map[string]ClusterFileHostGroup `yaml:"hostgroups"`
+}
+
+type ClusterFileNetwork struct {
+	VpcId               string `yaml:"vpcId"`
+	Subnet              string `yaml:"subnet"`
+	AdditionalSubnet    string `yaml:"additionalSubnet,omitempty"`
+	PrivateSubnet       bool   `yaml:"privateSubnet"`
+	UseDynamoDBEndpoint bool   `yaml:"useDynamoDBEndpoint"`
+}
+
+// ClusterFileDns is the alias record of the cluster load balancer, an alias is never removed
+type ClusterFileDns struct {
+	Alias  string `yaml:"alias,omitempty"`
+	ZoneId string `yaml:"zoneId,omitempty"`
+}
+
+type ClusterFileFailureDomain struct {
+	// Strategy is one of hashed-ip, az, placement-partition, custom
+	Strategy string `yaml:"strategy,omitempty"`
+	Command  string `yaml:"command,omitempty"`
+}
+
+type ClusterFileVolume struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	Size int64  `yaml:"size"`
+}
+
+type ClusterFileHostGroup struct {
+	// Role is backend or client
+	Role              string              `yaml:"role"`
+	InstanceType      string              `yaml:"instanceType"`
+	ImageId           string              `yaml:"imageId"`
+	KeyName           string              `yaml:"keyName,omitempty"`
+	IamArn            string              `yaml:"iamArn"`
+	SecurityGroupsIds []string            `yaml:"securityGroupsIds"`
+	Subnets           []string            `yaml:"subnets"`
+	Volumes           []ClusterFileVolume `yaml:"volumes"`
+	MaxSize           int64               `yaml:"maxSize"`
+	HttpTokens        string              `yaml:"httpTokens"`
+	// Autoscaling is the wekactl managed scaling policy, the policy is removed when omitted
+	Autoscaling *ClusterFileAutoscaling `yaml:"autoscaling,omitempty"`
+}
+
+type ClusterFileAutoscaling struct {
+	// PolicyType is target-tracking or step
+	PolicyType string  `yaml:"policyType"`
+	Metric     string  `yaml:"metric"`
+	Target     float64 `yaml:"target"`
+	Min        int64   `yaml:"min"`
+	Max        int64   `yaml:"max"`
+	// Cooldown is a duration, e.g. 5m
+	Cooldown string `yaml:"cooldown"`
+	// ScaleInTarget is the step policy scale in threshold, half of Target when omitted
+	ScaleInTarget float64 `yaml:"scaleInTarget,omitempty"`
+}
+
+func hostGroupToClusterFile(hostGroup HostGroup, autoscaling db.HostGroupAutoscaling) ClusterFileHostGroup {
+	params := hostGroup.HostGroupParams
+	fileHostGroup := ClusterFileHostGroup{
+		Role:              string(hostGroup.HostGroupInfo.Role),
+		InstanceType:      params.InstanceType,
+		ImageId:           params.ImageID,
+		KeyName:           params.KeyName,
+		IamArn:            params.IamArn,
+		SecurityGroupsIds: strings.RefListToList(params.SecurityGroupsIds),
+		Subnets:           params.GetSubnets(),
+		MaxSize:           params.MaxSize,
+		HttpTokens:        params.HttpTokens,
+	}
+	for _, volume := range params.VolumesInfo {
+		fileHostGroup.Volumes = append(fileHostGroup.Volumes, ClusterFileVolume{
+			Name: volume.Name,
+			Type: volume.Type,
+			Size: volume.Size,
+		})
+	}
+	if autoscaling.Key != "" {
+		fileHostGroup.Autoscaling = &ClusterFileAutoscaling{
+			PolicyType:    autoscaling.PolicyType,
+			Metric:        autoscaling.Metric,
+			Target:        autoscaling.Target,
+			Min:           autoscaling.Min,
+			Max:           autoscaling.Max,
+			Cooldown:      (time.Duration(autoscaling.Cooldown) * time.Second).String(),
+			ScaleInTarget: autoscaling.ScaleInTarget,
+		}
+	}
+	return fileHostGroup
+}
+
+// GetClusterFile returns the cluster settings from DB, hostgroups params are read from their launch templates
+func GetClusterFile(name cluster.ClusterName) (clusterFile ClusterFile, err error) {
+	awsCluster, err := GetCluster(name, true)
+	if err != nil {
+		return
+	}
+	return clusterToFile(awsCluster)
+}
+
+func clusterToFile(awsCluster AWSCluster) (clusterFile ClusterFile, err error) {
+	settings := awsCluster.ClusterSettings
+	clusterFile = ClusterFile{
+		Version: ClusterFileVersion,
+		Name:    string(awsCluster.Name),
+		Region:  env.Config.Region,
+		Network: ClusterFileNetwork{
+			VpcId:               settings.VpcId,
+			Subnet:              settings.Subnet,
+			AdditionalSubnet:    settings.AdditionalSubnet,
+			PrivateSubnet:       settings.PrivateSubnet,
+			UseDynamoDBEndpoint: settings.UseDynamoDBEndpoint,
+		},
+		Dns: ClusterFileDns{
+			Alias:  settings.DnsAlias,
+			ZoneId: settings.DnsZoneId,
+		},
+		FailureDomain: ClusterFileFailureDomain{
+			Strategy: string(settings.FailureDomain),
+			Command:  settings.FailureDomainCmd,
+		},
+		Tags:       settings.TagsMap,
+		HostGroups: map[string]ClusterFileHostGroup{},
+	}
+	for _, hostGroup := range awsCluster.HostGroups {
+		clusterFile.HostGroups[string(hostGroup.HostGroupInfo.Name)] = hostGroupToClusterFile(hostGroup, hostGroup.Autoscaling)
+	}
+	return
+}
+
+func ExportCluster(name cluster.ClusterName, path string) error {
+	clusterFile, err := GetClusterFile(name)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(clusterFile)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(clusterFileHeader), data...), 0644)
+}
+
+func ReadClusterFile(path string) (clusterFile ClusterFile, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	err = yaml.Unmarshal(data, &clusterFile)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("failed parsing cluster file %s: %v", path, err))
+	}
+	return
+}
+
+func validateHostGroupFile(hostGroup HostGroup, current, desired ClusterFileHostGroup) error {
+	name := hostGroup.HostGroupInfo.Name
+	for _, field := range []struct {
+		name            string
+		current, wanted interface{}
+	}{
+		{"role", current.Role, desired.Role},
+		{"instanceType", current.InstanceType, desired.InstanceType},
+		{"imageId", current.ImageId, desired.ImageId},
+		{"keyName", current.KeyName, desired.KeyName},
+		{"iamArn", current.IamArn, desired.IamArn},
+		{"securityGroupsIds", current.SecurityGroupsIds, desired.SecurityGroupsIds},
+		{"volumes", current.Volumes, desired.Volumes},
+		{"maxSize", current.MaxSize, desired.MaxSize},
+		{"httpTokens", current.HttpTokens, desired.HttpTokens},
+	} {
+		// nil and empty lists are the same
+		if fmt.Sprintf("%v", field.current) != fmt.Sprintf("%v", field.wanted) {
+			return errors.New(fmt.Sprintf("hostgroup %s %s can't be changed by apply (current: %v)", name, field.name, field.current))
+		}
+	}
+	for _, subnet := range current.Subnets {
+		if !strings.AnyOf(subnet, desired.Subnets...) {
+			return errors.New(fmt.Sprintf("hostgroup %s subnet %s can't be removed", name, subnet))
+		}
+	}
+	if desired.Autoscaling != nil {
+		params, err := autoscaleParamsFromFile(hostGroup, *desired.Autoscaling)
+		if err != nil {
+			return err
+		}
+		err = validateAutoscaleParams(hostGroup, params)
+		if err != nil {
+			return errors.New(fmt.Sprintf("hostgroup %s autoscaling: %v", name, err))
+		}
+	}
+	return nil
+}
+
+func autoscaleParamsFromFile(hostGroup HostGroup, autoscaling ClusterFileAutoscaling) (params AutoscaleParams, err error) {
+	cooldown, err := time.ParseDuration(autoscaling.Cooldown)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("hostgroup %s autoscaling: invalid cooldown %s", hostGroup.HostGroupInfo.Name, autoscaling.Cooldown))
+		return
+	}
+	params = AutoscaleParams{
+		Name:          hostGroup.HostGroupInfo.ClusterName,
+		HostGroupName: hostGroup.HostGroupInfo.Name,
+		PolicyType:    autoscaling.PolicyType,
+		Metric:        autoscaling.Metric,
+		Target:        autoscaling.Target,
+		Min:           autoscaling.Min,
+		Max:           autoscaling.Max,
+		Cooldown:      cooldown,
+		ScaleInTarget: autoscaling.ScaleInTarget,
+	}
+	return
+}
+
+// validateClusterFile checks the file against the deployed cluster, returning the hostgroups subnets to add
+func validateClusterFile(awsCluster AWSCluster, current, desired ClusterFile) (subnets []string, err error) {
+	if desired.Version != ClusterFileVersion {
+		err = errors.New(fmt.Sprintf("unsupported cluster file version %q, supported version: %s", desired.Version, ClusterFileVersion))
+		return
+	}
+	if desired.Region != current.Region {
+		err = errors.New(fmt.Sprintf("cluster file region %s doesn't match region %s", desired.Region, current.Region))
+		return
+	}
+	if desired.Network != current.Network {
+		err = errors.New("network settings can't be changed by apply")
+		return
+	}
+	err = cluster.ValidateTags(desired.Tags)
+	if err != nil {
+		return
+	}
+	if (desired.Dns.Alias == "") != (desired.Dns.ZoneId == "") {
+		err = errors.New("dns alias and zoneId must be set together")
+		return
+	}
+	if current.Dns.Alias != "" && desired.Dns != current.Dns {
+		err = errors.New(fmt.Sprintf("dns alias %s is already set and can't be changed", current.Dns.Alias))
+		return
+	}
+	if desired.FailureDomain.Strategy != "" {
+		err = common.ValidateFailureDomain(common.FailureDomainStrategy(desired.FailureDomain.Strategy), desired.FailureDomain.Command)
+		if err != nil {
+			return
+		}
+	}
+
+	var names []string
+	for name := range desired.HostGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := current.HostGroups[name]; !ok {
+			err = errors.New(fmt.Sprintf("hostgroup %s was not found in cluster %s", name, awsCluster.Name))
+			return
+		}
+	}
+	for _, hostGroup := range awsCluster.HostGroups {
+		name := string(hostGroup.HostGroupInfo.Name)
+		desiredHostGroup, ok := desired.HostGroups[name]
+		if !ok {
+			err = errors.New(fmt.Sprintf("hostgroup %s is missing in cluster file", name))
+			return
+		}
+		err = validateHostGroupFile(hostGroup, current.HostGroups[name], desiredHostGroup)
+		if err != nil {
+			return
+		}
+		for _, subnet := range desiredHostGroup.Subnets {
+			if !strings.AnyOf(subnet, current.HostGroups[name].Subnets...) && !strings.AnyOf(subnet, subnets...) {
+				subnets = append(subnets, subnet)
+			}
+		}
+	}
+	return
+}
+
+func saveHostGroupsAutoscaling(awsCluster AWSCluster, clusterFile ClusterFile) error {
+	for _, hostGroup := range awsCluster.HostGroups {
+		name := string(hostGroup.HostGroupInfo.Name)
+		autoscaling := clusterFile.HostGroups[name].Autoscaling
+		if autoscaling == nil {
+			err := db.DeleteHostGroupAutoscaling(awsCluster.TableName, name)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		params, err := autoscaleParamsFromFile(hostGroup, *autoscaling)
+		if err != nil {
+			return err
+		}
+		err = db.SaveHostGroupAutoscaling(awsCluster.TableName, name, params.settings())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyClusterFile validates the file against the deployed cluster, saves it to DB and updates the cluster
+func ApplyClusterFile(path string, dryRun bool) error {
+	desired, err := ReadClusterFile(path)
+	if err != nil {
+		return err
+	}
+	if desired.Name == "" {
+		return errors.New("cluster file has no name")
+	}
+	name := cluster.ClusterName(desired.Name)
+	awsCluster, err := GetCluster(name, true)
+	if err != nil {
+		return err
+	}
+	current, err := clusterToFile(awsCluster)
+	if err != nil {
+		return err
+	}
+	subnets, err := validateClusterFile(awsCluster, current, desired)
+	if err != nil {
+		return err
+	}
+	// checked before saving the file settings, the update that follows would refuse the downgrade anyway
+	err = checkDowngrade(awsCluster.ClusterSettings, false)
+	if err != nil {
+		return err
+	}
+	setTags, removedKeys := clusterFileTagsChanges(current.Tags, desired.Tags)
+	if dryRun {
+		logging.UserInfo("cluster file %s is valid", path)
+		for _, change := range clusterFileChanges(current, desired) {
+			logging.UserInfo(change)
+		}
+		// the resources are generated from the file settings, as the update following the apply would
+		awsCluster.ClusterSettings = settingsFromFile(awsCluster.ClusterSettings, desired)
+		for i := range awsCluster.HostGroups {
+			awsCluster.HostGroups[i].Autoscaling, err = autoscalingFromFile(awsCluster.HostGroups[i], desired)
+			if err != nil {
+				return err
+			}
+		}
+		awsCluster.Init()
+		return updateCluster(awsCluster, UpdateParams{Name: name, DryRun: true, Subnets: subnets})
+	}
+
+	// tags are saved by UpdateClusterTags, which re-tags the deployed resources as well
+	settings := settingsFromFile(awsCluster.ClusterSettings, desired)
+	settings.TagsMap = awsCluster.ClusterSettings.TagsMap
+	err = db.SaveClusterSettings(awsCluster.TableName, settings)
+	if err != nil {
+		return err
+	}
+	err = saveHostGroupsAutoscaling(awsCluster, desired)
+	if err != nil {
+		return err
+	}
+	logging.UserProgress("Cluster %s settings were saved", name)
+	if len(setTags) > 0 || len(removedKeys) > 0 {
+		err = UpdateClusterTags(name, setTags, removedKeys)
+		if err != nil {
+			return err
+		}
+	}
+	return UpdateCluster(UpdateParams{Name: name, Subnets: subnets})
+}
+
+// settingsFromFile returns the cluster settings with the ones the cluster file holds and apply may change
+func settingsFromFile(settings db.ClusterSettings, clusterFile ClusterFile) db.ClusterSettings {
+	settings.DnsAlias = clusterFile.Dns.Alias
+	settings.DnsZoneId = clusterFile.Dns.ZoneId
+	settings.FailureDomain = common.FailureDomainStrategy(clusterFile.FailureDomain.Strategy)
+	settings.FailureDomainCmd = clusterFile.FailureDomain.Command
+	settings.TagsMap = cluster.Tags{}.Update(clusterFile.Tags)
+	return settings
+}
+
+// autoscalingFromFile returns the hostgroup autoscaling settings of the cluster file, empty when it has none
+func autoscalingFromFile(hostGroup HostGroup, clusterFile ClusterFile) (autoscaling db.HostGroupAutoscaling, err error) {
+	fileAutoscaling := clusterFile.HostGroups[string(hostGroup.HostGroupInfo.Name)].Autoscaling
+	if fileAutoscaling == nil {
+		return
+	}
+	params, err := autoscaleParamsFromFile(hostGroup, *fileAutoscaling)
+	if err != nil {
+		return
+	}
+	return params.settings(), nil
+}
+
+// clusterFileTagsChanges returns the tags to set, new or with a new value, and the keys to remove
+func clusterFileTagsChanges(current, desired map[string]string) (setTags cluster.Tags, removedKeys []string) {
+	setTags = cluster.Tags{}
+	for key, value := range desired {
+		if currentValue, ok := current[key]; !ok || currentValue != value {
+			setTags[key] = value
+		}
+	}
+	for key := range current {
+		if _, ok := desired[key]; !ok {
+			removedKeys = append(removedKeys, key)
+		}
+	}
+	sort.Strings(removedKeys)
+	return
+}
+
+// clusterFileChanges describes the settings apply changes, the file is expected to be valid
+func clusterFileChanges(current, desired ClusterFile) (changes []string) {
+	if desired.Dns != current.Dns {
+		changes = append(changes, fmt.Sprintf("dns alias %s in zone %s will be set", desired.Dns.Alias, desired.Dns.ZoneId))
+	}
+	if desired.FailureDomain != current.FailureDomain {
+		changes = append(changes, fmt.Sprintf("failure domain will be changed from %q to %q",
+			current.FailureDomain.Strategy, desired.FailureDomain.Strategy))
+	}
+	setTags, removedKeys := clusterFileTagsChanges(current.Tags, desired.Tags)
+	var setKeys []string
+	for key := range setTags {
+		setKeys = append(setKeys, key)
+	}
+	sort.Strings(setKeys)
+	for _, key := range setKeys {
+		changes = append(changes, fmt.Sprintf("tag %s will be set to %q", key, setTags[key]))
+	}
+	for _, key := range removedKeys {
+		changes = append(changes, fmt.Sprintf("tag %s will be removed", key))
+	}
+
+	var names []string
+	for name := range desired.HostGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		currentHostGroup, desiredHostGroup := current.HostGroups[name], desired.HostGroups[name]
+		for _, subnet := range desiredHostGroup.Subnets {
+			if !strings.AnyOf(subnet, currentHostGroup.Subnets...) {
+				changes = append(changes, fmt.Sprintf("hostgroup %s subnet %s will be added", name, subnet))
+			}
+		}
+		switch {
+		case desiredHostGroup.Autoscaling == nil && currentHostGroup.Autoscaling != nil:
+			changes = append(changes, fmt.Sprintf("hostgroup %s scaling policy will be removed", name))
+		case desiredHostGroup.Autoscaling != nil && (currentHostGroup.Autoscaling == nil || *desiredHostGroup.Autoscaling != *currentHostGroup.Autoscaling):
+			changes = append(changes, fmt.Sprintf("hostgroup %s %s scaling policy on %s will be set",
+				name, desiredHostGroup.Autoscaling.PolicyType, desiredHostGroup.Autoscaling.Metric))
+		}
+	}
+	return
+}
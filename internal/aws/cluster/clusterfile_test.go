@@ -0,0 +1,195 @@
+package cluster
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/cluster"
+)
+
+func testClusterFile() (AWSCluster, ClusterFile) {
+	awsCluster := AWSCluster{
+		Name: "test",
+		HostGroups: []HostGroup{
+			GenerateHostGroup("test", common.HostGroupParams{}, common.RoleBackend, "Backends"),
+			GenerateHostGroup("test", common.HostGroupParams{}, common.RoleClient, "Clients"),
+		},
+	}
+	clusterFile := ClusterFile{
+		Version: ClusterFileVersion,
+		Name:    "test",
+		Region:  "eu-west-1",
+		Network: ClusterFileNetwork{VpcId: "vpc-1", Subnet: "subnet-1"},
+		Tags:    map[string]string{"team": "storage", "env": "dev"},
+		HostGroups: map[string]ClusterFileHostGroup{
+			"Backends": {Role: "backend", InstanceType: "i3en.2xlarge", Subnets: []string{"subnet-1"}, MaxSize: 42},
+			"Clients":  {Role: "client", InstanceType: "c5.2xlarge", Subnets: []string{"subnet-1"}, MaxSize: 30},
+		},
+	}
+	return awsCluster, clusterFile
+}
+
+// cloneClusterFile copies the maps the tests modify
+func cloneClusterFile(clusterFile ClusterFile) ClusterFile {
+	clone := clusterFile
+	clone.Tags = map[string]string{}
+	for key, value := range clusterFile.Tags {
+		clone.Tags[key] = value
+	}
+	clone.HostGroups = map[string]ClusterFileHostGroup{}
+	for name, hostGroup := range clusterFile.HostGroups {
+		clone.HostGroups[name] = hostGroup
+	}
+	return clone
+}
+
+func TestValidateClusterFile(t *testing.T) {
+	awsCluster, current := testClusterFile()
+	tests := []struct {
+		name    string
+		edit    func(desired *ClusterFile)
+		err     string
+		subnets []string
+	}{
+		{name: "unchanged", edit: func(desired *ClusterFile) {}},
+		{
+			name: "tags and failure domain",
+			edit: func(desired *ClusterFile) {
+				desired.Tags = map[string]string{"team": "platform"}
+				desired.FailureDomain = ClusterFileFailureDomain{Strategy: "az"}
+			},
+		},
+		{
+			name: "added subnet",
+			edit: func(desired *ClusterFile) {
+				backends := desired.HostGroups["Backends"]
+				backends.Subnets = []string{"subnet-1", "subnet-2"}
+				desired.HostGroups["Backends"] = backends
+				clients := desired.HostGroups["Clients"]
+				clients.Subnets = []string{"subnet-1", "subnet-2"}
+				desired.HostGroups["Clients"] = clients
+			},
+			subnets: []string{"subnet-2"},
+		},
+		{
+			name: "autoscaling",
+			edit: func(desired *ClusterFile) {
+				backends := desired.HostGroups["Backends"]
+				backends.Autoscaling = &ClusterFileAutoscaling{PolicyType: PolicyTypeStep, Metric: "cpu", Target: 70, Min: 6, Max: 10, Cooldown: "5m"}
+				desired.HostGroups["Backends"] = backends
+			},
+		},
+		{name: "version", edit: func(desired *ClusterFile) { desired.Version = "v0" }, err: "unsupported cluster file version"},
+		{name: "region", edit: func(desired *ClusterFile) { desired.Region = "us-east-1" }, err: "doesn't match region"},
+		{name: "network", edit: func(desired *ClusterFile) { desired.Network.Subnet = "subnet-2" }, err: "network settings"},
+		{name: "dns", edit: func(desired *ClusterFile) { desired.Dns.Alias = "weka.example.com" }, err: "must be set together"},
+		{
+			name: "failure domain",
+			edit: func(desired *ClusterFile) { desired.FailureDomain = ClusterFileFailureDomain{Strategy: "rack"} },
+			err:  "unknown failure domain strategy",
+		},
+		{name: "missing hostgroup", edit: func(desired *ClusterFile) { delete(desired.HostGroups, "Clients") }, err: "Clients is missing"},
+		{
+			name: "unknown hostgroup",
+			edit: func(desired *ClusterFile) { desired.HostGroups["Other"] = desired.HostGroups["Clients"] },
+			err:  "Other was not found",
+		},
+		{
+			name: "instance type",
+			edit: func(desired *ClusterFile) {
+				backends := desired.HostGroups["Backends"]
+				backends.InstanceType = "i3en.3xlarge"
+				desired.HostGroups["Backends"] = backends
+			},
+			err: "Backends instanceType can't be changed",
+		},
+		{
+			name: "removed subnet",
+			edit: func(desired *ClusterFile) {
+				clients := desired.HostGroups["Clients"]
+				clients.Subnets = []string{"subnet-2"}
+				desired.HostGroups["Clients"] = clients
+			},
+			err: "subnet-1 can't be removed",
+		},
+		{
+			name: "invalid autoscaling",
+			edit: func(desired *ClusterFile) {
+				backends := desired.HostGroups["Backends"]
+				backends.Autoscaling = &ClusterFileAutoscaling{PolicyType: PolicyTypeStep, Metric: "cpu", Target: 70, Min: 1, Max: 10, Cooldown: "5m"}
+				desired.HostGroups["Backends"] = backends
+			},
+			err: "below the minimum",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			desired := cloneClusterFile(current)
+			test.edit(&desired)
+			subnets, err := validateClusterFile(awsCluster, current, desired)
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got error %v, expected %q", err, test.err)
+			}
+			if !reflect.DeepEqual(subnets, test.subnets) {
+				t.Errorf("got subnets %v, expected %v", subnets, test.subnets)
+			}
+		})
+	}
+}
+
+func TestClusterFileTagsChanges(t *testing.T) {
+	setTags, removedKeys := clusterFileTagsChanges(
+		map[string]string{"team": "storage", "env": "dev", "owner": "alice"},
+		map[string]string{"team": "platform", "env": "dev", "cost-center": "42"},
+	)
+	if expected := (cluster.Tags{"team": "platform", "cost-center": "42"}); !reflect.DeepEqual(setTags, expected) {
+		t.Errorf("got set tags %v, expected %v", setTags, expected)
+	}
+	if expected := []string{"owner"}; !reflect.DeepEqual(removedKeys, expected) {
+		t.Errorf("got removed keys %v, expected %v", removedKeys, expected)
+	}
+
+	setTags, removedKeys = clusterFileTagsChanges(map[string]string{"env": "dev"}, map[string]string{"env": "dev"})
+	if len(setTags) != 0 || len(removedKeys) != 0 {
+		t.Errorf("unchanged tags got set tags %v and removed keys %v", setTags, removedKeys)
+	}
+}
+
+func TestClusterFileChanges(t *testing.T) {
+	_, current := testClusterFile()
+	if changes := clusterFileChanges(current, current); len(changes) != 0 {
+		t.Errorf("unchanged file got changes %v", changes)
+	}
+
+	desired := cloneClusterFile(current)
+	desired.Dns = ClusterFileDns{Alias: "weka.example.com", ZoneId: "Z1"}
+	desired.Tags = map[string]string{"team": "platform", "env": "dev"}
+	backends := desired.HostGroups["Backends"]
+	backends.Subnets = []string{"subnet-1", "subnet-2"}
+	backends.Autoscaling = &ClusterFileAutoscaling{PolicyType: PolicyTypeStep, Metric: "cpu", Target: 70, Min: 6, Max: 10, Cooldown: "5m"}
+	desired.HostGroups["Backends"] = backends
+	expected := []string{
+		"dns alias weka.example.com in zone Z1 will be set",
+		`tag team will be set to "platform"`,
+		"hostgroup Backends subnet subnet-2 will be added",
+		"hostgroup Backends step scaling policy on cpu will be set",
+	}
+	if changes := clusterFileChanges(current, desired); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("got changes %v, expected %v", changes, expected)
+	}
+
+	removed := cloneClusterFile(desired)
+	removed.Tags = map[string]string{"team": "platform"}
+	backends = removed.HostGroups["Backends"]
+	backends.Autoscaling = nil
+	removed.HostGroups["Backends"] = backends
+	expected = []string{"tag env will be removed", "hostgroup Backends scaling policy will be removed"}
+	if changes := clusterFileChanges(desired, removed); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("got changes %v, expected %v", changes, expected)
+	}
+}
@@ -41,14 +41,7 @@ func migrateSettings(clusterName cluster.ClusterName, dbClusterSettings *db.Clus
 		migrateRequired = true
 	}
 
-	versionInfo, err := env.GetBuildVersion()
-	if err != nil {
-		return err
-	}
-	if dbClusterSettings.BuildVersion != versionInfo.BuildVersion {
-		dbClusterSettings.BuildVersion = versionInfo.BuildVersion
-		migrateRequired = true
-	}
+	// BuildVersion is not migrated, it records the wekactl that deployed the cluster and is saved by UpdateCluster
 
 	for _, hostGroup := range hostGroups {
 		if hostGroup.HostGroupInfo.Role == common.RoleBackend {
@@ -96,10 +89,18 @@ func GetCluster(name cluster.ClusterName, fetchHotGroupParams bool) (awsCluster
 		return
 	}
 
+	for i := range hostGroups {
+		hostGroups[i].Autoscaling, err = db.GetHostGroupAutoscaling(db.GetTableName(name), string(hostGroups[i].HostGroupInfo.Name))
+		if err != nil {
+			return
+		}
+	}
+
 	awsCluster = AWSCluster{
 		Name:            name,
 		ClusterSettings: dbClusterSettings,
 		HostGroups:      hostGroups,
+		TableName:       db.GetTableName(name),
 	}
 	awsCluster.Init()
 	return
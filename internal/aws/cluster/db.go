@@ -60,6 +60,14 @@ func (d *DynamoDb) TargetVersion() string {
 	return dbVersion
 }
 
+func (d *DynamoDb) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	if d.StackId == "" {
+		// the stack id is only known on import, the table keeps the one it was tagged with
+		delete(tags, "wekactl.io/stack_id")
+	}
+	return db.UpdateDbTags(d.ClusterName, tags, removedKeys)
+}
+
 func (d *DynamoDb) Create(tags cluster.Tags) error {
 	return db.CreateDb(d.ResourceName(), d.KmsKey.Key, tags)
 }
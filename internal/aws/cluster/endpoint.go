@@ -0,0 +1,103 @@
+package cluster
+
+import (
+	"github.com/rs/zerolog/log"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/cluster"
+	strings2 "wekactl/internal/lib/strings"
+)
+
+const dynamoDBEndpointVersion = "v1"
+
+// DynamoDBEndpoint is a vpc gateway endpoint letting the scale lambdas, which run inside the vpc, read the weka
+// credentials from DB, so that they never pass through the state machine. Route tables already routing DynamoDB
+// through another endpoint of the vpc are left as is.
+type DynamoDBEndpoint struct {
+	ClusterName          cluster.ClusterName
+	VpcId                string
+	Subnets              []string
+	EndpointId           string
+	MissingRouteTableIds []string
+}
+
+func (d *DynamoDBEndpoint) Tags() cluster.Tags {
+	return cluster.GetCommonResourceTags(d.ClusterName, d.TargetVersion())
+}
+
+func (d *DynamoDBEndpoint) SubResources() []cluster.Resource {
+	return []cluster.Resource{}
+}
+
+func (d *DynamoDBEndpoint) ResourceName() string {
+	return common.GenerateResourceName(d.ClusterName, "dynamodb")
+}
+
+func (d *DynamoDBEndpoint) Fetch() error {
+	var routeTableIds []string
+	for _, subnet := range d.Subnets {
+		routeTableId, err := common.GetSubnetRouteTableId(d.VpcId, subnet)
+		if err != nil {
+			return err
+		}
+		if !strings2.AnyOf(routeTableId, routeTableIds...) {
+			routeTableIds = append(routeTableIds, routeTableId)
+		}
+	}
+
+	endpoints, err := common.GetDynamoDBEndpoints(d.VpcId)
+	if err != nil {
+		return err
+	}
+	var coveredRouteTableIds []string
+	for _, endpoint := range endpoints {
+		coveredRouteTableIds = append(coveredRouteTableIds, strings2.RefListToList(endpoint.RouteTableIds)...)
+		for _, tag := range endpoint.Tags {
+			if *tag.Key == cluster.ClusterNameTagKey && *tag.Value == string(d.ClusterName) {
+				d.EndpointId = *endpoint.VpcEndpointId
+			}
+		}
+	}
+
+	d.MissingRouteTableIds = nil
+	for _, routeTableId := range routeTableIds {
+		if !strings2.AnyOf(routeTableId, coveredRouteTableIds...) {
+			d.MissingRouteTableIds = append(d.MissingRouteTableIds, routeTableId)
+		}
+	}
+	return nil
+}
+
+func (d *DynamoDBEndpoint) Init() {
+	log.Debug().Msgf("Initializing cluster %s DynamoDB endpoint ...", string(d.ClusterName))
+}
+
+func (d *DynamoDBEndpoint) DeployedVersion() string {
+	if len(d.MissingRouteTableIds) == 0 {
+		return d.TargetVersion()
+	}
+	if d.EndpointId != "" {
+		return d.TargetVersion() + "#" // just to make it different from TargetVersion so we will enter Update flow
+	}
+	return ""
+}
+
+func (d *DynamoDBEndpoint) TargetVersion() string {
+	return dynamoDBEndpointVersion
+}
+
+func (d *DynamoDBEndpoint) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	if d.EndpointId == "" {
+		// route tables are covered by an endpoint wekactl doesn't manage
+		return nil
+	}
+	return common.UpdateEc2Tags([]*string{&d.EndpointId}, tags.AsEc2(), removedKeys)
+}
+
+func (d *DynamoDBEndpoint) Create(tags cluster.Tags) (err error) {
+	d.EndpointId, err = common.CreateDynamoDBEndpoint(d.VpcId, d.MissingRouteTableIds, tags.AsEc2())
+	return
+}
+
+func (d *DynamoDBEndpoint) Update(tags cluster.Tags) error {
+	return common.AddVpcEndpointRouteTables(d.EndpointId, d.MissingRouteTableIds)
+}
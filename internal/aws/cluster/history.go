@@ -0,0 +1,102 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/scalemachine"
+	"wekactl/internal/cluster"
+	"wekactl/internal/logging"
+)
+
+type HistoryParams struct {
+	Name          cluster.ClusterName
+	HostGroupName common.HostGroupName
+	Since         time.Duration
+	FailedOnly    bool
+	// Limit is the number of executions listed, their history is fetched one by one
+	Limit int
+}
+
+// RenderHostGroupHistory prints the hostgroup state machine executions, newest first
+func RenderHostGroupHistory(params HistoryParams) error {
+	if params.Limit < 1 {
+		return errors.New("limit must be positive")
+	}
+	stateMachineArn, err := scalemachine.GetStateMachineArn(common.GenerateResourceName(params.Name, params.HostGroupName))
+	if err != nil {
+		return err
+	}
+	// one more than listed, telling whether older executions were left out
+	executions, err := scalemachine.ListExecutions(stateMachineArn, time.Now().Add(-params.Since), params.FailedOnly, params.Limit+1)
+	if err != nil {
+		return err
+	}
+	truncated := len(executions) > params.Limit
+	if truncated {
+		executions = executions[:params.Limit]
+	}
+
+	fields := []string{"execution", "status", "start", "duration", "hosts removed", "instances terminated", "transient errors"}
+	var data [][]string
+	for _, execution := range executions {
+		details, err := scalemachine.GetExecutionDetails(*execution.ExecutionArn)
+		if err != nil {
+			return err
+		}
+		summary := details.Summary()
+		data = append(data, []string{
+			details.Name,
+			details.Status,
+			details.Start.Local().Format(time.RFC3339),
+			details.Duration().String(),
+			strings.Join(summary.HostsRemoved, "\n"),
+			strings.Join(summary.InstancesTerminated, "\n"),
+			strings.Join(summary.TransientErrors, "\n"),
+		})
+	}
+	common.RenderTable(fields, data)
+	if truncated {
+		logging.UserInfo("Only the latest %d executions were listed, use --limit to list more", params.Limit)
+	}
+	return nil
+}
+
+func indentJson(data string) string {
+	var value interface{}
+	if json.Unmarshal([]byte(data), &value) != nil {
+		return data
+	}
+	indented, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return data
+	}
+	return string(indented)
+}
+
+// PrintExecution prints the inputs and outputs of each state of the execution, given by name or arn
+func PrintExecution(clusterName cluster.ClusterName, hostGroupName common.HostGroupName, execution string) error {
+	executionArn, err := scalemachine.GetExecutionArn(common.GenerateResourceName(clusterName, hostGroupName), execution)
+	if err != nil {
+		return err
+	}
+	details, err := scalemachine.GetExecutionDetails(executionArn)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Execution: %s\nStatus: %s\nStart: %s\nDuration: %s\n", details.Arn, details.Status, details.Start.Local().Format(time.RFC3339), details.Duration())
+	if details.Error != "" || details.Cause != "" {
+		fmt.Printf("Error: %s\nCause: %s\n", details.Error, indentJson(details.Cause))
+	}
+	for _, step := range details.Steps {
+		fmt.Printf("\n### %s input\n%s\n", step.Name, indentJson(step.Input))
+		if step.Output != "" {
+			fmt.Printf("### %s output\n%s\n", step.Name, indentJson(step.Output))
+		}
+	}
+	return nil
+}
@@ -21,6 +21,7 @@ type HostGroup struct {
 	AutoscalingGroup AutoscalingGroup
 	TableName        string
 	ClusterSettings  db.ClusterSettings
+	Autoscaling      db.HostGroupAutoscaling
 }
 
 func (h *HostGroup) Tags() cluster.Tags {
@@ -47,6 +48,10 @@ func (h *HostGroup) TargetVersion() string {
 	return hostGroupVersion
 }
 
+func (h *HostGroup) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return nil
+}
+
 func (h *HostGroup) Create(tags cluster.Tags) (err error) {
 	if h.HostGroupInfo.Role != common.RoleBackend {
 		return
@@ -77,6 +82,7 @@ func (h *HostGroup) Init() {
 	h.AutoscalingGroup.HostGroupParams = h.HostGroupParams
 	h.AutoscalingGroup.TableName = h.TableName
 	h.AutoscalingGroup.ClusterSettings = h.ClusterSettings
+	h.AutoscalingGroup.Autoscaling = h.Autoscaling
 	h.AutoscalingGroup.Init()
 }
 
@@ -1,12 +1,19 @@
 package cluster
 
 import (
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"strings"
 	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
 	"wekactl/internal/aws/iam"
+	"wekactl/internal/aws/lambdas"
 	"wekactl/internal/cluster"
+	"wekactl/internal/env"
 	strings2 "wekactl/internal/lib/strings"
+	"wekactl/internal/logging"
 )
 
 type IamProfile struct {
@@ -19,6 +26,56 @@ type IamProfile struct {
 	AssumeRolePolicy iam.AssumeRolePolicyDocument
 	HostGroupInfo    common.HostGroupInfo
 	Policy           iam.PolicyDocument
+	ClusterSettings  db.ClusterSettings
+}
+
+// IamRoleNames are the roles of a hostgroup, an existing role can be supplied on import in place of each of them
+var IamRoleNames = []string{
+	string(lambdas.LambdaJoin),
+	string(lambdas.LambdaFetchInfo),
+	string(lambdas.LambdaScale),
+	string(lambdas.LambdaTerminate),
+	string(lambdas.LambdaTransient),
+	string(lambdas.LambdaMetrics),
+	"sm",
+	"cw",
+}
+
+// ParseIamRoles parses the name=arn roles supplied on import
+func ParseIamRoles(list []string) (roles map[string]string, err error) {
+	roles = map[string]string{}
+	for _, item := range list {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || !strings2.AnyOf(parts[0], IamRoleNames...) {
+			err = errors.New(fmt.Sprintf("invalid iam role %q, NAME=ARN is expected, names are: %s", item, strings.Join(IamRoleNames, ", ")))
+			return
+		}
+		if _, ok := roles[parts[0]]; ok {
+			err = errors.New(fmt.Sprintf("iam role %s was supplied more than once", parts[0]))
+			return
+		}
+		err = iam.ValidateRoleArn(parts[1])
+		if err != nil {
+			return
+		}
+		roles[parts[0]] = parts[1]
+	}
+	return
+}
+
+// policyScope restricts the hostgroup roles policies to the cluster resources
+func policyScope(hostGroupInfo common.HostGroupInfo, tableName, asgName string) iam.Scope {
+	return iam.Scope{
+		Region:      env.Config.Region,
+		ClusterName: hostGroupInfo.ClusterName,
+		TableName:   tableName,
+		ASGName:     asgName,
+	}
+}
+
+// externalRoleArn is the role supplied on import in place of this one, empty when wekactl owns the role
+func (i *IamProfile) externalRoleArn() string {
+	return i.ClusterSettings.IamRoles[i.Name]
 }
 
 func (i *IamProfile) Tags() cluster.Tags {
@@ -34,16 +91,25 @@ func (i *IamProfile) resourceNameBase() string {
 	return fmt.Sprintf("%s-%s", name, i.Name)
 }
 
+func (i *IamProfile) rolesPath() string {
+	return iam.RolesPath(i.HostGroupInfo.ClusterName, i.ClusterSettings.IamRolesPath)
+}
+
 func (i *IamProfile) ResourceName() string {
 	//creating and deleting the same role name and use it for lambda caused problems, so we use unique uuid
 	if i.RoleName == "" {
-		return strings2.ElfHashSuffixed(fmt.Sprintf("%s-%s", i.resourceNameBase(), uuid.New().String()), 64)
+		return strings2.ElfHashSuffixed(fmt.Sprintf("%s%s-%s", i.ClusterSettings.IamRolesNamePrefix, i.resourceNameBase(), uuid.New().String()), 64)
 	}
 	return i.RoleName
 }
 
 func (i *IamProfile) Fetch() error {
-	roleName, err := iam.GetIamRoleName(i.HostGroupInfo.ClusterName, i.resourceNameBase())
+	if i.externalRoleArn() != "" {
+		// never created or updated
+		i.Version = i.TargetVersion()
+		return nil
+	}
+	roleName, err := iam.GetIamRoleName(i.rolesPath(), i.resourceNameBase())
 	if err != nil || roleName == "" {
 		return err
 	}
@@ -54,11 +120,22 @@ func (i *IamProfile) Fetch() error {
 	}
 
 	i.Version = version
+	permissionsBoundary, err := iam.GetRolePermissionsBoundary(roleName)
+	if err != nil {
+		return err
+	}
+	if permissionsBoundary != i.ClusterSettings.IamPermissionsBoundary {
+		// forces an update, putting back the permissions boundary of the cluster settings
+		i.Version += "#"
+	}
 	return nil
 }
 
 func (i *IamProfile) Init() {
-	return
+	if roleArn := i.externalRoleArn(); roleArn != "" {
+		i.Arn = roleArn
+		i.RoleName = iam.RoleNameFromArn(roleArn)
+	}
 }
 
 func (i *IamProfile) DeployedVersion() string {
@@ -69,8 +146,16 @@ func (i *IamProfile) TargetVersion() string {
 	return i.Policy.VersionHash()
 }
 
+func (i *IamProfile) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	if i.externalRoleArn() != "" {
+		return nil
+	}
+	return iam.UpdateRoleTags(i.RoleName, tags.AsIam(), removedKeys)
+}
+
 func (i *IamProfile) Create(tags cluster.Tags) error {
-	arn, err := iam.CreateIamRole(i.HostGroupInfo.ClusterName, tags.AsIam(), i.ResourceName(), i.PolicyName, i.AssumeRolePolicy, i.Policy)
+	arn, err := iam.CreateIamRole(
+		i.rolesPath(), i.ClusterSettings.IamPermissionsBoundary, tags.AsIam(), i.ResourceName(), i.PolicyName, i.AssumeRolePolicy, i.Policy)
 	if err != nil {
 		return err
 	}
@@ -79,7 +164,52 @@ func (i *IamProfile) Create(tags cluster.Tags) error {
 }
 
 func (i *IamProfile) Update(tags cluster.Tags) error {
+	err := iam.SetRolePermissionsBoundary(i.RoleName, i.ClusterSettings.IamPermissionsBoundary)
+	if err != nil {
+		return err
+	}
 	return iam.UpdateRolePolicy(
-		i.HostGroupInfo.ClusterName, i.resourceNameBase(), i.PolicyName, i.Policy,
+		i.rolesPath(), i.resourceNameBase(), i.PolicyName, i.Policy,
 		cluster.GetResourceVersionTag(i.TargetVersion()).AsIam())
 }
+
+func (i *IamProfile) Verify() ([]string, error) {
+	if roleArn := i.externalRoleArn(); roleArn != "" {
+		return iam.GetExternalRoleDrift(roleArn, i.AssumeRolePolicy, i.Policy)
+	}
+	return iam.GetRoleDrift(i.RoleName, i.PolicyName, i.ClusterSettings.IamPermissionsBoundary, i.AssumeRolePolicy, i.Policy)
+}
+
+func (i *IamProfile) Reconcile(tags cluster.Tags) error {
+	if roleArn := i.externalRoleArn(); roleArn != "" {
+		logging.UserWarning("role %s is externally owned and wasn't reconciled, it must be fixed by its owner", roleArn)
+		return nil
+	}
+	return iam.ReconcileRole(
+		i.rolesPath(), i.resourceNameBase(), i.RoleName, i.PolicyName, i.ClusterSettings.IamPermissionsBoundary, i.AssumeRolePolicy, i.Policy,
+		cluster.GetResourceVersionTag(i.TargetVersion()).AsIam())
+}
+
+// validateExternalRoles checks the roles supplied on import against the generated trust and permissions policies of
+// the roles they replace, before anything relies on them
+func validateExternalRoles(r cluster.Resource) error {
+	if profile, ok := r.(*IamProfile); ok {
+		if roleArn := profile.externalRoleArn(); roleArn != "" {
+			drifts, err := iam.GetExternalRoleDrift(roleArn, profile.AssumeRolePolicy, profile.Policy)
+			if err != nil {
+				return err
+			}
+			if len(drifts) > 0 {
+				return errors.New(fmt.Sprintf("hostgroup %s %s role %s: %s",
+					profile.HostGroupInfo.Name, profile.Name, roleArn, strings.Join(drifts, "; ")))
+			}
+			log.Debug().Msgf("hostgroup %s %s role %s is valid", profile.HostGroupInfo.Name, profile.Name, roleArn)
+		}
+	}
+	for _, subResource := range r.SubResources() {
+		if err := validateExternalRoles(subResource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
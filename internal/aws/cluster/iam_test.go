@@ -0,0 +1,46 @@
+package cluster
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIamRoles(t *testing.T) {
+	joinArn := "arn:aws:iam::123456789012:role/platform/weka-join"
+	smArn := "arn:aws:iam::123456789012:role/weka-sm"
+	tests := []struct {
+		name  string
+		list  []string
+		roles map[string]string
+		err   string
+	}{
+		{name: "none", roles: map[string]string{}},
+		{
+			name:  "roles",
+			list:  []string{"join=" + joinArn, "sm=" + smArn},
+			roles: map[string]string{"join": joinArn, "sm": smArn},
+		},
+		{name: "missing arn", list: []string{"join"}, err: "NAME=ARN is expected"},
+		{name: "unknown name", list: []string{"other=" + joinArn}, err: "NAME=ARN is expected"},
+		{name: "duplicate", list: []string{"join=" + joinArn, "join=" + smArn}, err: "supplied more than once"},
+		{name: "invalid arn", list: []string{"join=weka-join"}, err: "an iam role arn is expected"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			roles, err := ParseIamRoles(test.list)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, expected %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(roles, test.roles) {
+				t.Errorf("got roles %v, expected %v", roles, test.roles)
+			}
+		})
+	}
+}
@@ -11,10 +11,13 @@ import (
 	"wekactl/internal/aws/autoscaling"
 	"wekactl/internal/aws/common"
 	"wekactl/internal/aws/db"
+	"wekactl/internal/aws/dist"
+	"wekactl/internal/aws/iam"
 	"wekactl/internal/cluster"
 	"wekactl/internal/connectors"
 	"wekactl/internal/env"
 	strings2 "wekactl/internal/lib/strings"
+	"wekactl/internal/logging"
 )
 
 type ClusterInstances struct {
@@ -190,6 +193,40 @@ func instanceIdsToClusterInstances(instanceIds []string) (clusterInstances Clust
 }
 
 func ImportCluster(params cluster.ImportParams) (err error) {
+	err = common.ValidateFailureDomain(common.FailureDomainStrategy(params.FailureDomain), params.FailureDomainCmd)
+	if err != nil {
+		return
+	}
+	err = iam.ValidateRoleOptions(params.IamRolesPath, params.IamRolesNamePrefix, params.IamPermissionsBoundary)
+	if err != nil {
+		return
+	}
+	// validated before anything is created
+	tags, err := params.TagsMap()
+	if err != nil {
+		return
+	}
+	iamRoles, err := ParseIamRoles(params.IamRoles)
+	if err != nil {
+		return
+	}
+	if !params.SkipPreflight {
+		checks := PreflightCluster(params)
+		if err = PreflightError(checks); err != nil {
+			RenderPreflightChecks(checks)
+			return errors2.Wrap(err, "fix them or import with --skip-preflight")
+		}
+		logging.UserProgress("Preflight checks passed")
+	} else if len(iamRoles) > 0 {
+		// preflight validates them otherwise
+		generatedCluster := generateAWSCluster(params.Name, db.GetTableName(cluster.ClusterName(params.Name)), db.ClusterSettings{IamRoles: iamRoles}, false)
+		generatedCluster.Init()
+		err = validateExternalRoles(&generatedCluster)
+		if err != nil {
+			return
+		}
+	}
+
 	var stackId string
 	var clusterSettings db.ClusterSettings
 	var clusterInstances ClusterInstances
@@ -224,6 +261,11 @@ func ImportCluster(params cluster.ImportParams) (err error) {
 	}
 	clusterSettings.VpcId = vpcId
 
+	err = addHostGroupsSubnets(&clusterSettings, params.Subnets)
+	if err != nil {
+		return err
+	}
+
 	clusterSettings.AdditionalSubnet = params.AdditionalAlbSubnet
 	if clusterSettings.AdditionalSubnet == "" {
 		additionalSubnet, err := common.GetAdditionalVpcSubnet(vpcId, clusterSettings.Subnet)
@@ -237,15 +279,22 @@ func ImportCluster(params cluster.ImportParams) (err error) {
 	}
 
 	clusterSettings.PrivateSubnet = params.PrivateSubnet
-	clusterSettings.TagsMap = params.TagsMap()
+	clusterSettings.TagsMap = tags
 	versionInfo, err := env.GetBuildVersion()
 	if err != nil {
 		return err
 	}
 	clusterSettings.BuildVersion = versionInfo.BuildVersion
+	clusterSettings.LambdasID = dist.LambdasID
 	clusterSettings.DnsAlias = params.DnsAlias
 	clusterSettings.DnsZoneId = params.DnsZoneId
 	clusterSettings.UseDynamoDBEndpoint = params.UseDynamoDBEndpoint
+	clusterSettings.IamRolesPath = params.IamRolesPath
+	clusterSettings.IamRolesNamePrefix = params.IamRolesNamePrefix
+	clusterSettings.IamPermissionsBoundary = params.IamPermissionsBoundary
+	clusterSettings.IamRoles = iamRoles
+	clusterSettings.FailureDomain = common.FailureDomainStrategy(params.FailureDomain)
+	clusterSettings.FailureDomainCmd = params.FailureDomainCmd
 
 	dynamoDb := DynamoDb{
 		ClusterName: cluster.ClusterName(params.Name),
@@ -270,6 +319,12 @@ func ImportCluster(params cluster.ImportParams) (err error) {
 	if len(errs) != 0 {
 		return errs[0]
 	}
+	// only instances launched from the auto scaling group get it, the terminate lambda may only terminate tagged ones
+	clusterNameTag := cluster.Tags{cluster.ClusterNameTagKey: params.Name}
+	err = common.UpdateEc2Tags(common.GetInstancesIdsRefs(clusterInstances.All()), clusterNameTag.AsEc2(), nil)
+	if err != nil {
+		return err
+	}
 
 	clientsExist := len(clusterInstances.Clients) > 0
 	awsCluster := generateAWSCluster(params.Name, dynamoDb.ResourceName(), clusterSettings, clientsExist)
@@ -300,12 +355,11 @@ func ImportCluster(params cluster.ImportParams) (err error) {
 }
 
 func importClusterParamsFromClusterInstances(instances ClusterInstances) (defaultParams db.ClusterSettings, err error) {
-	minBackendsNumber := 5
-	if len(instances.Backends) < minBackendsNumber {
+	if len(instances.Backends) < common.MinBackendsNumber {
 		return defaultParams, errors.New(fmt.Sprintf(
 			"%d backend instances found, minimum is: %d, can't proceed with import",
 			len(instances.Backends),
-			minBackendsNumber))
+			common.MinBackendsNumber))
 	}
 
 	err = importRoleParams(&defaultParams.Backends, instances.Backends, common.RoleBackend)
@@ -327,6 +381,47 @@ func importClusterParamsFromClusterInstances(instances ClusterInstances) (defaul
 	return
 }
 
+// getInstancesSubnets returns the distinct subnets of the instances, the auto scaling group must span all of them for
+// the instances to be attached
+func getInstancesSubnets(instances []*ec2.Instance) (subnets []string) {
+	for _, instance := range instances {
+		subnets = appendSubnets(subnets, *instance.SubnetId)
+	}
+	return
+}
+
+func appendSubnets(subnets []string, newSubnets ...string) []string {
+	for _, subnet := range newSubnets {
+		if !strings2.AnyOf(subnet, subnets...) {
+			subnets = append(subnets, subnet)
+		}
+	}
+	return subnets
+}
+
+func validateSubnetsVpc(vpcId string, subnets []string) error {
+	for _, subnet := range subnets {
+		subnetVpcId, err := common.VpcBySubnet(subnet)
+		if err != nil {
+			return err
+		}
+		if subnetVpcId != vpcId {
+			return errors.New(fmt.Sprintf("subnet %s is not in cluster vpc %s", subnet, vpcId))
+		}
+	}
+	return nil
+}
+
+// addHostGroupsSubnets adds user given subnets to the hostgroups subnets, all of them must be in the cluster vpc
+func addHostGroupsSubnets(clusterSettings *db.ClusterSettings, subnets []string) error {
+	if err := validateSubnetsVpc(clusterSettings.VpcId, subnets); err != nil {
+		return err
+	}
+	clusterSettings.Backends.Subnets = appendSubnets(clusterSettings.Backends.Subnets, subnets...)
+	clusterSettings.Clients.Subnets = appendSubnets(clusterSettings.Clients.Subnets, subnets...)
+	return nil
+}
+
 func importRoleParams(hostGroupParams *common.HostGroupParams, instances []*ec2.Instance, role common.InstanceRole) error {
 	instance := instances[0]
 
@@ -342,6 +437,7 @@ func importRoleParams(hostGroupParams *common.HostGroupParams, instances []*ec2.
 	hostGroupParams.IamArn = *instance.IamInstanceProfile.Arn
 	hostGroupParams.InstanceType = *instance.InstanceType
 	hostGroupParams.Subnet = *instance.SubnetId
+	hostGroupParams.Subnets = getInstancesSubnets(instances)
 	hostGroupParams.VolumesInfo = volumeInfo
 	hostGroupParams.MaxSize = common.GetMaxSize(role, len(instances))
 	hostGroupParams.HttpTokens = *instance.MetadataOptions.HttpTokens
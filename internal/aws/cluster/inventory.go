@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/dist"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+// RegionsParams selects the regions of inventory commands, by default only the region wekactl was invoked with
+type RegionsParams struct {
+	AllRegions bool
+	Regions    []string
+	Parallel   int
+}
+
+func (p RegionsParams) multiRegion() bool {
+	return p.AllRegions || len(p.Regions) > 0
+}
+
+// regions returns the given regions, or every region wekactl lambdas are distributed to with AllRegions
+func (p RegionsParams) regions() (regions []string, err error) {
+	if len(p.Regions) > 0 {
+		return p.Regions, nil
+	}
+	if !p.AllRegions {
+		return []string{env.Config.Region}, nil
+	}
+	for region := range dist.LambdasSource {
+		regions = append(regions, region)
+	}
+	if len(regions) == 0 {
+		err = errors.New("no regions are known to this build, specify them with --regions")
+		return
+	}
+	sort.Strings(regions)
+	return
+}
+
+type regionRows struct {
+	rows [][]string
+	err  error
+}
+
+// renderRegionsTable collects the rows of the regions concurrently, at most Parallel regions at a time, and renders
+// them as one table. Failed regions are reported and don't abort the others.
+func renderRegionsTable(params RegionsParams, fields []string, getRows func(region string) ([][]string, error)) error {
+	regions, err := params.regions()
+	if err != nil {
+		return err
+	}
+	parallel := params.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]regionRows, len(regions))
+	semaphore := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			results[i].rows, results[i].err = getRows(region)
+		}(i, region)
+	}
+	wg.Wait()
+
+	if !params.multiRegion() {
+		if results[0].err != nil {
+			return results[0].err
+		}
+		common.RenderTable(fields, results[0].rows)
+		return nil
+	}
+
+	var data [][]string
+	var failedRegions []string
+	for i, region := range regions {
+		if results[i].err != nil {
+			logging.UserFailure("region %s: %v", region, results[i].err)
+			failedRegions = append(failedRegions, region)
+			continue
+		}
+		for _, row := range results[i].rows {
+			data = append(data, append([]string{region}, row...))
+		}
+	}
+	common.RenderTable(append([]string{"region"}, fields...), data)
+	if len(failedRegions) > 0 {
+		return errors.New(fmt.Sprintf("failed regions: %s", strings.Join(failedRegions, ", ")))
+	}
+	return nil
+}
@@ -46,6 +46,10 @@ func (k *KmsKey) TargetVersion() string {
 	return kmsVersion
 }
 
+func (k *KmsKey) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return kms.UpdateKMSKeyTags(k.ClusterName, tags.AsKms(), removedKeys)
+}
+
 func (k *KmsKey) Create(tags cluster.Tags) error {
 	kmsKey, err := kms.CreateKMSKey(tags.AsKms(), k.ResourceName())
 	if err != nil {
@@ -1,31 +1,33 @@
 package cluster
 
 import (
-	"github.com/aws/aws-sdk-go/aws"
-	"strconv"
+	"fmt"
+	"sort"
 	"strings"
 	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
 	"wekactl/internal/aws/dist"
 	"wekactl/internal/aws/iam"
 	"wekactl/internal/aws/lambdas"
 	"wekactl/internal/cluster"
 	strings2 "wekactl/internal/lib/strings"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/rs/zerolog/log"
 )
 
 type Lambda struct {
-	Arn                 string
-	TableName           string
-	Version             string
-	ASGName             string
-	Type                lambdas.LambdaType
-	Profile             IamProfile
-	VPCConfig           lambda.VpcConfig
-	HostGroupInfo       common.HostGroupInfo
-	Permissions         iam.PolicyDocument
-	UseDynamoDBEndpoint bool
+	Arn             string
+	TableName       string
+	Version         string
+	ASGName         string
+	Type            lambdas.LambdaType
+	Profile         IamProfile
+	VPCConfig       lambda.VpcConfig
+	HostGroupInfo   common.HostGroupInfo
+	Permissions     iam.PolicyDocument
+	ClusterSettings db.ClusterSettings
 }
 
 func (l *Lambda) Tags() cluster.Tags {
@@ -49,7 +51,7 @@ func (l *Lambda) Fetch() error {
 	l.Version = version
 
 	if l.Profile.Arn == "" {
-		profileArn, err := iam.GetIamRoleArn(l.HostGroupInfo.ClusterName, l.Profile.resourceNameBase())
+		profileArn, err := iam.GetIamRoleArn(l.Profile.rolesPath(), l.Profile.resourceNameBase())
 		if err != nil {
 			return err
 		}
@@ -76,6 +78,7 @@ func (l *Lambda) Init() {
 	l.Profile.TableName = l.TableName
 	l.Profile.AssumeRolePolicy = iam.GetLambdaAssumeRolePolicy()
 	l.Profile.HostGroupInfo = l.HostGroupInfo
+	l.Profile.ClusterSettings = l.ClusterSettings
 	l.Profile.Policy = l.Permissions
 	l.Profile.Init()
 }
@@ -88,9 +91,13 @@ func (l *Lambda) TargetVersion() string {
 	return dist.LambdasID
 }
 
+func (l *Lambda) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return lambdas.UpdateLambdaTags(l.ResourceName(), tags.AsStringRefs(), removedKeys)
+}
+
 func (l *Lambda) Create(tags cluster.Tags) (err error) {
 	functionConfiguration, err := lambdas.CreateLambda(
-		tags.AsStringRefs(), l.Type, l.ResourceName(), l.Profile.Arn, l.ASGName, l.TableName, l.HostGroupInfo, l.VPCConfig, l.UseDynamoDBEndpoint)
+		tags.AsStringRefs(), l.Type, l.ResourceName(), l.Profile.Arn, l.ASGName, l.TableName, l.HostGroupInfo, l.VPCConfig, l.ClusterSettings.RecordTarget)
 	if err != nil {
 		return
 	}
@@ -117,12 +124,9 @@ func (l *Lambda) Update(tags cluster.Tags) error {
 			return err
 		}
 	}
-	useDynamoDBEndpoint, err := strconv.ParseBool(*info.EnvironmentVariables["USE_DYNAMODB_ENDPOINT"])
-	if err != nil {
-		return err
-	}
-	if useDynamoDBEndpoint != l.UseDynamoDBEndpoint {
-		info.EnvironmentVariables["USE_DYNAMODB_ENDPOINT"] = aws.String(strconv.FormatBool(l.UseDynamoDBEndpoint))
+	// credentials are no longer passed from fetch to scale lambda, so this variable is obsolete
+	if _, ok := info.EnvironmentVariables["USE_DYNAMODB_ENDPOINT"]; ok {
+		delete(info.EnvironmentVariables, "USE_DYNAMODB_ENDPOINT")
 		err := lambdas.UpdateLambdaEnvironmentVariable(l.ResourceName(), info.EnvironmentVariables)
 		if err != nil {
 			return err
@@ -140,3 +144,98 @@ func (l *Lambda) Update(tags cluster.Tags) error {
 	}
 	return nil
 }
+
+func (l *Lambda) environmentVariables() map[string]*string {
+	return lambdas.GetLambdaEnvironmentVariables(l.Type, l.ASGName, l.TableName, l.HostGroupInfo, l.ClusterSettings.RecordTarget)
+}
+
+func (l *Lambda) Verify() (drifts []string, err error) {
+	configuration, err := lambdas.GetLambdaConfiguration(l.ResourceName())
+	if err != nil {
+		return
+	}
+
+	if aws.StringValue(configuration.Role) != l.Profile.Arn {
+		drifts = append(drifts, fmt.Sprintf("role is %s instead of %s", aws.StringValue(configuration.Role), l.Profile.Arn))
+	}
+	if aws.StringValue(configuration.Runtime) != string(lambdas.LambdaRuntimeDefault) {
+		drifts = append(drifts, fmt.Sprintf("runtime is %s instead of %s", aws.StringValue(configuration.Runtime), lambdas.LambdaRuntimeDefault))
+	}
+	if aws.StringValue(configuration.Handler) != lambdas.LambdaHandlerName {
+		drifts = append(drifts, fmt.Sprintf("handler is %s instead of %s", aws.StringValue(configuration.Handler), lambdas.LambdaHandlerName))
+	}
+	if len(configuration.Architectures) == 0 || *configuration.Architectures[0] != string(lambdas.LambdaArchDefault) {
+		drifts = append(drifts, fmt.Sprintf("architecture is not %s", lambdas.LambdaArchDefault))
+	}
+	if aws.Int64Value(configuration.MemorySize) != lambdas.LambdaMemorySize {
+		drifts = append(drifts, fmt.Sprintf("memory size is %d instead of %d", aws.Int64Value(configuration.MemorySize), lambdas.LambdaMemorySize))
+	}
+	if aws.Int64Value(configuration.Timeout) != lambdas.LambdaTimeout {
+		drifts = append(drifts, fmt.Sprintf("timeout is %d instead of %d", aws.Int64Value(configuration.Timeout), lambdas.LambdaTimeout))
+	}
+	if configuration.TracingConfig == nil || aws.StringValue(configuration.TracingConfig.Mode) != lambdas.LambdaTracingMode {
+		drifts = append(drifts, fmt.Sprintf("tracing mode is not %s", lambdas.LambdaTracingMode))
+	}
+
+	var liveVariables map[string]*string
+	if configuration.Environment != nil {
+		liveVariables = configuration.Environment.Variables
+	}
+	variables := l.environmentVariables()
+	var names []string
+	for name := range variables {
+		names = append(names, name)
+	}
+	for name := range liveVariables {
+		if _, ok := variables[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, ok := variables[name]
+		liveValue, liveOk := liveVariables[name]
+		switch {
+		case !liveOk:
+			drifts = append(drifts, fmt.Sprintf("environment variable %s is missing", name))
+		case !ok:
+			drifts = append(drifts, fmt.Sprintf("unexpected environment variable %s", name))
+		case aws.StringValue(liveValue) != aws.StringValue(value):
+			drifts = append(drifts, fmt.Sprintf("environment variable %s is %s instead of %s", name, aws.StringValue(liveValue), aws.StringValue(value)))
+		}
+	}
+
+	var liveSubnets, liveSecurityGroups []string
+	if configuration.VpcConfig != nil {
+		liveSubnets = strings2.RefListToList(configuration.VpcConfig.SubnetIds)
+		liveSecurityGroups = strings2.RefListToList(configuration.VpcConfig.SecurityGroupIds)
+	}
+	if !strings2.SameElements(liveSubnets, strings2.RefListToList(l.VPCConfig.SubnetIds)) {
+		drifts = append(drifts, fmt.Sprintf("vpc subnets are %v instead of %v", liveSubnets, strings2.RefListToList(l.VPCConfig.SubnetIds)))
+	}
+	if !strings2.SameElements(liveSecurityGroups, strings2.RefListToList(l.VPCConfig.SecurityGroupIds)) {
+		drifts = append(drifts, fmt.Sprintf("vpc security groups are %v instead of %v", liveSecurityGroups, strings2.RefListToList(l.VPCConfig.SecurityGroupIds)))
+	}
+	return
+}
+
+func (l *Lambda) Reconcile(tags cluster.Tags) error {
+	info, err := lambdas.GetLambdaRuntime(l.ResourceName())
+	if err != nil {
+		return err
+	}
+	if info.Runtime != lambdas.LambdaRuntimeDefault || info.HandlerName != lambdas.LambdaHandlerName {
+		err := lambdas.UpdateLambdaRuntime(l.ResourceName(), lambdas.LambdaRuntimeDefault, lambdas.LambdaHandlerName)
+		if err != nil {
+			return err
+		}
+	}
+	if info.Arch != lambdas.LambdaArchDefault {
+		err := lambdas.UpdateLambdaArchitecture(l.ResourceName(), lambdas.LambdaArchDefault)
+		if err != nil {
+			return err
+		}
+	}
+	return lambdas.UpdateLambdaConfiguration(
+		l.ResourceName(), l.Profile.Arn, l.environmentVariables(), l.VPCConfig)
+}
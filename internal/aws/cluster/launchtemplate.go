@@ -64,6 +64,21 @@ func (l *LaunchTemplate) TargetVersion() string {
 	return launchtemplate.LaunchtemplateVersion
 }
 
+// UpdateTags tags the launch template and creates a new version holding the new instances tags, keeping the deployed
+// version tag, so that cluster update still rolls the data of the deployed version
+func (l *LaunchTemplate) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	err := launchtemplate.UpdateLaunchTemplateTags(l.ResourceName(), tags.AsEc2(), removedKeys)
+	if err != nil {
+		return err
+	}
+	newVersion, err := launchtemplate.CreateTagsLaunchTemplateVersion(
+		l.ResourceName(), tags.Clone().Update(cluster.GetResourceVersionTag(l.Version)).AsEc2())
+	if err != nil {
+		return err
+	}
+	return launchtemplate.ModifyLaunchTemplateDefaultVersion(l.ResourceName(), newVersion)
+}
+
 func (l *LaunchTemplate) Create(tags cluster.Tags) error {
 	return launchtemplate.CreateLaunchTemplate(tags.AsEc2(), l.HostGroupInfo.Name, l.HostGroupParams, l.JoinApi.RestApiGateway, l.ResourceName(), !l.ClusterSettings.PrivateSubnet)
 }
@@ -77,6 +92,38 @@ func (l *LaunchTemplate) Update(tags cluster.Tags) error {
 	return launchtemplate.ModifyLaunchTemplateDefaultVersion(l.ResourceName(), newVersion)
 }
 
+// settingsHostGroupParams returns the hostgroup params saved in the cluster settings on import, hostgroups without
+// saved params are compared with the params read from their own launch template
+func (l *LaunchTemplate) settingsHostGroupParams() common.HostGroupParams {
+	params := l.ClusterSettings.Backends
+	if l.HostGroupInfo.Role == common.RoleClient {
+		params = l.ClusterSettings.Clients
+	}
+	if params.ImageID == "" {
+		return l.HostGroupParams
+	}
+	params.Subnet = l.HostGroupParams.Subnet
+	params.Subnets = l.HostGroupParams.Subnets
+	params.MaxSize = l.HostGroupParams.MaxSize
+	return params
+}
+
+func (l *LaunchTemplate) Verify() ([]string, error) {
+	return launchtemplate.GetLaunchTemplateDrift(
+		l.HostGroupInfo.Name, l.settingsHostGroupParams(), l.JoinApi.RestApiGateway, l.ResourceName(), !l.ClusterSettings.PrivateSubnet)
+}
+
+// Reconcile creates a new launch template version from the settings params, instances already running keep the
+// configuration they were launched with
+func (l *LaunchTemplate) Reconcile(tags cluster.Tags) error {
+	newVersion, err := launchtemplate.CreateNewLaunchTemplateVersion(
+		tags.AsEc2(), l.HostGroupInfo.Name, l.settingsHostGroupParams(), l.JoinApi.RestApiGateway, l.ResourceName(), !l.ClusterSettings.PrivateSubnet)
+	if err != nil {
+		return err
+	}
+	return launchtemplate.ModifyLaunchTemplateDefaultVersion(l.ResourceName(), newVersion)
+}
+
 func (l *LaunchTemplate) Init() {
 	log.Debug().Msgf("Initializing hostgroup %s launch template ...", string(l.HostGroupInfo.Name))
 	l.JoinApi.HostGroupInfo = l.HostGroupInfo
@@ -1,30 +1,40 @@
 package cluster
 
 import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"sort"
+	"strings"
+	"wekactl/internal/aws/alb"
+	autoscaling2 "wekactl/internal/aws/autoscaling"
 	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/aws/scalemachine"
+	"wekactl/internal/cluster"
 	"wekactl/internal/connectors"
 )
 
+const stackTemplateDescription = "[WekaIO] To learn more about this template visit https://docs.weka.io/install/aws/cloudformation"
+
 type Cluster struct {
 	stackId      string
 	stackName    string
 	creationTime string
 }
 
-func getStacks() ([]Cluster, error) {
-	svc := connectors.GetAWSSession().CF
-	input := &cloudformation.ListStacksInput{}
-
-	result, err := svc.ListStacks(input)
-	if err != nil {
-		return nil, err
-	} else {
-		var stacks []Cluster
-		for _, stack := range result.StackSummaries {
-			if *stack.TemplateDescription ==
-				"[WekaIO] To learn more about this template visit https://docs.weka.io/install/aws/cloudformation" &&
-				*stack.StackStatus == "CREATE_COMPLETE" {
+func getStacks(region string) (stacks []Cluster, err error) {
+	svc := connectors.GetRegionAWSSession(region).CF
+	err = svc.ListStacksPages(&cloudformation.ListStacksInput{
+		StackStatusFilter: []*string{
+			aws.String(cloudformation.StackStatusCreateComplete),
+		},
+	}, func(page *cloudformation.ListStacksOutput, lastPage bool) bool {
+		for _, stack := range page.StackSummaries {
+			if stack.TemplateDescription != nil && *stack.TemplateDescription == stackTemplateDescription {
 				stacks = append(stacks, Cluster{
 					stackId:      *stack.StackId,
 					stackName:    *stack.StackName,
@@ -32,28 +42,195 @@ func getStacks() ([]Cluster, error) {
 				})
 			}
 		}
-		return stacks, nil
-	}
+		return true
+	})
+	return
 }
 
-func RenderStacksTable(region string) {
+func stacksTableRows(region string) (data [][]string, err error) {
+	stacks, err := getStacks(region)
+	if err != nil {
+		return
+	}
+	clusterNames, err := db.GetClusterNames(region)
+	if err != nil {
+		return
+	}
+	imported := map[cluster.ClusterName]bool{}
+	for _, clusterName := range clusterNames {
+		imported[clusterName] = true
+	}
+
+	for _, stack := range stacks {
+		data = append(data, []string{
+			stack.stackName,
+			stack.creationTime,
+			fmt.Sprintf("%t", imported[cluster.ClusterName(stack.stackName)]),
+		})
+	}
+	return
+}
 
+// RenderStacksTable lists the weka CloudFormation stacks, the candidates for import
+func RenderStacksTable(params RegionsParams) error {
 	fields := []string{
 		"stackName",
 		"creationTime",
+		"imported",
+	}
+	return renderRegionsTable(params, fields, stacksTableRows)
+}
+
+// getAutoScalingGroupsByCluster returns the hostgroups auto scaling groups of the region by cluster name
+func getAutoScalingGroupsByCluster(region string) (autoScalingGroups map[cluster.ClusterName][]*autoscaling.Group, err error) {
+	svc := connectors.GetRegionAWSSession(region).ASG
+	autoScalingGroups = map[cluster.ClusterName][]*autoscaling.Group{}
+	err = svc.DescribeAutoScalingGroupsPages(&autoscaling.DescribeAutoScalingGroupsInput{},
+		func(page *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
+			for _, asg := range page.AutoScalingGroups {
+				clusterName := cluster.ClusterName(autoscaling2.GetAsgTagValue(asg, cluster.ClusterNameTagKey))
+				if clusterName != "" && autoscaling2.GetAsgTagValue(asg, HostGroupNameTagKey) != "" {
+					autoScalingGroups[clusterName] = append(autoScalingGroups[clusterName], asg)
+				}
+			}
+			return true
+		})
+	return
+}
+
+// hostGroupsSizes renders the hostgroups as name desired/actual, e.g. Backends 6/5
+func hostGroupsSizes(autoScalingGroups []*autoscaling.Group) string {
+	var sizes []string
+	for _, asg := range autoScalingGroups {
+		sizes = append(sizes, fmt.Sprintf("%s %d/%d",
+			autoscaling2.GetAsgTagValue(asg, HostGroupNameTagKey), *asg.DesiredCapacity, len(asg.Instances)))
+	}
+	sort.Strings(sizes)
+	return strings.Join(sizes, ", ")
+}
+
+func getApplicationLoadBalancerDns(region string, clusterName cluster.ClusterName, settings db.ClusterSettings) (dns string, err error) {
+	if settings.DnsAlias != "" {
+		return settings.DnsAlias, nil
+	}
+	dns, err = alb.GetRegionApplicationLoadBalancerDns(region, clusterName)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == elbv2.ErrCodeLoadBalancerNotFoundException {
+		return "", nil
+	}
+	return
+}
+
+func clustersTableRows(region string) (data [][]string, err error) {
+	clusterNames, err := db.GetClusterNames(region)
+	if err != nil {
+		return
+	}
+	autoScalingGroups, err := getAutoScalingGroupsByCluster(region)
+	if err != nil {
+		return
 	}
 
-	clusters, err := getStacks()
+	for _, clusterName := range clusterNames {
+		settings, err := db.GetRegionClusterSettings(region, clusterName)
+		if err != nil && err != db.NoItemFound {
+			return nil, err
+		}
+		dns, err := getApplicationLoadBalancerDns(region, clusterName, settings)
+		if err != nil {
+			return nil, err
+		}
+		importedFrom := "instances"
+		if settings.StackId != nil {
+			importedFrom = "stack"
+		}
+		data = append(data, []string{
+			string(clusterName),
+			settings.BuildVersion,
+			hostGroupsSizes(autoScalingGroups[clusterName]),
+			dns,
+			importedFrom,
+		})
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i][0] < data[j][0]
+	})
+	return
+}
+
+// RenderClustersTable lists the clusters managed by wekactl
+func RenderClustersTable(params RegionsParams) error {
+	fields := []string{
+		"name",
+		"buildVersion",
+		"hostgroups (desired/actual)",
+		"albDns",
+		"importedFrom",
+	}
+	return renderRegionsTable(params, fields, clustersTableRows)
+}
+
+func clustersStatusRows(region string) (data [][]string, err error) {
+	clusterNames, err := db.GetClusterNames(region)
 	if err != nil {
-		println(err.Error())
-	} else {
-		var data [][]string
-		for _, stack := range clusters {
+		return
+	}
+	autoScalingGroups, err := getAutoScalingGroupsByCluster(region)
+	if err != nil {
+		return
+	}
+
+	for _, clusterName := range clusterNames {
+		for _, asg := range autoScalingGroups[clusterName] {
+			hostGroupName := autoscaling2.GetAsgTagValue(asg, HostGroupNameTagKey)
+			inService, unhealthy := 0, 0
+			for _, instance := range asg.Instances {
+				if *instance.LifecycleState == autoscaling.LifecycleStateInService {
+					inService++
+				}
+				if *instance.HealthStatus != "Healthy" {
+					unhealthy++
+				}
+			}
+			lastScale, lastScaleTime := "", ""
+			execution, err := scalemachine.GetRegionLastExecution(
+				region, common.GenerateResourceName(clusterName, common.HostGroupName(hostGroupName)))
+			if err != nil {
+				return nil, err
+			}
+			if execution != nil {
+				lastScale = *execution.Status
+				lastScaleTime = execution.StartDate.Format("2006-01-02 15:04:05")
+			}
 			data = append(data, []string{
-				stack.stackName,
-				stack.creationTime,
+				string(clusterName),
+				hostGroupName,
+				fmt.Sprintf("%d", *asg.DesiredCapacity),
+				fmt.Sprintf("%d", inService),
+				fmt.Sprintf("%d", unhealthy),
+				lastScale,
+				lastScaleTime,
 			})
 		}
-		common.RenderTable(fields, data)
 	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i][0] != data[j][0] {
+			return data[i][0] < data[j][0]
+		}
+		return data[i][1] < data[j][1]
+	})
+	return
+}
+
+// RenderClustersStatus shows the hostgroups instances health and their last scale execution
+func RenderClustersStatus(params RegionsParams) error {
+	fields := []string{
+		"cluster",
+		"hostgroup",
+		"desired",
+		"inService",
+		"unhealthy",
+		"lastScale",
+		"lastScaleTime",
+	}
+	return renderRegionsTable(params, fields, clustersStatusRows)
 }
@@ -0,0 +1,143 @@
+package cluster
+
+import (
+	"github.com/rs/zerolog/log"
+	"wekactl/internal/aws/autoscaling"
+	"wekactl/internal/aws/cloudwatch"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/aws/iam"
+	"wekactl/internal/aws/lambdas"
+	"wekactl/internal/cluster"
+	"wekactl/internal/logging"
+)
+
+const metricsPublisherVersion = "v1"
+
+// noMetricsPublisherVersion is the version of hostgroups whose scaling policy isn't based on a weka metric
+const noMetricsPublisherVersion = "none"
+
+// MetricsPublisher is the cloudwatch event rule invoking the metrics lambda every minute, publishing weka storage
+// signals as custom metrics that scaling policies can be based on. The rule, the lambda and its role are deployed only
+// while the hostgroup autoscaling settings select a weka metric, and deleted once they don't.
+type MetricsPublisher struct {
+	HostGroupInfo   common.HostGroupInfo
+	HostGroupParams common.HostGroupParams
+	TableName       string
+	ASGName         string
+	Version         string
+	ClusterSettings db.ClusterSettings
+	Autoscaling     db.HostGroupAutoscaling
+	metrics         Lambda
+}
+
+func (m *MetricsPublisher) enabled() bool {
+	metric, err := autoscaling.GetScalingMetric(m.Autoscaling.Metric)
+	return err == nil && metric.IsWekaMetric()
+}
+
+func (m *MetricsPublisher) Tags() cluster.Tags {
+	return GetHostGroupResourceTags(m.HostGroupInfo, m.TargetVersion())
+}
+
+func (m *MetricsPublisher) SubResources() []cluster.Resource {
+	if !m.enabled() {
+		return []cluster.Resource{}
+	}
+	return []cluster.Resource{&m.metrics}
+}
+
+func (m *MetricsPublisher) ResourceName() string {
+	return common.GenerateResourceName(m.HostGroupInfo.ClusterName, m.HostGroupInfo.Name) + "-metrics"
+}
+
+func (m *MetricsPublisher) Fetch() error {
+	version, err := cloudwatch.GetCloudWatchEventRuleVersion(m.ResourceName())
+	if err != nil {
+		return err
+	}
+	m.Version = version
+
+	if m.enabled() && m.metrics.Arn == "" {
+		arn, err := lambdas.GetLambdaArn(m.metrics.ResourceName())
+		if err != nil {
+			return err
+		}
+		m.metrics.Arn = arn
+	}
+	return nil
+}
+
+func (m *MetricsPublisher) DeployedVersion() string {
+	if m.Version == "" && !m.enabled() {
+		return noMetricsPublisherVersion
+	}
+	return m.Version
+}
+
+func (m *MetricsPublisher) TargetVersion() string {
+	if !m.enabled() {
+		return noMetricsPublisherVersion
+	}
+	return metricsPublisherVersion
+}
+
+func (m *MetricsPublisher) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return cloudwatch.UpdateCloudWatchEventRuleTags(m.ResourceName(), tags.AsCloudWatch(), removedKeys)
+}
+
+func (m *MetricsPublisher) Create(tags cluster.Tags) error {
+	ruleArn, err := cloudwatch.CreateLambdaEventRule(tags.AsCloudWatch(), m.metrics.Arn, m.ResourceName())
+	if err != nil {
+		return err
+	}
+	return lambdas.AddEventRuleInvokePermission(m.metrics.ResourceName(), ruleArn)
+}
+
+// delete removes the rule, then the lambda and its role unless supplied on import, which aren't part of the resource
+// tree anymore
+func (m *MetricsPublisher) delete() error {
+	err := cloudwatch.DeleteCloudWatchEventRule(m.ResourceName())
+	if err != nil {
+		return err
+	}
+	err = lambdas.DeleteLambda(m.metrics.ResourceName())
+	if err != nil {
+		return err
+	}
+	if m.metrics.Profile.externalRoleArn() == "" {
+		err = iam.DeleteIamRole(m.metrics.Profile.rolesPath(), m.metrics.Profile.resourceNameBase())
+		if err != nil {
+			return err
+		}
+	}
+	logging.UserProgress("Hostgroup %s metrics publisher was removed", m.HostGroupInfo.Name)
+	return nil
+}
+
+func (m *MetricsPublisher) Update(tags cluster.Tags) error {
+	if !m.enabled() {
+		return m.delete()
+	}
+	ruleArn, err := cloudwatch.GetCloudWatchEventRuleArn(m.ResourceName())
+	if err != nil {
+		return err
+	}
+	err = cloudwatch.PutLambdaTarget(m.metrics.Arn, m.ResourceName())
+	if err != nil {
+		return err
+	}
+	return lambdas.AddEventRuleInvokePermission(m.metrics.ResourceName(), ruleArn)
+}
+
+func (m *MetricsPublisher) Init() {
+	log.Debug().Msgf("Initializing hostgroup %s metrics publisher ...", string(m.HostGroupInfo.Name))
+	m.metrics.TableName = m.TableName
+	m.metrics.ASGName = m.ASGName
+	m.metrics.HostGroupInfo = m.HostGroupInfo
+	m.metrics.ClusterSettings = m.ClusterSettings
+	m.metrics.Type = lambdas.LambdaMetrics
+	m.metrics.VPCConfig = lambdas.GetLambdaVpcConfig(m.HostGroupParams.Subnet, m.HostGroupParams.SecurityGroupsIds)
+	m.metrics.Permissions = iam.GetMetricsLambdaPolicy(policyScope(m.HostGroupInfo, m.TableName, m.ASGName), m.metrics.ResourceName())
+	m.metrics.Init()
+}
@@ -0,0 +1,427 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"strings"
+	"wekactl/internal/aws/alb"
+	"wekactl/internal/aws/autoscaling"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/aws/dist"
+	"wekactl/internal/aws/iam"
+	"wekactl/internal/aws/lambdas"
+	"wekactl/internal/cluster"
+	"wekactl/internal/logging"
+)
+
+type PreflightStatus string
+
+const (
+	PreflightPass PreflightStatus = "pass"
+	PreflightFail PreflightStatus = "fail"
+	// PreflightWarn is a check that couldn't be made, it doesn't fail the import
+	PreflightWarn PreflightStatus = "warn"
+)
+
+type PreflightCheck struct {
+	Name    string
+	Status  PreflightStatus
+	Details string
+}
+
+// importActions are the actions wekactl performs on import and update, the quota checks included, route53 is checked
+// only for dns aliases
+var importActions = []string{
+	"cloudformation:DescribeStacks",
+	"cloudformation:DescribeStackResources",
+	"cloudformation:ListStacks",
+	"ec2:DescribeInstances",
+	"ec2:DescribeSubnets",
+	"ec2:DescribeRouteTables",
+	"ec2:DescribeVpcEndpoints",
+	"ec2:CreateVpcEndpoint",
+	"ec2:ModifyVpcEndpoint",
+	"ec2:DescribeNetworkInterfaces",
+	"ec2:DescribeVolumes",
+	"ec2:ModifyInstanceAttribute",
+	"ec2:CreateTags",
+	"ec2:DeleteTags",
+	"ec2:CreateLaunchTemplate",
+	"ec2:CreateLaunchTemplateVersion",
+	"ec2:ModifyLaunchTemplate",
+	"ec2:DescribeLaunchTemplates",
+	"ec2:DescribeLaunchTemplateVersions",
+	"ec2:RunInstances",
+	"autoscaling:CreateAutoScalingGroup",
+	"autoscaling:UpdateAutoScalingGroup",
+	"autoscaling:DescribeAutoScalingGroups",
+	"autoscaling:AttachInstances",
+	"autoscaling:AttachLoadBalancers",
+	"autoscaling:AttachLoadBalancerTargetGroups",
+	"autoscaling:DetachInstances",
+	"autoscaling:SuspendProcesses",
+	"autoscaling:SetDesiredCapacity",
+	"autoscaling:CreateOrUpdateTags",
+	"autoscaling:DeleteTags",
+	"autoscaling:PutScalingPolicy",
+	"autoscaling:DeletePolicy",
+	"autoscaling:DescribePolicies",
+	"autoscaling:DescribeScalingActivities",
+	"autoscaling:DescribeAccountLimits",
+	"kms:CreateKey",
+	"kms:CreateAlias",
+	"kms:DescribeKey",
+	"kms:ListKeys",
+	"kms:ListResourceTags",
+	"kms:TagResource",
+	"kms:UntagResource",
+	"dynamodb:CreateTable",
+	"dynamodb:DescribeTable",
+	"dynamodb:ListTables",
+	"dynamodb:ListTagsOfResource",
+	"dynamodb:TagResource",
+	"dynamodb:UntagResource",
+	"dynamodb:GetItem",
+	"dynamodb:PutItem",
+	"dynamodb:UpdateItem",
+	"dynamodb:DeleteItem",
+	"iam:GetRole",
+	"iam:ListRoles",
+	"iam:ListRoleTags",
+	"iam:GetRolePolicy",
+	"iam:ListRolePolicies",
+	"iam:GetAccountSummary",
+	"iam:PassRole",
+	"lambda:CreateFunction",
+	"lambda:GetFunction",
+	"lambda:ListFunctions",
+	"lambda:UpdateFunctionCode",
+	"lambda:UpdateFunctionConfiguration",
+	"lambda:AddPermission",
+	"lambda:GetPolicy",
+	"lambda:ListTags",
+	"lambda:TagResource",
+	"lambda:UntagResource",
+	"lambda:GetAccountSettings",
+	"s3:GetObject",
+	"apigateway:GET",
+	"apigateway:POST",
+	"apigateway:PUT",
+	"apigateway:PATCH",
+	"apigateway:DELETE",
+	"states:CreateStateMachine",
+	"states:DescribeStateMachine",
+	"states:UpdateStateMachine",
+	"states:ListStateMachines",
+	"states:ListTagsForResource",
+	"states:TagResource",
+	"states:UntagResource",
+	"events:PutRule",
+	"events:DescribeRule",
+	"events:ListRules",
+	"events:PutTargets",
+	"events:ListTargetsByRule",
+	"events:RemoveTargets",
+	"events:ListTagsForResource",
+	"events:TagResource",
+	"events:UntagResource",
+	"cloudwatch:PutMetricAlarm",
+	"cloudwatch:DescribeAlarms",
+	"cloudwatch:DeleteAlarms",
+	"cloudwatch:TagResource",
+	"cloudwatch:UntagResource",
+	"elasticloadbalancing:CreateLoadBalancer",
+	"elasticloadbalancing:CreateTargetGroup",
+	"elasticloadbalancing:CreateListener",
+	"elasticloadbalancing:DescribeLoadBalancers",
+	"elasticloadbalancing:DescribeTargetGroups",
+	"elasticloadbalancing:DescribeListeners",
+	"elasticloadbalancing:DescribeTags",
+	"elasticloadbalancing:ModifyListener",
+	"elasticloadbalancing:ModifyTargetGroup",
+	"elasticloadbalancing:DeleteListener",
+	"elasticloadbalancing:AddTags",
+	"elasticloadbalancing:RemoveTags",
+	"elasticloadbalancing:DescribeAccountLimits",
+}
+
+// iamRolesActions manage the roles wekactl creates, they aren't needed when every role is supplied on import
+var iamRolesActions = []string{
+	"iam:CreateRole",
+	"iam:TagRole",
+	"iam:UntagRole",
+	"iam:PutRolePolicy",
+	"iam:DeleteRolePolicy",
+	"iam:UpdateAssumeRolePolicy",
+	"iam:PutRolePermissionsBoundary",
+	"iam:DeleteRolePermissionsBoundary",
+}
+
+// instanceRoleActions are performed by the launch template user data of new instances, creating their additional nics
+var instanceRoleActions = []string{
+	"ec2:CreateNetworkInterface",
+	"ec2:CreateTags",
+	"ec2:AttachNetworkInterface",
+	"ec2:ModifyNetworkInterfaceAttribute",
+}
+
+func checkInstanceRole(name, instanceProfileArn string) PreflightCheck {
+	check := PreflightCheck{Name: name + " instance role"}
+	roleArn, err := iam.GetInstanceProfileRoleArn(instanceProfileArn)
+	var denied []string
+	if err == nil {
+		denied, err = iam.GetDeniedActions(roleArn, instanceRoleActions)
+	}
+	switch {
+	case err != nil:
+		check.Status = PreflightWarn
+		check.Details = err.Error()
+	case len(denied) > 0:
+		check.Status = PreflightFail
+		check.Details = fmt.Sprintf("%s is not allowed: %s, new instances can't create their network interfaces", roleArn, strings.Join(denied, ", "))
+	default:
+		check.Status = PreflightPass
+		check.Details = roleArn
+	}
+	return check
+}
+
+// validateInstanceRoles refuses to roll out launch templates whose user data the hostgroups instance roles can't run,
+// the new instances would shut down without joining
+func validateInstanceRoles(awsCluster AWSCluster) error {
+	checked := make(map[string]bool)
+	for _, hostGroup := range awsCluster.HostGroups {
+		instanceProfileArn := hostGroup.HostGroupParams.IamArn
+		if instanceProfileArn == "" || checked[instanceProfileArn] {
+			continue
+		}
+		checked[instanceProfileArn] = true
+		check := checkInstanceRole(string(hostGroup.HostGroupInfo.Name), instanceProfileArn)
+		switch check.Status {
+		case PreflightFail:
+			return errors.New(fmt.Sprintf("%s: %s", check.Name, check.Details))
+		case PreflightWarn:
+			logging.UserWarning("%s couldn't be checked: %s", check.Name, check.Details)
+		}
+	}
+	return nil
+}
+
+func checkPermissions(params cluster.ImportParams, iamRoles map[string]string) PreflightCheck {
+	check := PreflightCheck{Name: "permissions"}
+	principalArn, err := iam.GetCallerPrincipalArn()
+	if err != nil {
+		check.Status = PreflightWarn
+		check.Details = err.Error()
+		return check
+	}
+	actions := append([]string{}, importActions...)
+	if params.DnsAlias != "" {
+		actions = append(actions, "route53:ListResourceRecordSets", "route53:ChangeResourceRecordSets")
+	}
+	if len(iamRoles) < len(IamRoleNames) {
+		actions = append(actions, iamRolesActions...)
+	}
+	if len(iamRoles) > 0 {
+		// the supplied roles are validated by simulating their policies
+		actions = append(actions, "iam:SimulatePrincipalPolicy")
+	}
+	denied, err := iam.GetDeniedActions(principalArn, actions)
+	if err != nil {
+		check.Status = PreflightWarn
+		check.Details = fmt.Sprintf("simulating %s policies failed: %s", principalArn, err.Error())
+		return check
+	}
+	if len(denied) > 0 {
+		check.Status = PreflightFail
+		check.Details = fmt.Sprintf("%s is not allowed: %s", principalArn, strings.Join(denied, ", "))
+		return check
+	}
+	check.Status = PreflightPass
+	check.Details = fmt.Sprintf("%s is allowed all %d actions", principalArn, len(actions))
+	return check
+}
+
+func checkExternalRoles(awsCluster AWSCluster, iamRoles map[string]string) PreflightCheck {
+	check := PreflightCheck{Name: "supplied iam roles", Status: PreflightPass, Details: fmt.Sprintf("%d roles are valid", len(iamRoles))}
+	if err := validateExternalRoles(&awsCluster); err != nil {
+		check.Status = PreflightFail
+		check.Details = err.Error()
+	}
+	return check
+}
+
+func checkLambdasPackage() PreflightCheck {
+	check := PreflightCheck{Name: "lambdas package"}
+	exists, err := lambdas.LambdaPackageExists(dist.LambdasID)
+	switch {
+	case err != nil:
+		check.Status = PreflightWarn
+		check.Details = err.Error()
+	case !exists:
+		check.Status = PreflightFail
+		check.Details = fmt.Sprintf("lambdas package %s is missing from the region bucket", dist.LambdasID)
+	default:
+		check.Status = PreflightPass
+		check.Details = dist.LambdasID
+	}
+	return check
+}
+
+func quotaCheck(name string, used, quota, needed int64, err error) PreflightCheck {
+	if err != nil {
+		return PreflightCheck{Name: name, Status: PreflightWarn, Details: err.Error()}
+	}
+	check := PreflightCheck{Name: name, Status: PreflightPass, Details: fmt.Sprintf("%d of %d used, %d more are needed", used, quota, needed)}
+	if used+needed > quota {
+		check.Status = PreflightFail
+	}
+	return check
+}
+
+// externalRolesCount counts the roles supplied on import, which aren't created
+func externalRolesCount(r cluster.Resource) (count int) {
+	if profile, ok := r.(*IamProfile); ok && profile.externalRoleArn() != "" {
+		count++
+	}
+	for _, subResource := range r.SubResources() {
+		count += externalRolesCount(subResource)
+	}
+	return
+}
+
+// checkQuotas compares the free quotas with the resources the import creates, counted on the generated cluster
+func checkQuotas(awsCluster AWSCluster) (checks []PreflightCheck) {
+	counts := map[string]int{}
+	cluster.CountResources(&awsCluster, counts)
+	log.Debug().Msgf("cluster %s resources: %v", awsCluster.Name, counts)
+
+	used, quota, err := iam.GetRolesQuota()
+	roles := counts["IamProfile"] - externalRolesCount(&awsCluster)
+	checks = append(checks, quotaCheck("iam roles quota", used, quota, int64(roles), err))
+
+	used, quota, err = alb.GetApplicationLoadBalancersQuota()
+	checks = append(checks, quotaCheck("application load balancers quota", used, quota, int64(counts["ApplicationLoadBalancer"]), err))
+
+	used, quota, err = autoscaling.GetAutoScalingGroupsQuota()
+	checks = append(checks, quotaCheck("auto scaling groups quota", used, quota, int64(counts["AutoscalingGroup"]), err))
+
+	packageSize, err := lambdas.GetLambdaPackageSize(dist.LambdasID)
+	if err == nil {
+		used, quota, err = lambdas.GetCodeStorageQuota()
+	}
+	check := quotaCheck("lambda code storage quota", used, quota, int64(counts["Lambda"])*packageSize, err)
+	if err == nil {
+		check.Details = fmt.Sprintf("%d of %d MB used, %d MB more are needed", used>>20, quota>>20, (int64(counts["Lambda"])*packageSize)>>20)
+	}
+	checks = append(checks, check)
+	return
+}
+
+// checkNetwork checks the vpc and instance role prerequisites of the cluster instances, the import fails halfway
+// without them
+func checkNetwork(params cluster.ImportParams) (checks []PreflightCheck) {
+	instancesCheck := PreflightCheck{Name: "cluster instances", Status: PreflightFail}
+	var clusterInstances ClusterInstances
+	var err error
+	if len(params.InstanceIds) == 0 {
+		clusterInstances, err = GetStackInstancesInfo(params.Name)
+	} else {
+		clusterInstances, err = instanceIdsToClusterInstances(params.InstanceIds)
+	}
+	var settings db.ClusterSettings
+	if err == nil {
+		settings, err = importClusterParamsFromClusterInstances(clusterInstances)
+	}
+	var vpcId string
+	if err == nil {
+		vpcId, err = common.VpcBySubnet(settings.Subnet)
+	}
+	if err != nil {
+		instancesCheck.Details = err.Error()
+		return []PreflightCheck{instancesCheck}
+	}
+	instancesCheck.Status = PreflightPass
+	instancesCheck.Details = fmt.Sprintf("%d backends in subnet %s of vpc %s", len(clusterInstances.Backends), settings.Subnet, vpcId)
+	checks = append(checks, instancesCheck)
+	checks = append(checks, checkInstanceRole("backends", settings.Backends.IamArn))
+	if settings.Clients.IamArn != settings.Backends.IamArn {
+		checks = append(checks, checkInstanceRole("clients", settings.Clients.IamArn))
+	}
+
+	subnetCheck := PreflightCheck{Name: "additional alb subnet", Status: PreflightPass, Details: params.AdditionalAlbSubnet}
+	if params.AdditionalAlbSubnet == "" {
+		subnetCheck.Details, err = common.GetAdditionalVpcSubnet(vpcId, settings.Subnet)
+		if err != nil {
+			subnetCheck.Status = PreflightFail
+			subnetCheck.Details = err.Error()
+			if err == common.NoAdditionalSubnet {
+				subnetCheck.Details += ", supply additional ALB subnet via --additional-alb-subnet"
+			}
+		}
+	}
+	checks = append(checks, subnetCheck)
+
+	if params.PrivateSubnet {
+		endpointCheck := PreflightCheck{Name: "execute-api vpc endpoint", Status: PreflightPass}
+		endpoints, err := common.GetExecuteApiEndpoints(vpcId)
+		switch {
+		case err != nil:
+			endpointCheck.Status = PreflightWarn
+			endpointCheck.Details = err.Error()
+		case len(endpoints) == 0:
+			endpointCheck.Status = PreflightFail
+			endpointCheck.Details = fmt.Sprintf("vpc %s has no available execute-api endpoint, private join api can't be reached", vpcId)
+		default:
+			endpointCheck.Details = *endpoints[0].VpcEndpointId
+		}
+		checks = append(checks, endpointCheck)
+	}
+	return
+}
+
+// PreflightCluster checks the caller permissions, the account quotas and the vpc prerequisites of importing the
+// cluster, without creating anything
+func PreflightCluster(params cluster.ImportParams) (checks []PreflightCheck) {
+	name := cluster.ClusterName(params.Name)
+	iamRoles, err := ParseIamRoles(params.IamRoles)
+	if err != nil {
+		checks = append(checks, PreflightCheck{Name: "iam roles", Status: PreflightFail, Details: err.Error()})
+	}
+	checks = append(checks, checkPermissions(params, iamRoles))
+	checks = append(checks, checkLambdasPackage())
+
+	awsCluster := generateAWSCluster(params.Name, db.GetTableName(name), db.ClusterSettings{IamRoles: iamRoles}, false)
+	awsCluster.Init()
+	if len(iamRoles) > 0 {
+		checks = append(checks, checkExternalRoles(awsCluster, iamRoles))
+	}
+	checks = append(checks, checkQuotas(awsCluster)...)
+	checks = append(checks, checkNetwork(params)...)
+	return
+}
+
+func RenderPreflightChecks(checks []PreflightCheck) {
+	fields := []string{"check", "status", "details"}
+	var data [][]string
+	for _, check := range checks {
+		data = append(data, []string{check.Name, strings.ToUpper(string(check.Status)), check.Details})
+	}
+	common.RenderTable(fields, data)
+}
+
+// PreflightError is returned when any of the checks failed, warnings are ignored
+func PreflightError(checks []PreflightCheck) error {
+	var failed []string
+	for _, check := range checks {
+		if check.Status == PreflightFail {
+			failed = append(failed, check.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.New(fmt.Sprintf("preflight checks failed: %s", strings.Join(failed, ", ")))
+}
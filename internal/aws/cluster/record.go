@@ -0,0 +1,69 @@
+package cluster
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/aws/lambdas"
+	"wekactl/internal/aws/lambdas/scale_down"
+	"wekactl/internal/cluster"
+	"wekactl/internal/logging"
+)
+
+// RecordTargetOff disables the scale lambdas recording
+const RecordTargetOff = "off"
+
+// SetRecordTarget saves the cluster record target and applies it to every hostgroup scale lambda, cluster update and
+// verify keep it from the saved settings
+func SetRecordTarget(name cluster.ClusterName, target string) error {
+	if target == RecordTargetOff {
+		target = ""
+	} else if _, _, err := scale_down.ParseRecordTarget(target); err != nil {
+		return err
+	}
+
+	clusterSettings, err := db.GetClusterSettings(name)
+	if err != nil {
+		return err
+	}
+	// the scale role policy is regenerated, which an older wekactl must not do
+	err = checkDowngrade(clusterSettings, false)
+	if err != nil {
+		return err
+	}
+	clusterSettings.RecordTarget = target
+	err = db.SaveClusterSettings(db.GetTableName(name), clusterSettings)
+	if err != nil {
+		return err
+	}
+
+	awsCluster, err := GetCluster(name, false)
+	if err != nil {
+		return err
+	}
+	for _, hostGroup := range awsCluster.HostGroups {
+		scale := &hostGroup.AutoscalingGroup.ScaleMachineCloudWatch.ScaleMachine.scale
+		err = cluster.EnsureResource(&scale.Profile, awsCluster.ClusterSettings, false)
+		if err != nil {
+			return err
+		}
+		info, err := lambdas.GetLambdaRuntime(scale.ResourceName())
+		if err != nil {
+			return err
+		}
+		variables := info.EnvironmentVariables
+		if variables == nil {
+			variables = map[string]*string{}
+		}
+		if target == "" {
+			delete(variables, scale_down.RecordTargetEnv)
+		} else {
+			variables[scale_down.RecordTargetEnv] = aws.String(target)
+		}
+		err = lambdas.UpdateLambdaEnvironmentVariable(scale.ResourceName(), variables)
+		if err != nil {
+			return err
+		}
+		logging.UserProgress("Hostgroup %s scale lambda record target was updated", hostGroup.HostGroupInfo.Name)
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"wekactl/internal/aws/apigateway"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/aws/iam"
+	"wekactl/internal/aws/launchtemplate"
+	"wekactl/internal/aws/scalemachine"
+	"wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/lib/diff"
+	strings2 "wekactl/internal/lib/strings"
+	"wekactl/internal/logging"
+)
+
+// placeholders of the values that are only known once the cluster is deployed
+const (
+	RenderAccountId = "<account-id>"
+	RenderRestApiId = "<rest-api-id>"
+	RenderApiKey    = "<api-key>"
+)
+
+const renderDiffContext = 3
+
+// Artifact is a document wekactl generates and deploys, Path is relative to the render output directory
+type Artifact struct {
+	Path    string
+	Content string
+	// fetchLive returns the deployed document, or an empty string when it wasn't deployed. Values other than the
+	// account id that the render can't know are replaced by their placeholders. Documents wekactl doesn't own have none
+	fetchLive func(accountId string) (string, error)
+}
+
+// renderJson indents json documents, keys are sorted so that the rendered and deployed documents compare line by line,
+// and the placeholders are kept unescaped
+func renderJson(document string) string {
+	var value interface{}
+	if json.Unmarshal([]byte(document), &value) != nil {
+		return document
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if encoder.Encode(value) != nil {
+		return document
+	}
+	return buffer.String()
+}
+
+func hostGroupParamsFromFile(hostGroup ClusterFileHostGroup) (params common.HostGroupParams) {
+	params = common.HostGroupParams{
+		SecurityGroupsIds: strings2.ListToRefList(hostGroup.SecurityGroupsIds),
+		ImageID:           hostGroup.ImageId,
+		KeyName:           hostGroup.KeyName,
+		IamArn:            hostGroup.IamArn,
+		InstanceType:      hostGroup.InstanceType,
+		Subnets:           hostGroup.Subnets,
+		MaxSize:           hostGroup.MaxSize,
+		HttpTokens:        hostGroup.HttpTokens,
+	}
+	if len(hostGroup.Subnets) > 0 {
+		params.Subnet = hostGroup.Subnets[0]
+	}
+	for _, volume := range hostGroup.Volumes {
+		params.VolumesInfo = append(params.VolumesInfo, common.VolumeInfo{
+			Name: volume.Name,
+			Type: volume.Type,
+			Size: volume.Size,
+		})
+	}
+	return
+}
+
+// clusterFromFile generates the cluster resources of the cluster file, settings the file doesn't hold are taken from
+// the given settings
+func clusterFromFile(clusterFile ClusterFile, settings db.ClusterSettings) (awsCluster AWSCluster, err error) {
+	if clusterFile.Version != ClusterFileVersion {
+		err = errors.New(fmt.Sprintf("unsupported cluster file version %q, supported version: %s", clusterFile.Version, ClusterFileVersion))
+		return
+	}
+	if clusterFile.Name == "" {
+		err = errors.New("cluster file has no name")
+		return
+	}
+	if clusterFile.Region == "" {
+		err = errors.New("cluster file has no region")
+		return
+	}
+	err = cluster.ValidateTags(clusterFile.Tags)
+	if err != nil {
+		return
+	}
+	// the generated documents embed the region, and the cluster is looked for in it
+	env.Config.Region = clusterFile.Region
+
+	settings.VpcId = clusterFile.Network.VpcId
+	settings.Subnet = clusterFile.Network.Subnet
+	settings.AdditionalSubnet = clusterFile.Network.AdditionalSubnet
+	settings.PrivateSubnet = clusterFile.Network.PrivateSubnet
+	settings.UseDynamoDBEndpoint = clusterFile.Network.UseDynamoDBEndpoint
+	settings.DnsAlias = clusterFile.Dns.Alias
+	settings.DnsZoneId = clusterFile.Dns.ZoneId
+	settings.FailureDomain = common.FailureDomainStrategy(clusterFile.FailureDomain.Strategy)
+	settings.FailureDomainCmd = clusterFile.FailureDomain.Command
+	settings.TagsMap = cluster.Tags{}.Update(clusterFile.Tags)
+
+	var names []string
+	for name := range clusterFile.HostGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	name := cluster.ClusterName(clusterFile.Name)
+	var hostGroups []HostGroup
+	for _, hostGroupName := range names {
+		hostGroup := clusterFile.HostGroups[hostGroupName]
+		params := hostGroupParamsFromFile(hostGroup)
+		role := common.InstanceRole(hostGroup.Role)
+		if role == common.RoleBackend {
+			settings.Backends = params
+		}
+		generated := GenerateHostGroup(name, params, role, common.HostGroupName(hostGroupName))
+		if hostGroup.Autoscaling != nil {
+			var autoscaleParams AutoscaleParams
+			autoscaleParams, err = autoscaleParamsFromFile(generated, *hostGroup.Autoscaling)
+			if err != nil {
+				return
+			}
+			generated.Autoscaling = autoscaleParams.settings()
+		}
+		hostGroups = append(hostGroups, generated)
+	}
+
+	awsCluster = AWSCluster{
+		Name:            name,
+		ClusterSettings: settings,
+		HostGroups:      hostGroups,
+		TableName:       db.GetTableName(name),
+	}
+	awsCluster.Init()
+	return
+}
+
+func lambdaArnPlaceholder(lambda Lambda) string {
+	return fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s", env.Config.Region, RenderAccountId, lambda.ResourceName())
+}
+
+// iamProfileArtifacts renders the role trust and inline policies, roles supplied on import are rendered as well, with
+// the documents they must allow
+func iamProfileArtifacts(profile *IamProfile) (artifacts []Artifact) {
+	dir := filepath.Join(string(profile.HostGroupInfo.Name), "iam")
+	fetchDocuments := func() (assumeRolePolicy, policy string, err error) {
+		roleName, err := iam.GetIamRoleName(profile.rolesPath(), profile.resourceNameBase())
+		if err != nil || roleName == "" {
+			return
+		}
+		return iam.GetRolePolicyDocuments(roleName, profile.PolicyName)
+	}
+	external := profile.externalRoleArn() != ""
+
+	assumeRoleArtifact := Artifact{
+		Path:    filepath.Join(dir, fmt.Sprintf("%s-assume-role-policy.json", profile.Name)),
+		Content: renderJson(profile.AssumeRolePolicy.String()),
+	}
+	if !external {
+		assumeRoleArtifact.fetchLive = func(accountId string) (string, error) {
+			assumeRolePolicy, _, err := fetchDocuments()
+			return assumeRolePolicy, err
+		}
+	}
+	artifacts = append(artifacts, assumeRoleArtifact)
+
+	// roles without permissions, e.g. the transient lambda one, have no inline policy
+	if profile.Policy.Version != "" {
+		policyArtifact := Artifact{
+			Path:    filepath.Join(dir, fmt.Sprintf("%s-policy.json", profile.Name)),
+			Content: renderJson(profile.Policy.String()),
+		}
+		if !external {
+			policyArtifact.fetchLive = func(accountId string) (string, error) {
+				_, policy, err := fetchDocuments()
+				return policy, err
+			}
+		}
+		artifacts = append(artifacts, policyArtifact)
+	}
+	return
+}
+
+func scaleMachineArtifact(s *ScaleMachine) (artifact Artifact, err error) {
+	definition, err := scalemachine.GetStateMachineDefinition(scalemachine.StateMachineLambdasArn{
+		Fetch:     lambdaArnPlaceholder(s.fetch),
+		Scale:     lambdaArnPlaceholder(s.scale),
+		Terminate: lambdaArnPlaceholder(s.terminate),
+		Transient: lambdaArnPlaceholder(s.transient),
+	})
+	if err != nil {
+		return
+	}
+	artifact = Artifact{
+		Path:    filepath.Join(string(s.HostGroupInfo.Name), "state-machine.json"),
+		Content: renderJson(definition),
+		fetchLive: func(accountId string) (string, error) {
+			arn, err := scalemachine.GetStateMachineArn(s.ResourceName())
+			if err != nil {
+				return "", err
+			}
+			stateMachine, err := scalemachine.DescribeStateMachine(arn)
+			if err != nil {
+				if aerr, ok := err.(awserr.Error); ok && aerr.Code() == sfn.ErrCodeStateMachineDoesNotExist {
+					return "", nil
+				}
+				return "", err
+			}
+			return aws.StringValue(stateMachine.Definition), nil
+		},
+	}
+	return
+}
+
+func launchTemplateArtifact(l *LaunchTemplate, settings db.ClusterSettings) Artifact {
+	restApiGateway := apigateway.RestApiGateway{
+		Id:     RenderRestApiId,
+		Name:   l.JoinApi.ResourceName(),
+		ApiKey: RenderApiKey,
+	}
+	tags := l.Tags().Update(settings.Tags())
+	return Artifact{
+		Path:    filepath.Join(string(l.HostGroupInfo.Name), "user-data.sh"),
+		Content: launchtemplate.GetUserData(restApiGateway, l.HostGroupParams.InstanceType, l.HostGroupParams.SecurityGroupsIds, tags.AsEc2()),
+		fetchLive: func(accountId string) (string, error) {
+			launchTemplateVersion, err := launchtemplate.GetLatestLaunchTemplateVersion(l.ResourceName())
+			if err != nil {
+				if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "InvalidLaunchTemplateName.NotFoundException" {
+					return "", nil
+				}
+				return "", err
+			}
+			userData, err := common.DecodeBase64(aws.StringValue(launchTemplateVersion.LaunchTemplateData.UserData))
+			if err != nil {
+				return "", err
+			}
+			liveRestApiGateway, err := apigateway.GetRestApiGateway(l.JoinApi.ResourceName())
+			if err != nil {
+				return "", err
+			}
+			return strings.NewReplacer(
+				liveRestApiGateway.Id, RenderRestApiId,
+				liveRestApiGateway.ApiKey, RenderApiKey,
+			).Replace(userData), nil
+		},
+	}
+}
+
+func apiGatewayArtifact(a *ApiGateway) Artifact {
+	return Artifact{
+		Path:    filepath.Join(string(a.HostGroupInfo.Name), "api-gateway-policy.json"),
+		Content: renderJson(apigateway.GetRestApiPolicy(a.ClusterSettings.VpcId)),
+		fetchLive: func(accountId string) (string, error) {
+			restApiGateway, err := apigateway.GetRestApiGateway(a.ResourceName())
+			if err != nil {
+				return "", err
+			}
+			policy, err := apigateway.GetRestApiGatewayPolicy(a.ResourceName())
+			if err != nil {
+				return "", err
+			}
+			// api gateway expands the "*" resources to the api arn
+			apiArn := fmt.Sprintf("arn:aws:execute-api:%s:%s:%s/*", env.Config.Region, accountId, restApiGateway.Id)
+			return strings.ReplaceAll(policy, fmt.Sprintf("%q", apiArn), `"*"`), nil
+		},
+	}
+}
+
+func collectArtifacts(r cluster.Resource, settings db.ClusterSettings, artifacts *[]Artifact) (err error) {
+	switch resource := r.(type) {
+	case *IamProfile:
+		*artifacts = append(*artifacts, iamProfileArtifacts(resource)...)
+	case *ScaleMachine:
+		artifact, err := scaleMachineArtifact(resource)
+		if err != nil {
+			return err
+		}
+		*artifacts = append(*artifacts, artifact)
+	case *LaunchTemplate:
+		*artifacts = append(*artifacts, launchTemplateArtifact(resource, settings))
+	case *ApiGateway:
+		// public join apis have no resource policy
+		if resource.ClusterSettings.PrivateSubnet {
+			*artifacts = append(*artifacts, apiGatewayArtifact(resource))
+		}
+	}
+	for _, subResource := range r.SubResources() {
+		err = collectArtifacts(subResource, settings, artifacts)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func renderClusterFile(path string, settings db.ClusterSettings) (artifacts []Artifact, err error) {
+	clusterFile, err := ReadClusterFile(path)
+	if err != nil {
+		return
+	}
+	awsCluster, err := clusterFromFile(clusterFile, settings)
+	if err != nil {
+		return
+	}
+	err = collectArtifacts(&awsCluster, awsCluster.ClusterSettings, &artifacts)
+	return
+}
+
+// RenderCluster writes the IAM policies, state machine definitions, launch templates user data and join apis resource
+// policies of the cluster file to outDir, without any AWS call. Values that are only known once the cluster is
+// deployed are replaced by placeholders
+func RenderCluster(path, outDir string) error {
+	artifacts, err := renderClusterFile(path, db.ClusterSettings{})
+	if err != nil {
+		return err
+	}
+	for _, artifact := range artifacts {
+		artifactPath := filepath.Join(outDir, artifact.Path)
+		err = os.MkdirAll(filepath.Dir(artifactPath), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(artifactPath, []byte(artifact.Content), 0644)
+		if err != nil {
+			return err
+		}
+		logging.UserProgress("Rendered %s", artifactPath)
+	}
+	return nil
+}
+
+// DiffCluster compares the rendered artifacts of the cluster file with the deployed ones, returning the number of
+// artifacts that differ. The IAM roles settings, which the cluster file doesn't hold, are read from the deployed cluster
+func DiffCluster(path string) (differences int, err error) {
+	clusterFile, err := ReadClusterFile(path)
+	if err != nil {
+		return
+	}
+	if clusterFile.Region != "" {
+		env.Config.Region = clusterFile.Region
+	}
+	settings, err := db.GetClusterSettings(cluster.ClusterName(clusterFile.Name))
+	if err != nil {
+		return
+	}
+	artifacts, err := renderClusterFile(path, db.ClusterSettings{
+		IamRolesPath:           settings.IamRolesPath,
+		IamRolesNamePrefix:     settings.IamRolesNamePrefix,
+		IamPermissionsBoundary: settings.IamPermissionsBoundary,
+		IamRoles:               settings.IamRoles,
+	})
+	if err != nil {
+		return
+	}
+
+	accountId, err := common.GetAccountId()
+	if err != nil {
+		return
+	}
+	for _, artifact := range artifacts {
+		if artifact.fetchLive == nil {
+			logging.UserInfo("%s is not compared, its role is externally owned, see cluster verify", artifact.Path)
+			continue
+		}
+		var live string
+		live, err = artifact.fetchLive(accountId)
+		if err != nil {
+			err = errors.New(fmt.Sprintf("failed fetching deployed %s: %v", artifact.Path, err))
+			return
+		}
+		if live == "" {
+			differences++
+			logging.UserInfo("%s is not deployed", artifact.Path)
+			continue
+		}
+		live = strings.ReplaceAll(live, accountId, RenderAccountId)
+		if strings.HasSuffix(artifact.Path, ".json") {
+			live = renderJson(live)
+		}
+		lines := diff.Lines(live, artifact.Content, renderDiffContext)
+		if lines == "" {
+			continue
+		}
+		differences++
+		fmt.Printf("--- deployed %s\n+++ rendered %s\n%s\n", artifact.Path, artifact.Path, lines)
+	}
+	return
+}
@@ -0,0 +1,270 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	errors2 "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"time"
+	"wekactl/internal/aws/autoscaling"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/cluster"
+	strings2 "wekactl/internal/lib/strings"
+	"wekactl/internal/logging"
+)
+
+const (
+	rollPhaseLaunch = "launch"
+	rollPhaseRemove = "remove"
+)
+
+type RollParams struct {
+	Name          cluster.ClusterName
+	HostGroupName common.HostGroupName
+	InstanceType  string
+	ImageID       string
+	BatchSize     int64
+	Timeout       time.Duration
+}
+
+func getHostGroup(awsCluster AWSCluster, name common.HostGroupName) (hostGroup HostGroup, err error) {
+	for _, hostGroup = range awsCluster.HostGroups {
+		if hostGroup.HostGroupInfo.Name == name {
+			return
+		}
+	}
+	err = errors.New(fmt.Sprintf("hostgroup %s was not found in cluster %s", name, awsCluster.Name))
+	return
+}
+
+func instanceIdsIntersection(instanceIds, otherInstanceIds []string) (intersection []string) {
+	for _, instanceId := range instanceIds {
+		if strings2.AnyOf(instanceId, otherInstanceIds...) {
+			intersection = append(intersection, instanceId)
+		}
+	}
+	return
+}
+
+func startRoll(awsCluster AWSCluster, hostGroup *HostGroup, params RollParams) (roll db.HostGroupRoll, err error) {
+	if params.InstanceType == "" && params.ImageID == "" {
+		err = errors.New("instance type or ami must be given")
+		return
+	}
+	if params.BatchSize < 1 {
+		err = errors.New("batch size must be positive")
+		return
+	}
+
+	asgResource := &hostGroup.AutoscalingGroup
+	launchTemplate := &asgResource.LaunchTemplate
+	for _, resource := range []cluster.Resource{asgResource, launchTemplate} {
+		err = resource.Fetch()
+		if err != nil {
+			return
+		}
+		if resource.DeployedVersion() != resource.TargetVersion() {
+			err = errors.New(fmt.Sprintf("hostgroup %s resources are outdated, please run cluster update first", hostGroup.HostGroupInfo.Name))
+			return
+		}
+	}
+
+	if params.InstanceType != "" {
+		if _, ok := common.GetBackendCoreCounts()[params.InstanceType]; !ok && hostGroup.HostGroupInfo.Role == common.RoleBackend {
+			err = errors.New(fmt.Sprintf("instance type %s is not supported for backends", params.InstanceType))
+			return
+		}
+		launchTemplate.HostGroupParams.InstanceType = params.InstanceType
+	}
+	if params.ImageID != "" {
+		launchTemplate.HostGroupParams.ImageID = params.ImageID
+	}
+
+	asg, err := autoscaling.GetAutoScalingGroup(asgResource.ResourceName())
+	if err != nil {
+		return
+	}
+	if int64(len(asg.Instances)) != *asg.DesiredCapacity {
+		err = errors.New(fmt.Sprintf(
+			"hostgroup %s is scaling (%d instances, desired capacity %d), please retry when it is stable",
+			hostGroup.HostGroupInfo.Name, len(asg.Instances), *asg.DesiredCapacity))
+		return
+	}
+	if *asg.DesiredCapacity+params.BatchSize > *asg.MaxSize {
+		err = errors.New(fmt.Sprintf("hostgroup %s can't grow by %d instances, its max size is %d", hostGroup.HostGroupInfo.Name, params.BatchSize, *asg.MaxSize))
+		return
+	}
+
+	roll = db.HostGroupRoll{
+		InstanceType:   launchTemplate.HostGroupParams.InstanceType,
+		ImageID:        launchTemplate.HostGroupParams.ImageID,
+		BatchSize:      params.BatchSize,
+		Capacity:       *asg.DesiredCapacity,
+		OldInstanceIds: strings2.RefListToList(common.UnpackASGInstanceIds(asg.Instances)),
+		Phase:          rollPhaseLaunch,
+	}
+
+	err = launchTemplate.Update(launchTemplate.Tags().Update(awsCluster.ClusterSettings.Tags()))
+	if err != nil {
+		return
+	}
+	// making sure the auto scaling group launches the new launch template version
+	err = asgResource.Update(asgResource.Tags().Update(awsCluster.ClusterSettings.Tags()))
+	if err != nil {
+		return
+	}
+	logging.UserProgress("New launch template version was created (instance type: %s, ami: %s)", roll.InstanceType, roll.ImageID)
+
+	err = db.SaveHostGroupRoll(db.GetTableName(awsCluster.Name), string(hostGroup.HostGroupInfo.Name), roll)
+	return
+}
+
+func rollLaunchCheck(ctx context.Context, api wekaApi, asgName string, roll db.HostGroupRoll, target int64) stepCheck {
+	return func() (done bool, progress string, err error) {
+		asg, err := autoscaling.GetAutoScalingGroup(asgName)
+		if err != nil {
+			return
+		}
+		instanceIds := strings2.RefListToList(common.UnpackASGInstanceIds(asg.Instances))
+		var newInstanceIds []string
+		for _, instanceId := range instanceIds {
+			if !strings2.AnyOf(instanceId, roll.OldInstanceIds...) {
+				newInstanceIds = append(newInstanceIds, instanceId)
+			}
+		}
+		expected := int(target) - len(instanceIdsIntersection(roll.OldInstanceIds, instanceIds))
+
+		hosts, hostsErr := api.hostsList(ctx)
+		if hostsErr != nil {
+			log.Warn().Msgf("failed getting weka hosts: %s", hostsErr)
+		}
+		active := instanceIdsIntersection(newInstanceIds, activeInstanceIds(hosts))
+		progress = fmt.Sprintf("%d/%d new instances are active weka backends", len(active), expected)
+		if len(active) < expected {
+			return
+		}
+		if !wekaProtected(ctx, api) {
+			progress = "waiting for weka data to be fully protected"
+			return
+		}
+		done = true
+		return
+	}
+}
+
+func rollRemoveCheck(ctx context.Context, api wekaApi, asgName string, capacity int64) stepCheck {
+	return func() (done bool, progress string, err error) {
+		asg, err := autoscaling.GetAutoScalingGroup(asgName)
+		if err != nil {
+			return
+		}
+		left := int64(len(asg.Instances)) - capacity
+		progress = fmt.Sprintf("%d old instances are being removed by scale down", left)
+		if left > 0 {
+			return
+		}
+		if !wekaProtected(ctx, api) {
+			progress = "waiting for weka data to be fully protected"
+			return
+		}
+		done = true
+		return
+	}
+}
+
+func rollStepError(err error) error {
+	if err == stepTimedOut {
+		return errors2.Wrap(err, "roll step didn't finish, rerun the command to resume")
+	}
+	return err
+}
+
+// RollHostGroup replaces hostgroup instances with instances of a new launch template version, batch by batch.
+// Each batch launches new instances, waits for them to join, then lowers the desired capacity back so that the scale
+// down flow removes the oldest hosts. Progress is saved in DB, rerunning the command resumes an interrupted roll.
+func RollHostGroup(ctx context.Context, params RollParams) error {
+	awsCluster, err := GetCluster(params.Name, true)
+	if err != nil {
+		return err
+	}
+	hostGroup, err := getHostGroup(awsCluster, params.HostGroupName)
+	if err != nil {
+		return err
+	}
+
+	tableName := db.GetTableName(params.Name)
+	hostGroupName := string(params.HostGroupName)
+	roll, err := db.GetHostGroupRoll(tableName, hostGroupName)
+	if err != nil {
+		return err
+	}
+	if roll.Key == "" {
+		roll, err = startRoll(awsCluster, &hostGroup, params)
+		if err != nil {
+			return err
+		}
+	} else {
+		if (params.InstanceType != "" && params.InstanceType != roll.InstanceType) || (params.ImageID != "" && params.ImageID != roll.ImageID) {
+			return errors.New(fmt.Sprintf(
+				"hostgroup %s roll to instance type %s and ami %s is in progress, rerun without --instance-type and --ami to resume it",
+				hostGroupName, roll.InstanceType, roll.ImageID))
+		}
+		logging.UserInfo("Resuming hostgroup %s roll (instance type: %s, ami: %s)", hostGroupName, roll.InstanceType, roll.ImageID)
+	}
+
+	api, err := newWekaApi(params.Name)
+	if err != nil {
+		return err
+	}
+
+	asgName := hostGroup.AutoscalingGroup.ResourceName()
+	for {
+		asg, err := autoscaling.GetAutoScalingGroup(asgName)
+		if err != nil {
+			return err
+		}
+		oldInstanceIds := instanceIdsIntersection(roll.OldInstanceIds, strings2.RefListToList(common.UnpackASGInstanceIds(asg.Instances)))
+
+		switch roll.Phase {
+		case rollPhaseLaunch:
+			if len(oldInstanceIds) == 0 {
+				return db.DeleteHostGroupRoll(tableName, hostGroupName)
+			}
+			batchSize := common.Min(int(roll.BatchSize), len(oldInstanceIds))
+			target := roll.Capacity + int64(batchSize)
+			logging.UserProgress("Launching %d new instances, %d old instances left to replace", batchSize, len(oldInstanceIds))
+			if *asg.DesiredCapacity != target {
+				err = autoscaling.SetDesiredCapacity(asgName, target)
+				if err != nil {
+					return err
+				}
+			}
+			err = waitForStep(ctx, params.Timeout, rollLaunchCheck(ctx, api, asgName, roll, target))
+			if err != nil {
+				return rollStepError(err)
+			}
+			roll.Phase = rollPhaseRemove
+		case rollPhaseRemove:
+			if *asg.DesiredCapacity != roll.Capacity {
+				err = autoscaling.SetDesiredCapacity(asgName, roll.Capacity)
+				if err != nil {
+					return err
+				}
+			}
+			err = waitForStep(ctx, params.Timeout, rollRemoveCheck(ctx, api, asgName, roll.Capacity))
+			if err != nil {
+				return rollStepError(err)
+			}
+			roll.Phase = rollPhaseLaunch
+		default:
+			return errors.New(fmt.Sprintf("unknown roll phase %s", roll.Phase))
+		}
+
+		err = db.SaveHostGroupRoll(tableName, hostGroupName, roll)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	errors2 "github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"strings"
+	"time"
+	"wekactl/internal/aws/autoscaling"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/aws/scalemachine"
+	"wekactl/internal/cluster"
+	strings2 "wekactl/internal/lib/strings"
+	"wekactl/internal/logging"
+)
+
+type ScaleParams struct {
+	Name          cluster.ClusterName
+	HostGroupName common.HostGroupName
+	Desired       int64
+	Wait          bool
+	Timeout       time.Duration
+}
+
+func validateDesiredCapacity(hostGroup HostGroup, desired, maxSize int64) error {
+	if desired < 0 {
+		return errors.New("desired capacity can't be negative")
+	}
+	if desired > maxSize {
+		return errors.New(fmt.Sprintf("desired capacity %d is above hostgroup %s max size %d", desired, hostGroup.HostGroupInfo.Name, maxSize))
+	}
+	if hostGroup.HostGroupInfo.Role == common.RoleBackend && desired < common.MinBackendsNumber {
+		return errors.New(fmt.Sprintf("desired capacity %d is below the minimum of %d backends", desired, common.MinBackendsNumber))
+	}
+	return nil
+}
+
+func scaleConvergenceCheck(ctx context.Context, api wekaApi, hostGroup HostGroup, desired int64, stateMachineArn string, started time.Time, transientErrors *[]string) stepCheck {
+	asgName := hostGroup.AutoscalingGroup.ResourceName()
+	return func() (done bool, progress string, err error) {
+		failures, err := scalemachine.GetExecutionFailures(stateMachineArn, started)
+		if err != nil {
+			return
+		}
+		if len(failures) > 0 {
+			if len(*transientErrors) == 0 || (*transientErrors)[0] != failures[0] {
+				log.Warn().Msgf("scale execution failed: %s", failures[0])
+			}
+			*transientErrors = failures
+		}
+
+		asg, err := autoscaling.GetAutoScalingGroup(asgName)
+		if err != nil {
+			return
+		}
+		progress = fmt.Sprintf("%d/%d instances are in the auto scaling group", len(asg.Instances), desired)
+		if int64(len(asg.Instances)) != desired {
+			return
+		}
+
+		instanceIds := strings2.RefListToList(common.UnpackASGInstanceIds(asg.Instances))
+		hosts, hostsErr := api.hostsList(ctx)
+		if hostsErr != nil {
+			log.Warn().Msgf("failed getting weka hosts: %s", hostsErr)
+			return
+		}
+		active := instanceIdsIntersection(instanceIds, activeInstanceIds(hosts))
+		progress = fmt.Sprintf("%d/%d instances are active weka hosts", len(active), desired)
+		if int64(len(active)) != desired {
+			return
+		}
+
+		if hostGroup.HostGroupInfo.Role == common.RoleBackend {
+			removed := make(map[string]bool)
+			for _, host := range hosts {
+				if host.Mode == "backend" && host.Aws.InstanceId != "" && !strings2.AnyOf(host.Aws.InstanceId, instanceIds...) {
+					removed[host.Aws.InstanceId] = true
+				}
+			}
+			if len(removed) > 0 {
+				progress = fmt.Sprintf("%d removed instances are still weka backends", len(removed))
+				return
+			}
+		}
+
+		if !wekaProtected(ctx, api) {
+			progress = "waiting for weka data to be fully protected"
+			return
+		}
+		done = true
+		return
+	}
+}
+
+// ScaleHostGroup sets hostgroup desired capacity, and optionally waits until the state machine executions brought weka
+// to the same number of active hosts with data fully protected
+func ScaleHostGroup(ctx context.Context, params ScaleParams) error {
+	awsCluster, err := GetCluster(params.Name, false)
+	if err != nil {
+		return err
+	}
+	hostGroup, err := getHostGroup(awsCluster, params.HostGroupName)
+	if err != nil {
+		return err
+	}
+
+	roll, err := db.GetHostGroupRoll(db.GetTableName(params.Name), string(params.HostGroupName))
+	if err != nil {
+		return err
+	}
+	if roll.Key != "" {
+		return errors.New(fmt.Sprintf("hostgroup %s roll is in progress, please resume it with hostgroup roll first", params.HostGroupName))
+	}
+
+	asgName := hostGroup.AutoscalingGroup.ResourceName()
+	asg, err := autoscaling.GetAutoScalingGroup(asgName)
+	if err != nil {
+		return err
+	}
+	err = validateDesiredCapacity(hostGroup, params.Desired, *asg.MaxSize)
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	if *asg.DesiredCapacity != params.Desired {
+		err = autoscaling.SetDesiredCapacity(asgName, params.Desired)
+		if err != nil {
+			return err
+		}
+		logging.UserProgress("Hostgroup %s desired capacity was changed from %d to %d", params.HostGroupName, *asg.DesiredCapacity, params.Desired)
+	}
+	if !params.Wait {
+		return nil
+	}
+
+	api, err := newWekaApi(params.Name)
+	if err != nil {
+		return err
+	}
+	stateMachineArn, err := scalemachine.GetStateMachineArn(common.GenerateResourceName(params.Name, params.HostGroupName))
+	if err != nil {
+		return err
+	}
+
+	var transientErrors []string
+	err = waitForStep(ctx, params.Timeout, scaleConvergenceCheck(ctx, api, hostGroup, params.Desired, stateMachineArn, started, &transientErrors))
+	if err == stepTimedOut {
+		if len(transientErrors) > 0 {
+			return errors.New(fmt.Sprintf(
+				"hostgroup %s didn't converge within %s, last transient errors:\n%s", params.HostGroupName, params.Timeout, strings.Join(transientErrors[:common.Min(len(transientErrors), 3)], "\n")))
+		}
+		return errors2.Wrap(err, fmt.Sprintf("hostgroup %s didn't converge within %s", params.HostGroupName, params.Timeout))
+	}
+	return err
+}
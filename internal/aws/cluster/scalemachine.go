@@ -5,8 +5,10 @@ import (
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/rs/zerolog/log"
 	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
 	"wekactl/internal/aws/iam"
 	"wekactl/internal/aws/lambdas"
+	"wekactl/internal/aws/lambdas/scale_down"
 	"wekactl/internal/aws/scalemachine"
 	"wekactl/internal/cluster"
 )
@@ -14,19 +16,19 @@ import (
 const scaleMachineVersion = "v1"
 
 type ScaleMachine struct {
-	Arn                 string
-	TableName           string
-	Version             string
-	ASGName             string
-	HostGroupInfo       common.HostGroupInfo
-	HostGroupParams     common.HostGroupParams
-	fetch               Lambda
-	scale               Lambda
-	terminate           Lambda
-	transient           Lambda
-	StateMachine        scalemachine.StateMachine
-	Profile             IamProfile
-	UseDynamoDBEndpoint bool
+	Arn             string
+	TableName       string
+	Version         string
+	ASGName         string
+	HostGroupInfo   common.HostGroupInfo
+	HostGroupParams common.HostGroupParams
+	fetch           Lambda
+	scale           Lambda
+	terminate       Lambda
+	transient       Lambda
+	StateMachine    scalemachine.StateMachine
+	Profile         IamProfile
+	ClusterSettings db.ClusterSettings
 }
 
 func (s *ScaleMachine) Tags() cluster.Tags {
@@ -34,7 +36,8 @@ func (s *ScaleMachine) Tags() cluster.Tags {
 }
 
 func (s *ScaleMachine) SubResources() []cluster.Resource {
-	return []cluster.Resource{&s.fetch, &s.scale, &s.terminate, &s.transient, &s.Profile}
+	// scale lambda is updated first, so that it reads credentials from DB before fetch stops passing them
+	return []cluster.Resource{&s.scale, &s.fetch, &s.terminate, &s.transient, &s.Profile}
 }
 
 func (s *ScaleMachine) ResourceName() string {
@@ -49,7 +52,7 @@ func (s *ScaleMachine) Fetch() error {
 	s.Version = version
 
 	if s.Profile.Arn == "" {
-		profileArn, err := iam.GetIamRoleArn(s.HostGroupInfo.ClusterName, s.Profile.resourceNameBase())
+		profileArn, err := iam.GetIamRoleArn(s.Profile.rolesPath(), s.Profile.resourceNameBase())
 		if err != nil {
 			return err
 		}
@@ -115,69 +118,111 @@ func (s *ScaleMachine) TargetVersion() string {
 	return scaleMachineVersion
 }
 
+func (s *ScaleMachine) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return scalemachine.UpdateStateMachineTags(s.Arn, tags.AsSfn(), removedKeys)
+}
+
 func (s *ScaleMachine) Create(tags cluster.Tags) (err error) {
-	stateMachineLambdasArn := scalemachine.StateMachineLambdasArn{
+	arn, err := scalemachine.CreateStateMachine(tags.AsSfn(), s.lambdasArn(), s.Profile.Arn, s.ResourceName())
+	if err != nil {
+		return
+	}
+	s.Arn = *arn
+	return nil
+}
+
+func (s *ScaleMachine) Update(tags cluster.Tags) error {
+	return scalemachine.UpdateStateMachineRoleArn(s.Arn, s.Profile.Arn)
+}
+
+func (s *ScaleMachine) lambdasArn() scalemachine.StateMachineLambdasArn {
+	return scalemachine.StateMachineLambdasArn{
 		Fetch:     s.fetch.Arn,
 		Scale:     s.scale.Arn,
 		Terminate: s.terminate.Arn,
 		Transient: s.transient.Arn,
 	}
+}
 
-	arn, err := scalemachine.CreateStateMachine(tags.AsSfn(), stateMachineLambdasArn, s.Profile.Arn, s.ResourceName())
+func (s *ScaleMachine) Verify() (drifts []string, err error) {
+	stateMachine, err := scalemachine.DescribeStateMachine(s.Arn)
 	if err != nil {
 		return
 	}
-	s.Arn = *arn
-	return nil
+	definition, err := scalemachine.GetStateMachineDefinition(s.lambdasArn())
+	if err != nil {
+		return
+	}
+	if !scalemachine.EqualDefinitions(*stateMachine.Definition, definition) {
+		drifts = append(drifts, "definition differs")
+	}
+	if *stateMachine.RoleArn != s.Profile.Arn {
+		drifts = append(drifts, fmt.Sprintf("role is %s instead of %s", *stateMachine.RoleArn, s.Profile.Arn))
+	}
+	return
 }
 
-func (s *ScaleMachine) Update(tags cluster.Tags) error {
-	return scalemachine.UpdateStateMachineRoleArn(s.Arn, s.Profile.Arn)
+func (s *ScaleMachine) Reconcile(tags cluster.Tags) error {
+	definition, err := scalemachine.GetStateMachineDefinition(s.lambdasArn())
+	if err != nil {
+		return err
+	}
+	return scalemachine.UpdateStateMachine(s.Arn, definition, s.Profile.Arn)
 }
 
 func (s *ScaleMachine) Init() {
 	log.Debug().Msgf("Initializing hostgroup %s state machine ...", string(s.HostGroupInfo.Name))
-	s.Profile.Name = "sm"
-	s.Profile.PolicyName = fmt.Sprintf("wekactl-%s-sm-%s", string(s.HostGroupInfo.ClusterName), string(s.HostGroupInfo.Name))
-	s.Profile.TableName = s.TableName
-	s.Profile.AssumeRolePolicy = iam.GetStateMachineAssumeRolePolicy()
-	s.Profile.HostGroupInfo = s.HostGroupInfo
-	s.Profile.Policy = iam.GetStateMachineRolePolicy()
-	s.Profile.Init()
-
 	vpcConfig := lambdas.GetLambdaVpcConfig(s.HostGroupParams.Subnet, s.HostGroupParams.SecurityGroupsIds)
+	scope := policyScope(s.HostGroupInfo, s.TableName, s.ASGName)
 
 	s.fetch.TableName = s.TableName
 	s.fetch.ASGName = s.ASGName
 	s.fetch.HostGroupInfo = s.HostGroupInfo
+	s.fetch.ClusterSettings = s.ClusterSettings
 	s.fetch.Type = lambdas.LambdaFetchInfo
 	s.fetch.VPCConfig = lambda.VpcConfig{}
-	s.fetch.Permissions = iam.GetJoinAndFetchLambdaPolicy()
-	s.fetch.UseDynamoDBEndpoint = s.UseDynamoDBEndpoint
+	s.fetch.Permissions = iam.GetJoinAndFetchLambdaPolicy(scope, s.fetch.ResourceName())
 	s.fetch.Init()
 
 	s.scale.TableName = s.TableName
 	s.scale.ASGName = s.ASGName
 	s.scale.HostGroupInfo = s.HostGroupInfo
+	s.scale.ClusterSettings = s.ClusterSettings
 	s.scale.Type = lambdas.LambdaScale
 	s.scale.VPCConfig = vpcConfig
-	s.scale.Permissions = iam.GetScaleLambdaPolicy()
-	s.scale.UseDynamoDBEndpoint = s.UseDynamoDBEndpoint
+	if s.ClusterSettings.RecordTarget != "" {
+		// validated when set by hostgroup record
+		scope.RecordBucket, scope.RecordPrefix, _ = scale_down.ParseRecordTarget(s.ClusterSettings.RecordTarget)
+	}
+	s.scale.Permissions = iam.GetScaleLambdaPolicy(scope, s.scale.ResourceName())
 	s.scale.Init()
 
 	s.terminate.TableName = s.TableName
 	s.terminate.ASGName = s.ASGName
 	s.terminate.HostGroupInfo = s.HostGroupInfo
+	s.terminate.ClusterSettings = s.ClusterSettings
 	s.terminate.Type = lambdas.LambdaTerminate
 	s.terminate.VPCConfig = lambda.VpcConfig{}
-	s.terminate.Permissions = iam.GetTerminateLambdaPolicy()
+	s.terminate.Permissions = iam.GetTerminateLambdaPolicy(scope, s.terminate.ResourceName())
 	s.terminate.Init()
 
 	s.transient.TableName = s.TableName
 	s.transient.ASGName = s.ASGName
 	s.transient.HostGroupInfo = s.HostGroupInfo
+	s.transient.ClusterSettings = s.ClusterSettings
 	s.transient.Type = lambdas.LambdaTransient
 	s.transient.VPCConfig = lambda.VpcConfig{}
 	s.transient.Permissions = iam.PolicyDocument{}
 	s.transient.Init()
+
+	// the state machine role may only invoke the hostgroup lambdas, so it follows them
+	s.Profile.Name = "sm"
+	s.Profile.PolicyName = fmt.Sprintf("wekactl-%s-sm-%s", string(s.HostGroupInfo.ClusterName), string(s.HostGroupInfo.Name))
+	s.Profile.TableName = s.TableName
+	s.Profile.AssumeRolePolicy = iam.GetStateMachineAssumeRolePolicy()
+	s.Profile.HostGroupInfo = s.HostGroupInfo
+	s.Profile.ClusterSettings = s.ClusterSettings
+	s.Profile.Policy = iam.GetStateMachineRolePolicy(scope, []string{
+		s.fetch.ResourceName(), s.scale.ResourceName(), s.terminate.ResourceName(), s.transient.ResourceName()})
+	s.Profile.Init()
 }
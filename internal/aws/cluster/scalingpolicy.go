@@ -0,0 +1,187 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/rs/zerolog/log"
+	"wekactl/internal/aws/autoscaling"
+	cloudwatch2 "wekactl/internal/aws/cloudwatch"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/cluster"
+	"wekactl/internal/logging"
+)
+
+const scalingPolicyVersion = "v1"
+
+// noScalingPolicyVersion is the version of hostgroups without wekactl managed scaling policies
+const noScalingPolicyVersion = "none"
+
+const (
+	PolicyTypeTargetTracking = "target-tracking"
+	PolicyTypeStep           = "step"
+)
+
+// ScalingPolicy holds the auto scaling group policies (and their alarms for step scaling) defined by the hostgroup
+// autoscaling settings in DB. Policies can't be tagged, their version is kept as an auto scaling group tag.
+type ScalingPolicy struct {
+	HostGroupInfo common.HostGroupInfo
+	ASGName       string
+	Settings      db.HostGroupAutoscaling
+	Version       string
+}
+
+func (s *ScalingPolicy) Tags() cluster.Tags {
+	return GetHostGroupResourceTags(s.HostGroupInfo, s.TargetVersion())
+}
+
+func (s *ScalingPolicy) SubResources() []cluster.Resource {
+	return []cluster.Resource{}
+}
+
+func (s *ScalingPolicy) ResourceName() string {
+	return common.GenerateResourceName(s.HostGroupInfo.ClusterName, s.HostGroupInfo.Name) + "-scaling"
+}
+
+func (s *ScalingPolicy) Fetch() error {
+	settings, err := db.GetHostGroupAutoscaling(db.GetTableName(s.HostGroupInfo.ClusterName), string(s.HostGroupInfo.Name))
+	if err != nil {
+		return err
+	}
+	s.Settings = settings
+
+	version, err := autoscaling.GetAutoScalingGroupTagValue(s.ASGName, autoscaling.ScalingPolicyVersionTagKey)
+	if err != nil {
+		return err
+	}
+	s.Version = version
+	return nil
+}
+
+func (s *ScalingPolicy) Init() {
+	log.Debug().Msgf("Initializing hostgroup %s scaling policy ...", string(s.HostGroupInfo.Name))
+}
+
+func (s *ScalingPolicy) DeployedVersion() string {
+	if s.Version == "" {
+		return noScalingPolicyVersion
+	}
+	return s.Version
+}
+
+func (s *ScalingPolicy) TargetVersion() string {
+	if s.Settings.Key == "" {
+		return noScalingPolicyVersion
+	}
+	settings, err := json.Marshal(s.Settings)
+	if err != nil {
+		panic(err)
+	}
+	h := sha256.New()
+	h.Write(settings)
+	return scalingPolicyVersion + "-" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// UpdateTags tags the step scaling alarms, the policies themselves can't be tagged
+func (s *ScalingPolicy) UpdateTags(tags cluster.Tags, removedKeys []string) error {
+	return cloudwatch2.UpdateMetricAlarmsTags(s.ResourceName(), tags.AsCloudWatchAlarm(), removedKeys)
+}
+
+func (s *ScalingPolicy) Create(tags cluster.Tags) error {
+	return s.Update(tags)
+}
+
+func (s *ScalingPolicy) deletePolicies() error {
+	policies, err := autoscaling.GetScalingPolicies(s.ASGName, s.ResourceName())
+	if err != nil {
+		return err
+	}
+	err = autoscaling.DeleteScalingPolicies(policies)
+	if err != nil {
+		return err
+	}
+	alarms, err := cloudwatch2.GetMetricAlarms(s.ResourceName())
+	if err != nil {
+		return err
+	}
+	return cloudwatch2.DeleteMetricAlarms(alarms)
+}
+
+// scaleInTarget is the step policy threshold below which an instance is removed
+func (s *ScalingPolicy) scaleInTarget() float64 {
+	if s.Settings.ScaleInTarget == 0 {
+		return s.Settings.Target / 2
+	}
+	return s.Settings.ScaleInTarget
+}
+
+func (s *ScalingPolicy) putStepPolicies(tags cluster.Tags, metric autoscaling.ScalingMetric) error {
+	steps := []struct {
+		suffix     string
+		adjustment int64
+		operator   string
+		threshold  float64
+	}{
+		{"-out", 1, cloudwatch.ComparisonOperatorGreaterThanThreshold, s.Settings.Target},
+		{"-in", -1, cloudwatch.ComparisonOperatorLessThanThreshold, s.scaleInTarget()},
+	}
+	dimensionName, dimensionValue := metric.Dimension(s.ASGName, s.HostGroupInfo.ClusterName)
+	for _, step := range steps {
+		name := s.ResourceName() + step.suffix
+		policyArn, err := autoscaling.PutStepPolicy(s.ASGName, name, step.adjustment, s.Settings.Cooldown)
+		if err != nil {
+			return err
+		}
+		err = cloudwatch2.PutScalingAlarm(tags.AsCloudWatchAlarm(), name, metric.Namespace, metric.MetricName,
+			dimensionName, dimensionValue, step.operator, step.threshold, policyArn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ScalingPolicy) Update(tags cluster.Tags) error {
+	err := s.deletePolicies()
+	if err != nil {
+		return err
+	}
+
+	if s.Settings.Key == "" {
+		asg, err := autoscaling.GetAutoScalingGroup(s.ASGName)
+		if err != nil {
+			return err
+		}
+		err = autoscaling.SetAutoScalingGroupSize(s.ASGName, 0, *asg.MaxSize)
+		if err != nil {
+			return err
+		}
+		return autoscaling.DeleteAutoScalingGroupTag(s.ASGName, autoscaling.ScalingPolicyVersionTagKey)
+	}
+
+	metric, err := autoscaling.GetScalingMetric(s.Settings.Metric)
+	if err != nil {
+		return err
+	}
+	err = autoscaling.SetAutoScalingGroupSize(s.ASGName, s.Settings.Min, s.Settings.Max)
+	if err != nil {
+		return err
+	}
+	switch s.Settings.PolicyType {
+	case PolicyTypeTargetTracking:
+		err = autoscaling.PutTargetTrackingPolicy(s.ASGName, s.ResourceName()+"-target", s.HostGroupInfo.ClusterName, metric, s.Settings.Target, s.Settings.Cooldown)
+	case PolicyTypeStep:
+		err = s.putStepPolicies(tags, metric)
+	default:
+		err = errors.New(fmt.Sprintf("unsupported policy type %s", s.Settings.PolicyType))
+	}
+	if err != nil {
+		return err
+	}
+	logging.UserProgress("Hostgroup %s %s scaling policy on %s was set", s.HostGroupInfo.Name, s.Settings.PolicyType, s.Settings.Metric)
+	return autoscaling.SetAutoScalingGroupTag(s.ASGName, autoscaling.ScalingPolicyVersionTagKey, s.TargetVersion())
+}
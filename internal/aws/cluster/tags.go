@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/cluster"
+	"wekactl/internal/logging"
+)
+
+func RenderClusterTags(name cluster.ClusterName) error {
+	settings, err := db.GetClusterSettings(name)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	for key := range settings.TagsMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := []string{"key", "value"}
+	var data [][]string
+	for _, key := range keys {
+		data = append(data, []string{key, settings.TagsMap[key]})
+	}
+	common.RenderTable(fields, data)
+	return nil
+}
+
+// UpdateClusterTags saves the tags changes to the cluster settings and re-tags every existing cluster resource,
+// running instances, their volumes and network interfaces included. Instances launched afterwards get the new tags
+// from a new launch template version. Like the other updates, it refuses to run with a wekactl older than the one that
+// last updated the cluster.
+func UpdateClusterTags(name cluster.ClusterName, setTags cluster.Tags, removedKeys []string) error {
+	awsCluster, err := GetCluster(name, true)
+	if err != nil {
+		return err
+	}
+
+	settings := awsCluster.ClusterSettings
+	err = checkDowngrade(settings, false)
+	if err != nil {
+		return err
+	}
+	tagsMap := cluster.Tags{}.Update(settings.TagsMap)
+	for _, key := range removedKeys {
+		if _, ok := tagsMap[key]; !ok {
+			return errors.New(fmt.Sprintf("tag %s is not set on cluster %s", key, name))
+		}
+		delete(tagsMap, key)
+	}
+	tagsMap.Update(setTags)
+	err = cluster.ValidateTags(tagsMap)
+	if err != nil {
+		return err
+	}
+	settings.TagsMap = tagsMap
+
+	err = db.SaveClusterSettings(db.GetTableName(name), settings)
+	if err != nil {
+		return err
+	}
+	logging.UserProgress("Cluster %s tags were saved, tagging resources ...", name)
+
+	// resources get the settings through Init, keeping them in sync with the saved ones
+	awsCluster.ClusterSettings = settings
+	awsCluster.Init()
+
+	dynamoDb := DynamoDb{
+		ClusterName: name,
+	}
+	dynamoDb.Init()
+	err = cluster.UpdateResourceTags(&dynamoDb, settings, removedKeys)
+	if err != nil {
+		return err
+	}
+	return cluster.UpdateResourceTags(&awsCluster, settings, removedKeys)
+}
@@ -8,10 +8,16 @@ import (
 	"github.com/rs/zerolog/log"
 	autoscaling2 "wekactl/internal/aws/autoscaling"
 	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/aws/dist"
+	"wekactl/internal/aws/lambdas"
 	"wekactl/internal/aws/launchtemplate"
 	"wekactl/internal/cluster"
 	"wekactl/internal/connectors"
 	"wekactl/internal/env"
+	strings2 "wekactl/internal/lib/strings"
+	"wekactl/internal/lib/version"
+	"wekactl/internal/logging"
 )
 
 const defaultVolumeSize = 48
@@ -88,12 +94,22 @@ func fetchHostGroupParams(clusterName cluster.ClusterName, asg *autoscaling.Grou
 		ImageID:           *launchTemplateData.ImageId,
 		IamArn:            *launchTemplateData.IamInstanceProfile.Arn,
 		InstanceType:      *launchTemplateData.InstanceType,
-		Subnet:            *launchTemplateData.NetworkInterfaces[0].SubnetId,
+		Subnets:           autoscaling2.GetAutoScalingGroupSubnets(asg),
 		VolumesInfo:       volumesInfo,
 		MaxSize:           *asg.MaxSize,
 		HttpTokens:        *launchTemplateData.MetadataOptions.HttpTokens,
 	}
 
+	// launch templates of single subnet hostgroups created before multiple subnets were supported hold the subnet
+	if launchTemplateData.NetworkInterfaces[0].SubnetId != nil {
+		hostGroupParams.Subnet = *launchTemplateData.NetworkInterfaces[0].SubnetId
+	} else if len(hostGroupParams.Subnets) > 0 {
+		hostGroupParams.Subnet = hostGroupParams.Subnets[0]
+	} else {
+		err = errors.New(fmt.Sprintf("no subnet found for auto scaling group %s", *asg.AutoScalingGroupName))
+		return
+	}
+
 	if launchTemplateData.KeyName != nil {
 		hostGroupParams.KeyName = *launchTemplateData.KeyName
 	}
@@ -168,14 +184,116 @@ func getHostGroups(clusterName cluster.ClusterName, fetchHotGroupParams bool) (h
 	return
 }
 
-func UpdateCluster(name cluster.ClusterName, dryRun bool) error {
-	awsCluster, err := GetCluster(name, true)
+// addClusterSubnets spreads the cluster hostgroups over additional subnets, instances already running are kept in place
+func addClusterSubnets(awsCluster AWSCluster, subnets []string, dryRun bool) error {
+	if err := validateSubnetsVpc(awsCluster.ClusterSettings.VpcId, subnets); err != nil {
+		return err
+	}
+	for _, hostGroup := range awsCluster.HostGroups {
+		asg := hostGroup.AutoscalingGroup
+		currentSubnets := asg.HostGroupParams.GetSubnets()
+		newSubnets := appendSubnets(append([]string{}, currentSubnets...), subnets...)
+		if len(newSubnets) == len(currentSubnets) {
+			continue
+		}
+		if dryRun {
+			logging.UserInfo("hostgroup \"%s\" subnets will be updated to %v", hostGroup.HostGroupInfo.Name, newSubnets)
+			continue
+		}
+		tags := asg.Tags().Update(awsCluster.ClusterSettings.Tags())
+		err := autoscaling2.UpdateAutoScalingGroup(asg.LaunchTemplate.ResourceName(), asg.ResourceName(), newSubnets, tags.AsAsg())
+		if err != nil {
+			return err
+		}
+		log.Info().Msgf("hostgroup %s subnets were updated to %v", hostGroup.HostGroupInfo.Name, newSubnets)
+	}
+	return nil
+}
+
+type UpdateParams struct {
+	Name           cluster.ClusterName
+	DryRun         bool
+	Subnets        []string
+	AllowDowngrade bool
+	ToVersion      string // lambdas package ID to deploy instead of the one wekactl was built with
+}
+
+// checkDowngrade refuses to deploy an older wekactl or lambdas package than the ones recorded by the last update,
+// versions that can't be compared (e.g. dev builds without tags) are not considered downgrades
+func checkDowngrade(settings db.ClusterSettings, allowDowngrade bool) error {
+	versionInfo, err := env.GetBuildVersion()
+	if err != nil {
+		return err
+	}
+
+	if version.IsDowngrade(settings.BuildVersion, versionInfo.BuildVersion) {
+		if !allowDowngrade {
+			return errors.New(fmt.Sprintf(
+				"cluster was updated by wekactl %s, refusing to downgrade it with wekactl %s, use --allow-downgrade to force it",
+				settings.BuildVersion, versionInfo.BuildVersion))
+		}
+		logging.UserInfo("downgrading cluster from wekactl %s to %s", settings.BuildVersion, versionInfo.BuildVersion)
+	}
+
+	if version.IsDowngrade(settings.LambdasID, dist.LambdasID) {
+		if !allowDowngrade {
+			return errors.New(fmt.Sprintf(
+				"cluster lambdas package is %s, refusing to downgrade it to %s, use --allow-downgrade to force it",
+				settings.LambdasID, dist.LambdasID))
+		}
+		logging.UserInfo("downgrading cluster lambdas package from %s to %s", settings.LambdasID, dist.LambdasID)
+	}
+	return nil
+}
+
+func pinLambdasVersion(lambdasId string) error {
+	exists, err := lambdas.LambdaPackageExists(lambdasId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New(fmt.Sprintf("lambdas package %s was not found in %s", lambdasId, env.Config.Region))
+	}
+	log.Debug().Msgf("pinning lambdas package %s instead of %s", lambdasId, dist.LambdasID)
+	dist.LambdasID = lambdasId
+	return nil
+}
+
+func UpdateCluster(params UpdateParams) error {
+	if params.ToVersion != "" {
+		if err := pinLambdasVersion(params.ToVersion); err != nil {
+			return err
+		}
+	}
+
+	awsCluster, err := GetCluster(params.Name, true)
+	if err != nil {
+		return err
+	}
+	return updateCluster(awsCluster, params)
+}
+
+// updateCluster brings the cluster resources to the versions generated from the given cluster settings
+func updateCluster(awsCluster AWSCluster, params UpdateParams) error {
+	err := checkDowngrade(awsCluster.ClusterSettings, params.AllowDowngrade)
+	if err != nil {
+		return err
+	}
+
+	// the generated policies may need more permissions than the supplied roles were validated against on import
+	err = validateExternalRoles(&awsCluster)
+	if err != nil {
+		return err
+	}
+
+	// the launch templates user data tags the network interfaces it creates, which older clusters roles may not allow
+	err = validateInstanceRoles(awsCluster)
 	if err != nil {
 		return err
 	}
 
 	dynamoDb := DynamoDb{
-		ClusterName: name,
+		ClusterName: params.Name,
 	}
 	dynamoDb.Init()
 	err = cluster.EnsureResource(&dynamoDb, awsCluster.ClusterSettings, false)
@@ -183,5 +301,74 @@ func UpdateCluster(name cluster.ClusterName, dryRun bool) error {
 		return err
 	}
 
-	return cluster.EnsureResource(&awsCluster, awsCluster.ClusterSettings, dryRun)
+	// before the terminate lambda policy requiring the tag rolls out
+	untagged, err := tagHostGroupsInstances(awsCluster, !params.DryRun)
+	if err != nil {
+		return err
+	}
+	if params.DryRun {
+		for _, drift := range untagged {
+			logging.UserInfo("resource %s \"%s\" %s, they will be tagged", drift.ResourceType, drift.ResourceName, drift.Description)
+		}
+	}
+
+	err = cluster.EnsureResource(&awsCluster, awsCluster.ClusterSettings, params.DryRun)
+	if err != nil {
+		return err
+	}
+
+	if len(params.Subnets) > 0 {
+		err = addClusterSubnets(awsCluster, params.Subnets, params.DryRun)
+		if err != nil {
+			return err
+		}
+	}
+
+	if params.DryRun {
+		return nil
+	}
+	return saveDeployedVersions(params.Name, awsCluster.ClusterSettings)
+}
+
+// tagHostGroupsInstances finds the hostgroups instances missing the cluster name tag, which the terminate lambda
+// policy conditions termination on, e.g. the instances imported by an older wekactl. When tag is set they are tagged.
+func tagHostGroupsInstances(awsCluster AWSCluster, tag bool) (drifts []cluster.Drift, err error) {
+	for _, hostGroup := range awsCluster.HostGroups {
+		asg := hostGroup.AutoscalingGroup
+		instanceIds, err := asg.untaggedInstanceIds()
+		if err != nil {
+			return drifts, err
+		}
+		if len(instanceIds) == 0 {
+			continue
+		}
+		drifts = append(drifts, cluster.Drift{
+			ResourceType: "AutoscalingGroup",
+			ResourceName: asg.ResourceName(),
+			Description:  fmt.Sprintf("%d instances are missing the %s tag", len(instanceIds), cluster.ClusterNameTagKey),
+		})
+		if !tag {
+			continue
+		}
+		err = asg.tagInstances(instanceIds)
+		if err != nil {
+			return drifts, err
+		}
+		log.Info().Msgf("hostgroup %s instances %v were tagged", hostGroup.HostGroupInfo.Name, strings2.RefListToList(instanceIds))
+	}
+	return
+}
+
+// saveDeployedVersions records the wekactl and lambdas package versions the cluster was updated with
+func saveDeployedVersions(name cluster.ClusterName, settings db.ClusterSettings) error {
+	versionInfo, err := env.GetBuildVersion()
+	if err != nil {
+		return err
+	}
+	if settings.BuildVersion == versionInfo.BuildVersion && settings.LambdasID == dist.LambdasID {
+		return nil
+	}
+	settings.BuildVersion = versionInfo.BuildVersion
+	settings.LambdasID = dist.LambdasID
+	return db.SaveClusterSettings(db.GetTableName(name), settings)
 }
@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"github.com/rs/zerolog/log"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/cluster"
+	"wekactl/internal/logging"
+)
+
+// VerifyCluster compares every cluster resource live configuration with the one wekactl would generate and renders
+// the drifts found, when fix is set the drifts are reconciled on the way, unless this wekactl is older than the one that
+// last updated the cluster and allowDowngrade isn't set
+func VerifyCluster(name cluster.ClusterName, fix, allowDowngrade bool) (drifts []cluster.Drift, err error) {
+	awsCluster, err := GetCluster(name, true)
+	if err != nil {
+		return
+	}
+	if fix {
+		// reconciling pushes the configuration generated by this binary
+		err = checkDowngrade(awsCluster.ClusterSettings, allowDowngrade)
+		if err != nil {
+			return
+		}
+		err = validateInstanceRoles(awsCluster)
+		if err != nil {
+			return
+		}
+	}
+
+	dynamoDb := DynamoDb{
+		ClusterName: name,
+	}
+	dynamoDb.Init()
+	dbDrifts, err := cluster.VerifyResource(&dynamoDb, awsCluster.ClusterSettings, fix)
+	if err != nil {
+		return
+	}
+	drifts = append(drifts, dbDrifts...)
+
+	// tagged before the terminate lambda policy requiring the tag is reconciled
+	instancesDrifts, err := tagHostGroupsInstances(awsCluster, fix)
+	drifts = append(drifts, instancesDrifts...)
+	if err != nil {
+		return
+	}
+
+	clusterDrifts, err := cluster.VerifyResource(&awsCluster, awsCluster.ClusterSettings, fix)
+	drifts = append(drifts, clusterDrifts...)
+	if err != nil {
+		return
+	}
+
+	log.Debug().Msgf("cluster %s has %d drifts", name, len(drifts))
+	if len(drifts) == 0 {
+		logging.UserSuccess("No drift found, cluster %s matches its generated configuration", name)
+		return
+	}
+
+	fields := []string{"resource type", "resource name", "drift"}
+	var data [][]string
+	for _, drift := range drifts {
+		data = append(data, []string{drift.ResourceType, drift.ResourceName, drift.Description})
+	}
+	common.RenderTable(fields, data)
+	return
+}
@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"strings"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/dist"
+	"wekactl/internal/cluster"
+	"wekactl/internal/env"
+)
+
+func versionStatus(deployedVersion, targetVersion string) string {
+	if deployedVersion == "" {
+		return "missing"
+	}
+	if deployedVersion != targetVersion {
+		return "outdated"
+	}
+	return "up to date"
+}
+
+// RenderClusterVersions prints the versions recorded in the cluster settings and each resource deployed version
+// against the version this wekactl would deploy
+func RenderClusterVersions(name cluster.ClusterName) error {
+	awsCluster, err := GetCluster(name, true)
+	if err != nil {
+		return err
+	}
+	versionInfo, err := env.GetBuildVersion()
+	if err != nil {
+		return err
+	}
+
+	dynamoDb := DynamoDb{
+		ClusterName: name,
+	}
+	dynamoDb.Init()
+	versions, err := cluster.GetResourceVersions(&dynamoDb)
+	if err != nil {
+		return err
+	}
+	clusterVersions, err := cluster.GetResourceVersions(&awsCluster)
+	if err != nil {
+		return err
+	}
+	versions = append(versions, clusterVersions...)
+
+	fields := []string{"component", "name", "deployed", "target", "status"}
+	settings := awsCluster.ClusterSettings
+	data := [][]string{
+		{"wekactl", string(name), settings.BuildVersion, versionInfo.BuildVersion, versionStatus(settings.BuildVersion, versionInfo.BuildVersion)},
+		{"lambdas package", string(name), settings.LambdasID, dist.LambdasID, versionStatus(settings.LambdasID, dist.LambdasID)},
+	}
+	for _, resourceVersion := range versions {
+		// a # suffix marks resources whose role changed, they are updated although their version tag is the target one
+		deployedVersion := strings.TrimSuffix(resourceVersion.DeployedVersion, "#")
+		data = append(data, []string{
+			resourceVersion.ResourceType,
+			resourceVersion.ResourceName,
+			deployedVersion,
+			resourceVersion.TargetVersion,
+			versionStatus(resourceVersion.DeployedVersion, resourceVersion.TargetVersion),
+		})
+	}
+	common.RenderTable(fields, data)
+	return nil
+}
@@ -0,0 +1,71 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"time"
+	"wekactl/internal/lib/weka"
+	"wekactl/internal/logging"
+)
+
+const stepPollInterval = 30 * time.Second
+
+var stepTimedOut = errors.New("timed out waiting for hostgroup")
+
+// stepCheck returns whether the awaited step is done, and a progress description that is reported when it changes
+type stepCheck func() (done bool, progress string, err error)
+
+func waitForStep(ctx context.Context, timeout time.Duration, check stepCheck) error {
+	deadline := time.Now().Add(timeout)
+	lastProgress := ""
+	for {
+		done, progress, err := check()
+		if err != nil {
+			return err
+		}
+		if progress != lastProgress {
+			logging.UserProgress(progress)
+			lastProgress = progress
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return stepTimedOut
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(stepPollInterval):
+		}
+	}
+}
+
+// wekaProtected returns whether weka io is running and no data waits for rebuild, weka api errors are reported as not
+// protected yet, since backends come and go while hostgroups scale
+func wekaProtected(ctx context.Context, api wekaApi) bool {
+	status, err := api.status(ctx)
+	if err != nil {
+		log.Warn().Msgf("failed getting weka status: %s", err)
+		return false
+	}
+	return status.IoStatus == "STARTED" && status.FullyProtected()
+}
+
+// activeInstanceIds returns instances whose weka hosts are all active
+func activeInstanceIds(hosts weka.HostListResponse) (instanceIds []string) {
+	inactive := make(map[string]bool)
+	for _, host := range hosts {
+		if host.Aws.InstanceId == "" {
+			continue
+		}
+		inactive[host.Aws.InstanceId] = inactive[host.Aws.InstanceId] || host.State != "ACTIVE"
+	}
+	for instanceId, isInactive := range inactive {
+		if !isInactive {
+			instanceIds = append(instanceIds, instanceId)
+		}
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/cluster"
+	"wekactl/internal/connectors"
+	"wekactl/internal/lib/jrpc"
+	"wekactl/internal/lib/weka"
+)
+
+// wekaApi calls weka management api of the cluster backends, it requires wekactl to reach their private ips
+type wekaApi struct {
+	clusterName cluster.ClusterName
+	username    string
+	password    string
+}
+
+func newWekaApi(clusterName cluster.ClusterName) (api wekaApi, err error) {
+	creds, err := db.GetUsernameAndPassword(db.GetTableName(clusterName))
+	if err != nil {
+		return
+	}
+	api.clusterName = clusterName
+	api.username, err = common.DecodeBase64(creds.Username)
+	if err != nil {
+		return
+	}
+	api.password, err = common.DecodeBase64(creds.Password)
+	return
+}
+
+// call uses the backends running right now, as they change while hostgroups scale
+func (w wekaApi) call(ctx context.Context, method weka.JrpcMethod, result interface{}) error {
+	ips, err := common.GetBackendsPrivateIps(string(w.clusterName))
+	if err != nil {
+		return err
+	}
+	if len(ips) == 0 {
+		return errors.New(fmt.Sprintf("no running backends were found for cluster %s", w.clusterName))
+	}
+	pool := &jrpc.Pool{
+		Ips:     ips,
+		Clients: map[string]*jrpc.BaseClient{},
+		Builder: func(ip string) *jrpc.BaseClient {
+			return connectors.NewJrpcClient(ctx, ip, weka.ManagementJrpcPort, w.username, w.password)
+		},
+		Ctx: ctx,
+	}
+	return pool.Call(method, struct{}{}, result)
+}
+
+func (w wekaApi) hostsList(ctx context.Context) (hosts weka.HostListResponse, err error) {
+	err = w.call(ctx, weka.JrpcHostList, &hosts)
+	return
+}
+
+func (w wekaApi) status(ctx context.Context) (status weka.StatusResponse, err error) {
+	err = w.call(ctx, weka.JrpcStatus, &status)
+	return
+}
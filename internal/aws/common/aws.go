@@ -2,7 +2,9 @@ package common
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/ec2"
@@ -16,6 +18,7 @@ import (
 	"sync"
 	"wekactl/internal/cluster"
 	"wekactl/internal/connectors"
+	"wekactl/internal/env"
 	strings2 "wekactl/internal/lib/strings"
 	"wekactl/internal/lib/types"
 )
@@ -25,7 +28,25 @@ type InstancePrivateIpsSet map[string]types.Nilt
 
 const RootFsMinimalSize = 16
 
+// RenderTable prints the rows as a table, or as a json list of objects keyed by fields with json output
 func RenderTable(fields []string, data [][]string) {
+	if env.Config.Output == env.OutputJson {
+		var rows []map[string]string
+		for _, values := range data {
+			row := map[string]string{}
+			for i, field := range fields {
+				row[field] = values[i]
+			}
+			rows = append(rows, row)
+		}
+		out, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			log.Error().Msgf("failed rendering json output: %v", err)
+			return
+		}
+		fmt.Println(string(out))
+		return
+	}
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader(fields)
 	table.SetRowLine(true)
@@ -176,6 +197,12 @@ func GetDeltaInstancesIds(instanceIds1 []*string, instanceIds2 []*string) (delta
 	return
 }
 
+// MinBackendsNumber is the smallest weka cluster wekactl manages
+const MinBackendsNumber = 5
+
+// WekaMetricsNamespace is the CloudWatch namespace of the metrics lambda, metrics have the ClusterName dimension
+const WekaMetricsNamespace = "Weka"
+
 func GetMaxSize(role InstanceRole, initialSize int) int64 {
 	var maxSize int
 	switch role {
@@ -382,8 +409,25 @@ func DeleteInstances(ids []string) (err error) {
 	return
 }
 
+func WaitForInstancesTermination(ids []string) error {
+	svc := connectors.GetAWSSession().EC2
+	for i := 0; i < len(ids); i += 50 {
+		err := svc.WaitUntilInstanceTerminated(&ec2.DescribeInstancesInput{
+			InstanceIds: strings2.ListToRefList(ids[i:Min(len(ids), i+50)]),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func GetAccountId() (string, error) {
-	svc := connectors.GetAWSSession().STS
+	return GetRegionAccountId(env.Config.Region)
+}
+
+func GetRegionAccountId(region string) (string, error) {
+	svc := connectors.GetRegionAWSSession(region).STS
 	result, err := svc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
 	if err != nil {
 		return "", err
@@ -450,3 +494,43 @@ func GetVolumeType(region string) string {
 	}
 	return "gp2"
 }
+
+// GetInstancesAttachmentIds returns the ids of the volumes and network interfaces attached to the instances
+func GetInstancesAttachmentIds(instances []*ec2.Instance) (ids []*string) {
+	for _, instance := range instances {
+		for _, blockDevice := range instance.BlockDeviceMappings {
+			if blockDevice.Ebs != nil {
+				ids = append(ids, blockDevice.Ebs.VolumeId)
+			}
+		}
+		for _, networkInterface := range instance.NetworkInterfaces {
+			ids = append(ids, networkInterface.NetworkInterfaceId)
+		}
+	}
+	return
+}
+
+// UpdateEc2Tags sets the tags on the ec2 resources (instances, volumes, launch templates, etc.) and removes the
+// removedKeys ones
+func UpdateEc2Tags(resourceIds []*string, tags []*ec2.Tag, removedKeys []string) error {
+	if len(resourceIds) == 0 {
+		return nil
+	}
+	svc := connectors.GetAWSSession().EC2
+	if len(removedKeys) > 0 {
+		var deletedTags []*ec2.Tag
+		for _, key := range removedKeys {
+			deletedTags = append(deletedTags, &ec2.Tag{Key: aws.String(key)})
+		}
+		_, err := svc.DeleteTags(&ec2.DeleteTagsInput{Resources: resourceIds, Tags: deletedTags})
+		if err != nil {
+			return err
+		}
+	}
+	_, err := svc.CreateTags(&ec2.CreateTagsInput{Resources: resourceIds, Tags: tags})
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("ec2 resources %v were tagged", strings2.RefListToList(resourceIds))
+	return nil
+}
@@ -0,0 +1,178 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/rs/zerolog/log"
+	"wekactl/internal/cluster"
+	"wekactl/internal/connectors"
+	"wekactl/internal/env"
+	"wekactl/internal/lib/strings"
+)
+
+func dynamoDBServiceName() string {
+	return fmt.Sprintf("com.amazonaws.%s.dynamodb", env.Config.Region)
+}
+
+func executeApiServiceName() string {
+	return fmt.Sprintf("com.amazonaws.%s.execute-api", env.Config.Region)
+}
+
+// GetExecuteApiEndpoints returns the available vpc execute-api interface endpoints, private join api gateways are
+// reachable only through them
+func GetExecuteApiEndpoints(vpcId string) (endpoints []*ec2.VpcEndpoint, err error) {
+	svc := connectors.GetAWSSession().EC2
+	err = svc.DescribeVpcEndpointsPages(&ec2.DescribeVpcEndpointsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{&vpcId},
+			},
+			{
+				Name:   aws.String("service-name"),
+				Values: []*string{aws.String(executeApiServiceName())},
+			},
+			{
+				Name:   aws.String("vpc-endpoint-type"),
+				Values: []*string{aws.String(ec2.VpcEndpointTypeInterface)},
+			},
+			{
+				Name:   aws.String("vpc-endpoint-state"),
+				Values: []*string{aws.String("available")},
+			},
+		},
+	}, func(page *ec2.DescribeVpcEndpointsOutput, lastPage bool) bool {
+		endpoints = append(endpoints, page.VpcEndpoints...)
+		return true
+	})
+	return
+}
+
+// GetSubnetRouteTableId returns the route table associated with the subnet, or the vpc main route table
+func GetSubnetRouteTableId(vpcId, subnetId string) (routeTableId string, err error) {
+	routeTables, err := GetRouteTables(vpcId)
+	if err != nil {
+		return
+	}
+	for _, routeTable := range routeTables {
+		for _, association := range routeTable.Associations {
+			if association.SubnetId != nil && *association.SubnetId == subnetId {
+				return *routeTable.RouteTableId, nil
+			}
+		}
+	}
+	for _, routeTable := range routeTables {
+		for _, association := range routeTable.Associations {
+			if association.Main != nil && *association.Main {
+				return *routeTable.RouteTableId, nil
+			}
+		}
+	}
+	err = errors.New(fmt.Sprintf("no route table was found for subnet %s", subnetId))
+	return
+}
+
+// GetDynamoDBEndpoints returns the vpc DynamoDB gateway endpoints
+func GetDynamoDBEndpoints(vpcId string) (endpoints []*ec2.VpcEndpoint, err error) {
+	svc := connectors.GetAWSSession().EC2
+	err = svc.DescribeVpcEndpointsPages(&ec2.DescribeVpcEndpointsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{&vpcId},
+			},
+			{
+				Name:   aws.String("service-name"),
+				Values: []*string{aws.String(dynamoDBServiceName())},
+			},
+			{
+				Name:   aws.String("vpc-endpoint-type"),
+				Values: []*string{aws.String(ec2.VpcEndpointTypeGateway)},
+			},
+			{
+				Name:   aws.String("vpc-endpoint-state"),
+				Values: []*string{aws.String("pending"), aws.String("available")},
+			},
+		},
+	}, func(page *ec2.DescribeVpcEndpointsOutput, lastPage bool) bool {
+		endpoints = append(endpoints, page.VpcEndpoints...)
+		return true
+	})
+	return
+}
+
+// GetClusterVpcEndpoints returns the vpc endpoints created by wekactl for the cluster
+func GetClusterVpcEndpoints(clusterName cluster.ClusterName) (endpoints []*ec2.VpcEndpoint, err error) {
+	svc := connectors.GetAWSSession().EC2
+	err = svc.DescribeVpcEndpointsPages(&ec2.DescribeVpcEndpointsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag:" + cluster.ClusterNameTagKey),
+				Values: []*string{aws.String(string(clusterName))},
+			},
+			{
+				Name:   aws.String("vpc-endpoint-state"),
+				Values: []*string{aws.String("pending"), aws.String("available")},
+			},
+		},
+	}, func(page *ec2.DescribeVpcEndpointsOutput, lastPage bool) bool {
+		endpoints = append(endpoints, page.VpcEndpoints...)
+		return true
+	})
+	return
+}
+
+func CreateDynamoDBEndpoint(vpcId string, routeTableIds []string, tags []*ec2.Tag) (endpointId string, err error) {
+	svc := connectors.GetAWSSession().EC2
+	output, err := svc.CreateVpcEndpoint(&ec2.CreateVpcEndpointInput{
+		VpcId:           &vpcId,
+		ServiceName:     aws.String(dynamoDBServiceName()),
+		VpcEndpointType: aws.String(ec2.VpcEndpointTypeGateway),
+		RouteTableIds:   strings.ListToRefList(routeTableIds),
+		TagSpecifications: []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String(ec2.ResourceTypeVpcEndpoint),
+				Tags:         tags,
+			},
+		},
+	})
+	if err != nil {
+		return
+	}
+	endpointId = *output.VpcEndpoint.VpcEndpointId
+	log.Debug().Msgf("DynamoDB vpc endpoint %s was created for route tables %v", endpointId, routeTableIds)
+	return
+}
+
+func AddVpcEndpointRouteTables(endpointId string, routeTableIds []string) error {
+	svc := connectors.GetAWSSession().EC2
+	_, err := svc.ModifyVpcEndpoint(&ec2.ModifyVpcEndpointInput{
+		VpcEndpointId:    &endpointId,
+		AddRouteTableIds: strings.ListToRefList(routeTableIds),
+	})
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("route tables %v were added to vpc endpoint %s", routeTableIds, endpointId)
+	return nil
+}
+
+func DeleteVpcEndpoints(endpointIds []string) error {
+	if len(endpointIds) == 0 {
+		return nil
+	}
+	svc := connectors.GetAWSSession().EC2
+	output, err := svc.DeleteVpcEndpoints(&ec2.DeleteVpcEndpointsInput{
+		VpcEndpointIds: strings.ListToRefList(endpointIds),
+	})
+	if err != nil {
+		return err
+	}
+	if len(output.Unsuccessful) > 0 {
+		return errors.New(fmt.Sprintf("failed deleting vpc endpoint %s: %s", *output.Unsuccessful[0].ResourceId, *output.Unsuccessful[0].Error.Message))
+	}
+	log.Debug().Msgf("vpc endpoints %v were deleted", endpointIds)
+	return nil
+}
@@ -0,0 +1,89 @@
+package common
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/rs/zerolog/log"
+	"strconv"
+	"time"
+	"wekactl/internal/cluster"
+	"wekactl/internal/connectors"
+)
+
+// NetworkInterfaceCreatedTagKey holds the unix time the additional nic was created by the backend user-data
+const NetworkInterfaceCreatedTagKey = "wekactl.io/created_at"
+
+// GetClusterNetworkInterfaces returns additional nics created by cluster backends, filtered by status unless it is empty
+func GetClusterNetworkInterfaces(clusterName cluster.ClusterName, status string) (networkInterfaces []*ec2.NetworkInterface, err error) {
+	svc := connectors.GetAWSSession().EC2
+	filters := []*ec2.Filter{
+		{
+			Name:   aws.String("tag:" + cluster.ClusterNameTagKey),
+			Values: []*string{aws.String(string(clusterName))},
+		},
+		{
+			Name:   aws.String("tag-key"),
+			Values: []*string{aws.String(NetworkInterfaceCreatedTagKey)},
+		},
+	}
+	if status != "" {
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String("status"),
+			Values: []*string{aws.String(status)},
+		})
+	}
+	err = svc.DescribeNetworkInterfacesPages(&ec2.DescribeNetworkInterfacesInput{Filters: filters},
+		func(page *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
+			networkInterfaces = append(networkInterfaces, page.NetworkInterfaces...)
+			return true
+		})
+	return
+}
+
+func networkInterfaceCreationTime(networkInterface *ec2.NetworkInterface) (creationTime time.Time, ok bool) {
+	for _, tag := range networkInterface.TagSet {
+		if *tag.Key == NetworkInterfaceCreatedTagKey {
+			seconds, err := strconv.ParseInt(*tag.Value, 10, 64)
+			if err != nil {
+				return
+			}
+			return time.Unix(seconds, 0), true
+		}
+	}
+	return
+}
+
+// GetLeakedNetworkInterfaces returns cluster nics that are not attached to any instance and are older than minAge
+func GetLeakedNetworkInterfaces(clusterName cluster.ClusterName, minAge time.Duration) (leaked []*ec2.NetworkInterface, err error) {
+	networkInterfaces, err := GetClusterNetworkInterfaces(clusterName, ec2.NetworkInterfaceStatusAvailable)
+	if err != nil {
+		return
+	}
+	for _, networkInterface := range networkInterfaces {
+		creationTime, ok := networkInterfaceCreationTime(networkInterface)
+		if ok && time.Since(creationTime) > minAge {
+			leaked = append(leaked, networkInterface)
+		}
+	}
+	return
+}
+
+func DeleteNetworkInterfaces(networkInterfaces []*ec2.NetworkInterface) (deleted []string, errs []error) {
+	svc := connectors.GetAWSSession().EC2
+	for _, networkInterface := range networkInterfaces {
+		_, err := svc.DeleteNetworkInterface(&ec2.DeleteNetworkInterfaceInput{
+			NetworkInterfaceId: networkInterface.NetworkInterfaceId,
+		})
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "InvalidNetworkInterfaceID.NotFound" {
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+		log.Debug().Msgf("network interface %s was deleted successfully", *networkInterface.NetworkInterfaceId)
+		deleted = append(deleted, *networkInterface.NetworkInterfaceId)
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/weka/go-cloud-lib/bash_functions"
+	"strings"
+)
+
+type FailureDomainStrategy string
+
+const (
+	FailureDomainHashedIp           FailureDomainStrategy = "hashed-ip"
+	FailureDomainAvailabilityZone   FailureDomainStrategy = "az"
+	FailureDomainPlacementPartition FailureDomainStrategy = "placement-partition"
+	FailureDomainCustom             FailureDomainStrategy = "custom"
+)
+
+var FailureDomainStrategies = []FailureDomainStrategy{
+	FailureDomainHashedIp,
+	FailureDomainAvailabilityZone,
+	FailureDomainPlacementPartition,
+	FailureDomainCustom,
+}
+
+// metadataCmd reads instance metadata path, works with both IMDSv1 and IMDSv2 (HttpTokens required)
+func metadataCmd(path string) string {
+	return fmt.Sprintf(`curl -sf -H "X-aws-ec2-metadata-token: $(curl -sf -X PUT -H 'X-aws-ec2-metadata-token-ttl-seconds: 60' http://169.254.169.254/latest/api/token)" http://169.254.169.254/latest/meta-data/%s`, path)
+}
+
+func ValidateFailureDomain(strategy FailureDomainStrategy, customCmd string) error {
+	valid := false
+	for _, s := range FailureDomainStrategies {
+		if strategy == s {
+			valid = true
+		}
+	}
+	if !valid {
+		return errors.New(fmt.Sprintf("unknown failure domain strategy '%s', supported: %v", strategy, FailureDomainStrategies))
+	}
+	if strategy == FailureDomainCustom && strings.TrimSpace(customCmd) == "" {
+		return errors.New("custom failure domain strategy requires a command")
+	}
+	if strategy != FailureDomainCustom && customCmd != "" {
+		return errors.New("failure domain command can be used only with custom strategy")
+	}
+	return nil
+}
+
+// GetFailureDomainCmd returns the bash command printing the weka failure domain of the joining instance.
+// Empty strategy is the historical hashed private ip behaviour.
+// Placement partition falls back to hashed ip on instances that are not in a partition placement group.
+func GetFailureDomainCmd(strategy FailureDomainStrategy, customCmd string) string {
+	switch strategy {
+	case FailureDomainAvailabilityZone:
+		return metadataCmd("placement/availability-zone")
+	case FailureDomainPlacementPartition:
+		return fmt.Sprintf("p=$(%s) && echo partition-$p || %s", metadataCmd("placement/partition-number"), bash_functions.GetHashedPrivateIpBashCmd())
+	case FailureDomainCustom:
+		return customCmd
+	default:
+		return bash_functions.GetHashedPrivateIpBashCmd()
+	}
+}
@@ -24,11 +24,21 @@ type HostGroupParams struct {
 	IamArn            string
 	InstanceType      string
 	Subnet            string
+	Subnets           []string // all hostgroup subnets, Subnet is the primary one
 	VolumesInfo       []VolumeInfo
 	MaxSize           int64
 	HttpTokens        string
 }
 
+// GetSubnets returns the hostgroup auto scaling group subnets, hostgroups imported before multiple subnets
+// were supported have only the primary one
+func (p HostGroupParams) GetSubnets() []string {
+	if len(p.Subnets) == 0 {
+		return []string{p.Subnet}
+	}
+	return p.Subnets
+}
+
 type HostGroupInfo struct {
 	ClusterName cluster.ClusterName
 	Role        InstanceRole
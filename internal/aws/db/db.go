@@ -9,6 +9,7 @@ import (
 	"wekactl/internal/aws/common"
 	"wekactl/internal/cluster"
 	"wekactl/internal/connectors"
+	"wekactl/internal/env"
 	"wekactl/internal/logging"
 )
 
@@ -27,7 +28,11 @@ func PutItem(tableName string, item interface{}) error {
 }
 
 func GetItem(tableName string, key string, item interface{}) error {
-	svc := connectors.GetAWSSession().DynamoDB
+	return GetRegionItem(env.Config.Region, tableName, key, item)
+}
+
+func GetRegionItem(region, tableName string, key string, item interface{}) error {
+	svc := connectors.GetRegionAWSSession(region).DynamoDB
 	result, err := svc.GetItem(&dynamodb.GetItemInput{
 		TableName: aws.String(tableName),
 		Key: map[string]*dynamodb.AttributeValue{
@@ -216,48 +221,82 @@ func GetTableName(name cluster.ClusterName) string {
 }
 
 func GetClusterSettings(name cluster.ClusterName) (clusterSettings ClusterSettings, err error) {
-	err = GetItem(GetTableName(name), ModelClusterSettings, &clusterSettings)
+	return GetRegionClusterSettings(env.Config.Region, name)
+}
+
+func GetRegionClusterSettings(region string, name cluster.ClusterName) (clusterSettings ClusterSettings, err error) {
+	err = GetRegionItem(region, GetTableName(name), ModelClusterSettings, &clusterSettings)
+	return
+}
+
+func getTableClusterName(svc *dynamodb.DynamoDB, tableArn *string) (clusterName cluster.ClusterName, err error) {
+	tagsOutput, err := svc.ListTagsOfResource(&dynamodb.ListTagsOfResourceInput{
+		ResourceArn: tableArn,
+	})
 	if err != nil {
 		return
 	}
+	for _, tag := range tagsOutput.Tags {
+		if *tag.Key == cluster.ClusterNameTagKey {
+			clusterName = cluster.ClusterName(*tag.Value)
+			return
+		}
+	}
 	return
 }
 
-func GetClusterDb(clusterName cluster.ClusterName) (table *dynamodb.TableDescription, err error) {
-	svc := connectors.GetAWSSession().DynamoDB
-
-	tablesList, err := svc.ListTables(&dynamodb.ListTablesInput{})
+// forEachClusterTable calls f with every wekactl table of the region, until f returns true
+func forEachClusterTable(region string, f func(clusterName cluster.ClusterName, table *dynamodb.TableDescription) bool) (err error) {
+	svc := connectors.GetRegionAWSSession(region).DynamoDB
+	var tableNames []*string
+	err = svc.ListTablesPages(&dynamodb.ListTablesInput{}, func(page *dynamodb.ListTablesOutput, lastPage bool) bool {
+		tableNames = append(tableNames, page.TableNames...)
+		return true
+	})
 	if err != nil {
 		return
 	}
 
-	var tableOutput *dynamodb.DescribeTableOutput
-	var tagsOutput *dynamodb.ListTagsOfResourceOutput
-
-	for _, tableName := range tablesList.TableNames {
+	for _, tableName := range tableNames {
+		if !strings.HasPrefix(*tableName, GetTableName("")) {
+			continue
+		}
+		var tableOutput *dynamodb.DescribeTableOutput
 		tableOutput, err = svc.DescribeTable(&dynamodb.DescribeTableInput{
 			TableName: tableName,
 		})
-
 		if err != nil {
 			return
 		}
-
-		tagsOutput, err = svc.ListTagsOfResource(&dynamodb.ListTagsOfResourceInput{
-			ResourceArn: tableOutput.Table.TableArn,
-		})
+		var clusterName cluster.ClusterName
+		clusterName, err = getTableClusterName(svc, tableOutput.Table.TableArn)
 		if err != nil {
 			return
 		}
-
-		for _, tag := range tagsOutput.Tags {
-			if *tag.Key == cluster.ClusterNameTagKey && *tag.Value == string(clusterName) {
-				table = tableOutput.Table
-				return
-			}
+		if clusterName != "" && f(clusterName, tableOutput.Table) {
+			return
 		}
 	}
+	return
+}
+
+func GetClusterDb(clusterName cluster.ClusterName) (table *dynamodb.TableDescription, err error) {
+	err = forEachClusterTable(env.Config.Region, func(tableClusterName cluster.ClusterName, tableDescription *dynamodb.TableDescription) bool {
+		if tableClusterName == clusterName {
+			table = tableDescription
+			return true
+		}
+		return false
+	})
+	return
+}
 
+// GetClusterNames returns the names of the clusters managed by wekactl in the region, by their DB tables
+func GetClusterNames(region string) (clusterNames []cluster.ClusterName, err error) {
+	err = forEachClusterTable(region, func(clusterName cluster.ClusterName, table *dynamodb.TableDescription) bool {
+		clusterNames = append(clusterNames, clusterName)
+		return false
+	})
 	return
 }
 
@@ -278,3 +317,115 @@ func GetUsernameAndPassword(tableName string) (creds ClusterCreds, err error) {
 	}
 	return
 }
+
+func DeleteItem(tableName string, key string) error {
+	svc := connectors.GetAWSSession().DynamoDB
+	_, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Key": {
+				S: aws.String(key),
+			},
+		},
+	})
+	return err
+}
+
+func SaveJoinHooks(tableName string, hooks JoinHooks) error {
+	hooks.Key = ModelJoinHooks
+	err := PutItem(tableName, hooks)
+	if err != nil {
+		log.Debug().Msgf("error saving join hooks to DB %v", err)
+		return err
+	}
+	log.Debug().Msgf("join hooks were added to DB successfully!")
+	return nil
+}
+
+// GetJoinHooks returns empty hooks if none were set
+func GetJoinHooks(tableName string) (hooks JoinHooks, err error) {
+	err = GetItem(tableName, ModelJoinHooks, &hooks)
+	if err == NoItemFound {
+		err = nil
+	}
+	return
+}
+
+func ClearJoinHooks(tableName string) error {
+	return DeleteItem(tableName, ModelJoinHooks)
+}
+
+func hostGroupRollKey(hostGroupName string) string {
+	return ModelHostGroupRoll + "-" + hostGroupName
+}
+
+func SaveHostGroupRoll(tableName, hostGroupName string, roll HostGroupRoll) error {
+	roll.Key = hostGroupRollKey(hostGroupName)
+	err := PutItem(tableName, roll)
+	if err != nil {
+		log.Debug().Msgf("error saving hostgroup %s roll to DB %v", hostGroupName, err)
+		return err
+	}
+	log.Debug().Msgf("hostgroup %s roll was saved to DB successfully!", hostGroupName)
+	return nil
+}
+
+// GetHostGroupRoll returns roll with empty key if no roll is in progress
+func GetHostGroupRoll(tableName, hostGroupName string) (roll HostGroupRoll, err error) {
+	err = GetItem(tableName, hostGroupRollKey(hostGroupName), &roll)
+	if err == NoItemFound {
+		err = nil
+	}
+	return
+}
+
+func DeleteHostGroupRoll(tableName, hostGroupName string) error {
+	return DeleteItem(tableName, hostGroupRollKey(hostGroupName))
+}
+
+func hostGroupAutoscalingKey(hostGroupName string) string {
+	return ModelHostGroupAutoscaling + "-" + hostGroupName
+}
+
+func SaveHostGroupAutoscaling(tableName, hostGroupName string, autoscaling HostGroupAutoscaling) error {
+	autoscaling.Key = hostGroupAutoscalingKey(hostGroupName)
+	err := PutItem(tableName, autoscaling)
+	if err != nil {
+		log.Debug().Msgf("error saving hostgroup %s autoscaling to DB %v", hostGroupName, err)
+		return err
+	}
+	log.Debug().Msgf("hostgroup %s autoscaling was saved to DB successfully!", hostGroupName)
+	return nil
+}
+
+// GetHostGroupAutoscaling returns autoscaling with empty key if none was set
+func GetHostGroupAutoscaling(tableName, hostGroupName string) (autoscaling HostGroupAutoscaling, err error) {
+	err = GetItem(tableName, hostGroupAutoscalingKey(hostGroupName), &autoscaling)
+	if err == NoItemFound {
+		err = nil
+	}
+	return
+}
+
+func DeleteHostGroupAutoscaling(tableName, hostGroupName string) error {
+	return DeleteItem(tableName, hostGroupAutoscalingKey(hostGroupName))
+}
+
+// UpdateDbTags sets the given tags on the cluster table and removes the removedKeys ones
+func UpdateDbTags(clusterName cluster.ClusterName, tags cluster.Tags, removedKeys []string) error {
+	table, err := GetClusterDb(clusterName)
+	if err != nil {
+		return err
+	}
+	svc := connectors.GetAWSSession().DynamoDB
+	if len(removedKeys) > 0 {
+		_, err = svc.UntagResource(&dynamodb.UntagResourceInput{
+			ResourceArn: table.TableArn,
+			TagKeys:     aws.StringSlice(removedKeys),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return UpdateDbVersion(clusterName, tags)
+}
@@ -8,6 +8,9 @@ import (
 
 const ModelClusterCreds = "cluster-creds"
 const ModelClusterSettings = "cluster-settings"
+const ModelJoinHooks = "join-hooks"
+const ModelHostGroupRoll = "hostgroup-roll"
+const ModelHostGroupAutoscaling = "hostgroup-autoscaling"
 
 var NoItemFound = errors.New("no item found in db")
 
@@ -28,9 +31,21 @@ type ClusterSettings struct {
 	PrivateSubnet       bool
 	StackId             *string // != nil in case it is created from CF stack
 	BuildVersion        string
+	LambdasID           string // lambda package deployed by the last import or update
 	DnsAlias            string
 	DnsZoneId           string
 	UseDynamoDBEndpoint bool
+	FailureDomain       common.FailureDomainStrategy
+	FailureDomainCmd    string
+	// IAM roles constraints set on import, applied to every role wekactl creates
+	IamRolesPath           string // roles are created under <IamRolesPath>wekactl/<cluster>/, "/" when empty
+	IamRolesNamePrefix     string
+	IamPermissionsBoundary string
+	// IamRoles are existing roles supplied on import by role name, e.g. "scale", they are externally owned, wekactl never
+	// creates, changes or deletes them
+	IamRoles map[string]string
+	// RecordTarget is the s3://bucket/prefix the scale lambdas record the weka management traffic to, off when empty
+	RecordTarget string
 }
 
 func (c ClusterSettings) Tags() cluster.Tags {
@@ -41,7 +56,48 @@ func (c ClusterSettings) UsePrivateSubnet() bool {
 	return c.PrivateSubnet
 }
 
+// JoinHooks are site specific additions rendered by the join lambda into the backend join script.
+// PreJoin runs after the base instance setup and before weka installation, PostJoin after the drives were added,
+// Functions override the bodies of the go-cloud-lib functions (report, join_finalization, ...) which are no-ops by default.
+type JoinHooks struct {
+	Key              string
+	PreJoin          string
+	PostJoin         string
+	FindDrivesScript string
+	Functions        map[string]string
+}
+
+func (h JoinHooks) IsEmpty() bool {
+	return h.PreJoin == "" && h.PostJoin == "" && h.FindDrivesScript == "" && len(h.Functions) == 0
+}
+
 type ResourceVersion struct {
 	Key     string
 	Version string
 }
+
+// HostGroupRoll is the progress of a hostgroup instances replacement, kept so that an interrupted roll can be resumed.
+// Capacity is the desired capacity before the roll, OldInstanceIds are the instances to replace.
+type HostGroupRoll struct {
+	Key            string
+	InstanceType   string
+	ImageID        string
+	BatchSize      int64
+	Capacity       int64
+	OldInstanceIds []string
+	Phase          string
+}
+
+// HostGroupAutoscaling is the scaling policy managed by wekactl for a hostgroup, PolicyType is target-tracking or step,
+// Target is the metric value kept by target tracking, or the step scaling alarm threshold. Cooldown is in seconds.
+type HostGroupAutoscaling struct {
+	Key        string
+	PolicyType string
+	Metric     string
+	Target     float64
+	Min        int64
+	Max        int64
+	Cooldown   int64
+	// ScaleInTarget is the step policy threshold below which an instance is removed, half of Target when 0
+	ScaleInTarget float64 `json:",omitempty"`
+}
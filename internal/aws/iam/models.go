@@ -4,12 +4,23 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"path"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/cluster"
 )
 
+// Condition maps a condition operator to its condition keys and values
+type Condition map[string]map[string]string
+
 type StatementEntry struct {
-	Effect   string
-	Action   []string
-	Resource string
+	Effect    string
+	Action    []string
+	Resource  []string
+	Condition Condition `json:",omitempty"`
+	// SimulatedAction lists the API operations the lambda calls under the wildcard actions, the policy simulator only
+	// evaluates concrete operations. It is not part of the policy document
+	SimulatedAction []string `json:"-"`
 }
 
 type PolicyDocument struct {
@@ -33,6 +44,77 @@ type AssumeRolePolicyDocument struct {
 	Statement []PolicyStatement
 }
 
+// Scope restricts the generated policies to the resources of a single cluster hostgroup. The roles and the cluster
+// resources are in the same account, so the ARNs leave the account out.
+type Scope struct {
+	Region      string
+	ClusterName cluster.ClusterName
+	TableName   string
+	ASGName     string
+	// RecordBucket and RecordPrefix locate the scale executions recordings, RecordBucket is empty when not recording
+	RecordBucket string
+	RecordPrefix string
+}
+
+func (s Scope) arn(service, resource string) string {
+	return fmt.Sprintf("arn:aws:%s:%s:*:%s", service, s.Region, resource)
+}
+
+// clusterTagCondition matches resources tagged with the cluster name, e.g. the hostgroups instances and the KMS key
+func (s Scope) clusterTagCondition() Condition {
+	return Condition{
+		"StringEquals": {"aws:ResourceTag/" + cluster.ClusterNameTagKey: string(s.ClusterName)},
+	}
+}
+
+func (s Scope) lambdaLogsStatement(lambdaName string) StatementEntry {
+	logGroupArn := s.arn("logs", "log-group:/aws/lambda/"+lambdaName)
+	return StatementEntry{
+		Effect: "Allow",
+		Action: []string{
+			"logs:CreateLogStream",
+			"logs:PutLogEvents",
+			"logs:CreateLogGroup",
+		},
+		Resource: []string{logGroupArn, logGroupArn + ":*"},
+	}
+}
+
+func (s Scope) tableReadStatement() StatementEntry {
+	return StatementEntry{
+		Effect: "Allow",
+		Action: []string{
+			"dynamodb:GetItem",
+		},
+		Resource: []string{s.arn("dynamodb", "table/"+s.TableName)},
+	}
+}
+
+func (s Scope) kmsDecryptStatement() StatementEntry {
+	return StatementEntry{
+		Effect: "Allow",
+		Action: []string{
+			"kms:Decrypt",
+		},
+		Resource:  []string{s.arn("kms", "key/*")},
+		Condition: s.clusterTagCondition(),
+	}
+}
+
+// vpcLambdaStatement is required by the scale and metrics lambdas, which run in the cluster VPC, these actions don't
+// support resource-level permissions
+func vpcLambdaStatement() StatementEntry {
+	return StatementEntry{
+		Effect: "Allow",
+		Action: []string{
+			"ec2:CreateNetworkInterface",
+			"ec2:DescribeNetworkInterfaces",
+			"ec2:DeleteNetworkInterface",
+		},
+		Resource: []string{"*"},
+	}
+}
+
 func (p PolicyDocument) Bytes() []byte {
 	if p.Version == "" {
 		p.Version = p.VersionHash()
@@ -102,22 +184,24 @@ func GetLambdaAssumeRolePolicy() AssumeRolePolicyDocument {
 	return policyDocument
 }
 
-func GetJoinAndFetchLambdaPolicy() PolicyDocument {
+func GetJoinAndFetchLambdaPolicy(scope Scope, lambdaName string) PolicyDocument {
 	policyDocument := PolicyDocument{
 		Version: "2012-10-17",
 		Statement: []StatementEntry{
+			scope.lambdaLogsStatement(lambdaName),
+			scope.tableReadStatement(),
+			scope.kmsDecryptStatement(),
 			{
 				Effect: "Allow",
 				Action: []string{
-					"logs:CreateLogStream",
-					"logs:PutLogEvents",
-					"logs:CreateLogGroup",
-					"dynamodb:GetItem",
 					"autoscaling:Describe*",
 					"ec2:Describe*",
-					"kms:Decrypt",
 				},
-				Resource: "*",
+				Resource: []string{"*"},
+				SimulatedAction: []string{
+					"autoscaling:DescribeAutoScalingGroups",
+					"ec2:DescribeInstances",
+				},
 			},
 		},
 	}
@@ -142,7 +226,12 @@ func GetStateMachineAssumeRolePolicy() AssumeRolePolicyDocument {
 	return policyDocument
 }
 
-func GetStateMachineRolePolicy() PolicyDocument {
+func GetStateMachineRolePolicy(scope Scope, lambdasNames []string) PolicyDocument {
+	var lambdasArns []string
+	for _, name := range lambdasNames {
+		lambdaArn := scope.arn("lambda", "function:"+name)
+		lambdasArns = append(lambdasArns, lambdaArn, lambdaArn+":*")
+	}
 	policyDocument := PolicyDocument{
 		Version: "2012-10-17",
 		Statement: []StatementEntry{
@@ -151,57 +240,108 @@ func GetStateMachineRolePolicy() PolicyDocument {
 				Action: []string{
 					"lambda:InvokeFunction",
 				},
-				Resource: "*",
+				Resource: lambdasArns,
 			},
 		},
 	}
 	return policyDocument
 }
 
-func GetScaleLambdaPolicy() PolicyDocument {
+func GetScaleLambdaPolicy(scope Scope, lambdaName string) PolicyDocument {
+	policyDocument := PolicyDocument{
+		Version: "2012-10-17",
+		Statement: []StatementEntry{
+			scope.lambdaLogsStatement(lambdaName),
+			scope.tableReadStatement(),
+			scope.kmsDecryptStatement(),
+			vpcLambdaStatement(),
+		},
+	}
+	if scope.RecordBucket != "" {
+		policyDocument.Statement = append(policyDocument.Statement, StatementEntry{
+			Effect: "Allow",
+			Action: []string{
+				"s3:PutObject",
+			},
+			Resource: []string{"arn:aws:s3:::" + path.Join(scope.RecordBucket, scope.RecordPrefix, "*")},
+		})
+	}
+	return policyDocument
+}
+
+func GetMetricsLambdaPolicy(scope Scope, lambdaName string) PolicyDocument {
 	policyDocument := PolicyDocument{
 		Version: "2012-10-17",
 		Statement: []StatementEntry{
+			scope.lambdaLogsStatement(lambdaName),
+			scope.tableReadStatement(),
+			scope.kmsDecryptStatement(),
+			vpcLambdaStatement(),
 			{
 				Effect: "Allow",
 				Action: []string{
-					"logs:CreateLogStream",
-					"logs:PutLogEvents",
-					"logs:CreateLogGroup",
-					"ec2:CreateNetworkInterface",
-					"ec2:DescribeNetworkInterfaces",
-					"ec2:DeleteNetworkInterface",
-					"dynamodb:GetItem",
-					"kms:Decrypt",
+					"ec2:DescribeInstances",
+				},
+				Resource: []string{"*"},
+			},
+			{
+				Effect: "Allow",
+				Action: []string{
+					"cloudwatch:PutMetricData",
+				},
+				Resource: []string{"*"},
+				Condition: Condition{
+					"StringEquals": {"cloudwatch:namespace": common.WekaMetricsNamespace},
 				},
-				Resource: "*",
 			},
 		},
 	}
 	return policyDocument
 }
 
-func GetTerminateLambdaPolicy() PolicyDocument {
+func GetTerminateLambdaPolicy(scope Scope, lambdaName string) PolicyDocument {
 	policyDocument := PolicyDocument{
 		Version: "2012-10-17",
 		Statement: []StatementEntry{
+			scope.lambdaLogsStatement(lambdaName),
 			{
+				// network interfaces leaked by failed joins, tagged on creation by the instances
 				Effect: "Allow",
 				Action: []string{
-					"logs:CreateLogStream",
-					"logs:PutLogEvents",
-					"logs:CreateLogGroup",
-					"ec2:CreateNetworkInterface",
-					"ec2:DescribeNetworkInterfaces",
 					"ec2:DeleteNetworkInterface",
+				},
+				Resource:  []string{scope.arn("ec2", "network-interface/*")},
+				Condition: scope.clusterTagCondition(),
+			},
+			{
+				Effect: "Allow",
+				Action: []string{
 					"ec2:ModifyInstanceAttribute",
 					"ec2:TerminateInstances",
+				},
+				Resource:  []string{scope.arn("ec2", "instance/*")},
+				Condition: scope.clusterTagCondition(),
+			},
+			{
+				Effect: "Allow",
+				Action: []string{
 					"autoscaling:DetachInstances",
-					"autoscaling:Describe*",
 					"autoscaling:SetInstanceProtection",
+				},
+				Resource: []string{scope.arn("autoscaling", "autoScalingGroup:*:autoScalingGroupName/"+scope.ASGName)},
+			},
+			{
+				Effect: "Allow",
+				Action: []string{
+					"autoscaling:Describe*",
 					"ec2:Describe*",
 				},
-				Resource: "*",
+				Resource: []string{"*"},
+				SimulatedAction: []string{
+					"autoscaling:DescribeAutoScalingGroups",
+					"ec2:DescribeInstances",
+					"ec2:DescribeNetworkInterfaces",
+				},
 			},
 		},
 	}
@@ -226,7 +366,7 @@ func GetCloudWatchEventAssumeRolePolicy() AssumeRolePolicyDocument {
 	return policyDocument
 }
 
-func GetCloudWatchEventRolePolicy() PolicyDocument {
+func GetCloudWatchEventRolePolicy(scope Scope, stateMachineName string) PolicyDocument {
 	policyDocument := PolicyDocument{
 		Version: "2012-10-17",
 		Statement: []StatementEntry{
@@ -235,7 +375,7 @@ func GetCloudWatchEventRolePolicy() PolicyDocument {
 				Action: []string{
 					"states:StartExecution",
 				},
-				Resource: "*",
+				Resource: []string{scope.arn("states", "stateMachine:"+stateMachineName)},
 			},
 		},
 	}
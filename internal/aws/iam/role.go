@@ -2,15 +2,22 @@ package iam
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/semaphore"
+	"net/url"
+	"reflect"
+	"regexp"
 	"strings"
 	"sync"
 	"wekactl/internal/cluster"
 	"wekactl/internal/connectors"
+	strings2 "wekactl/internal/lib/strings"
 )
 
 func attachIamPolicy(roleName, policyName string, policy PolicyDocument) error {
@@ -28,16 +35,61 @@ func attachIamPolicy(roleName, policyName string, policy PolicyDocument) error {
 	return nil
 }
 
-func CreateIamRole(clusterName cluster.ClusterName, tags []*iam.Tag, roleName, policyName string, assumeRolePolicy AssumeRolePolicyDocument, policy PolicyDocument) (*string, error) {
+const maxRolesNamePrefixLen = 16
+
+var rolesPathRe = regexp.MustCompile(`^/([\x21-\x7E]+/)?$`)
+var rolesNamePrefixRe = regexp.MustCompile(`^[\w+=,.@-]*$`)
+var permissionsBoundaryRe = regexp.MustCompile(`^arn:aws[\w-]*:iam::(\d{12}|aws):policy/.+$`)
+var roleArnRe = regexp.MustCompile(`^arn:aws[\w-]*:iam::\d{12}:role/([\x21-\x7E]+/)?[\w+=,.@-]{1,64}$`)
+
+// RolesPath is the path of the cluster roles, wekactl finds its roles by it. The default path prefix is "/"
+func RolesPath(clusterName cluster.ClusterName, pathPrefix string) string {
+	if pathPrefix == "" {
+		pathPrefix = "/"
+	}
+	return fmt.Sprintf("%swekactl/%s/", pathPrefix, clusterName)
+}
+
+// ValidateRoleOptions checks the roles path prefix, name prefix and permissions boundary before any role is created
+func ValidateRoleOptions(pathPrefix, namePrefix, permissionsBoundary string) error {
+	if pathPrefix != "" && !rolesPathRe.MatchString(pathPrefix) {
+		return errors.New(fmt.Sprintf("invalid iam roles path %q, the path must begin and end with '/', e.g. /platform/", pathPrefix))
+	}
+	if len(namePrefix) > maxRolesNamePrefixLen || !rolesNamePrefixRe.MatchString(namePrefix) {
+		return errors.New(fmt.Sprintf("invalid iam roles name prefix %q, up to %d alphanumeric or +=,.@_- characters are allowed", namePrefix, maxRolesNamePrefixLen))
+	}
+	if permissionsBoundary != "" && !permissionsBoundaryRe.MatchString(permissionsBoundary) {
+		return errors.New(fmt.Sprintf("invalid permissions boundary %q, an iam policy arn is expected", permissionsBoundary))
+	}
+	return nil
+}
+
+// ValidateRoleArn checks the arn of a role supplied on import instead of one wekactl creates
+func ValidateRoleArn(roleArn string) error {
+	if !roleArnRe.MatchString(roleArn) {
+		return errors.New(fmt.Sprintf("invalid iam role %q, an iam role arn is expected", roleArn))
+	}
+	return nil
+}
+
+// RoleNameFromArn returns the role name, which follows the role path
+func RoleNameFromArn(roleArn string) string {
+	return roleArn[strings.LastIndex(roleArn, "/")+1:]
+}
+
+func CreateIamRole(rolesPath, permissionsBoundary string, tags []*iam.Tag, roleName, policyName string, assumeRolePolicy AssumeRolePolicyDocument, policy PolicyDocument) (*string, error) {
 	log.Debug().Msgf("creating role %s", roleName)
 	svc := connectors.GetAWSSession().IAM
 	input := &iam.CreateRoleInput{
 		AssumeRolePolicyDocument: aws.String(assumeRolePolicy.String()),
-		Path:                     aws.String(fmt.Sprintf("/wekactl/%s/", clusterName)),
+		Path:                     aws.String(rolesPath),
 		//max roleName length must be 64 characters
 		RoleName: aws.String(roleName),
 		Tags:     tags,
 	}
+	if permissionsBoundary != "" {
+		input.PermissionsBoundary = aws.String(permissionsBoundary)
+	}
 
 	result, err := svc.CreateRole(input)
 	if err != nil {
@@ -60,12 +112,12 @@ func CreateIamRole(clusterName cluster.ClusterName, tags []*iam.Tag, roleName, p
 	return result.Role.Arn, nil
 }
 
-func getIamRole(clusterName cluster.ClusterName, roleBaseName string, marker *string) (iamRole *iam.Role, err error) {
+func getIamRole(rolesPath, roleBaseName string, marker *string) (iamRole *iam.Role, err error) {
 	svc := connectors.GetAWSSession().IAM
 
 	rolesOutput, err := svc.ListRoles(&iam.ListRolesInput{
 		Marker:     marker,
-		PathPrefix: aws.String(fmt.Sprintf("/wekactl/%s/", clusterName)),
+		PathPrefix: aws.String(rolesPath),
 	})
 	if err != nil {
 		return
@@ -77,7 +129,7 @@ func getIamRole(clusterName cluster.ClusterName, roleBaseName string, marker *st
 	}
 
 	if *rolesOutput.IsTruncated {
-		return getIamRole(clusterName, roleBaseName, rolesOutput.Marker)
+		return getIamRole(rolesPath, roleBaseName, rolesOutput.Marker)
 	}
 
 	return
@@ -107,10 +159,10 @@ func removeRolePolicy(role *iam.Role) error {
 	return nil
 }
 
-func DeleteIamRole(clusterName cluster.ClusterName, roleBaseName string) error {
+func DeleteIamRole(rolesPath, roleBaseName string) error {
 	svc := connectors.GetAWSSession().IAM
 	log.Debug().Msgf("fetching role %s ...", roleBaseName)
-	role, err := getIamRole(clusterName, roleBaseName, nil)
+	role, err := getIamRole(rolesPath, roleBaseName, nil)
 	if err != nil {
 		return err
 	}
@@ -133,8 +185,8 @@ func DeleteIamRole(clusterName cluster.ClusterName, roleBaseName string) error {
 
 }
 
-func GetIamRoleName(clusterName cluster.ClusterName, roleBaseName string) (roleName string, err error) {
-	role, err := getIamRole(clusterName, roleBaseName, nil)
+func GetIamRoleName(rolesPath, roleBaseName string) (roleName string, err error) {
+	role, err := getIamRole(rolesPath, roleBaseName, nil)
 	if err != nil || role == nil {
 		return
 	}
@@ -157,8 +209,8 @@ func GetIamRoleVersion(roleName string) (version string, err error) {
 	return
 }
 
-func GetIamRoleArn(clusterName cluster.ClusterName, roleBaseName string) (arn string, err error) {
-	role, err := getIamRole(clusterName, roleBaseName, nil)
+func GetIamRoleArn(rolesPath, roleBaseName string) (arn string, err error) {
+	role, err := getIamRole(rolesPath, roleBaseName, nil)
 	if err != nil || role == nil {
 		return
 	}
@@ -166,8 +218,8 @@ func GetIamRoleArn(clusterName cluster.ClusterName, roleBaseName string) (arn st
 	return
 }
 
-func UpdateRolePolicy(clusterName cluster.ClusterName, roleBaseName, policyName string, policy PolicyDocument, versionTag []*iam.Tag) error {
-	role, err := getIamRole(clusterName, roleBaseName, nil)
+func UpdateRolePolicy(rolesPath, roleBaseName, policyName string, policy PolicyDocument, versionTag []*iam.Tag) error {
+	role, err := getIamRole(rolesPath, roleBaseName, nil)
 	if err != nil {
 		return err
 	}
@@ -189,7 +241,7 @@ func UpdateRolePolicy(clusterName cluster.ClusterName, roleBaseName, policyName
 	return err
 }
 
-func getRoles(clusterName cluster.ClusterName) (roles []*iam.Role, err error) {
+func getRoles(rolesPath string) (roles []*iam.Role, err error) {
 	var marker *string
 	isTruncated := true
 	var rolesOutput *iam.ListRolesOutput
@@ -200,7 +252,7 @@ func getRoles(clusterName cluster.ClusterName) (roles []*iam.Role, err error) {
 	for isTruncated {
 		rolesOutput, err = svc.ListRoles(&iam.ListRolesInput{
 			Marker:     marker,
-			PathPrefix: aws.String(fmt.Sprintf("/wekactl/%s/", clusterName)),
+			PathPrefix: aws.String(rolesPath),
 		})
 		if err != nil {
 			return
@@ -234,11 +286,11 @@ func init() {
 	tagsSemaphore = semaphore.NewWeighted(20)
 }
 
-func GetClusterRoles(clusterName cluster.ClusterName) (clusterRoles []*iam.Role, err error) {
+func GetClusterRoles(clusterName cluster.ClusterName, rolesPath string) (clusterRoles []*iam.Role, err error) {
 	var wg sync.WaitGroup
 	var responseLock sync.Mutex
 
-	roles, err := getRoles(clusterName)
+	roles, err := getRoles(rolesPath)
 	if err != nil {
 		return
 	}
@@ -271,9 +323,9 @@ func GetClusterRoles(clusterName cluster.ClusterName) (clusterRoles []*iam.Role,
 	return
 }
 
-func DeleteRoles(clusterName cluster.ClusterName, roles []*iam.Role) error {
+func DeleteRoles(rolesPath string, roles []*iam.Role) error {
 	for _, role := range roles {
-		err := DeleteIamRole(clusterName, *role.RoleName)
+		err := DeleteIamRole(rolesPath, *role.RoleName)
 		if err != nil {
 			return err
 		}
@@ -281,3 +333,246 @@ func DeleteRoles(clusterName cluster.ClusterName, roles []*iam.Role) error {
 
 	return nil
 }
+
+// equalPolicyDocuments compares two json policy documents regardless of their formatting, iam returns documents url encoded
+func equalPolicyDocuments(livePolicy, policy string) bool {
+	decoded, err := url.QueryUnescape(livePolicy)
+	if err != nil {
+		return false
+	}
+	var liveDocument, document interface{}
+	if json.Unmarshal([]byte(decoded), &liveDocument) != nil || json.Unmarshal([]byte(policy), &document) != nil {
+		return false
+	}
+	return reflect.DeepEqual(liveDocument, document)
+}
+
+// GetRoleDrift compares the role trust policy and inline policies with the ones wekactl generates, wekactl never
+// attaches managed policies, so the ones attached to the role were attached on purpose and are not reported
+func GetRoleDrift(roleName, policyName, permissionsBoundary string, assumeRolePolicy AssumeRolePolicyDocument, policy PolicyDocument) (drifts []string, err error) {
+	svc := connectors.GetAWSSession().IAM
+
+	roleOutput, err := svc.GetRole(&iam.GetRoleInput{RoleName: &roleName})
+	if err != nil {
+		return
+	}
+	if !equalPolicyDocuments(*roleOutput.Role.AssumeRolePolicyDocument, assumeRolePolicy.String()) {
+		drifts = append(drifts, "assume role policy document differs")
+	}
+	if liveBoundary := rolePermissionsBoundary(roleOutput.Role); liveBoundary != permissionsBoundary {
+		drifts = append(drifts, fmt.Sprintf("permissions boundary is %q instead of %q", liveBoundary, permissionsBoundary))
+	}
+
+	policiesOutput, err := svc.ListRolePolicies(&iam.ListRolePoliciesInput{RoleName: &roleName})
+	if err != nil {
+		return
+	}
+	found := false
+	for _, name := range policiesOutput.PolicyNames {
+		if *name != policyName || policy.Version == "" {
+			drifts = append(drifts, fmt.Sprintf("unexpected inline policy %s", *name))
+			continue
+		}
+		found = true
+		var policyOutput *iam.GetRolePolicyOutput
+		policyOutput, err = svc.GetRolePolicy(&iam.GetRolePolicyInput{RoleName: &roleName, PolicyName: name})
+		if err != nil {
+			return
+		}
+		if !equalPolicyDocuments(*policyOutput.PolicyDocument, policy.String()) {
+			drifts = append(drifts, fmt.Sprintf("inline policy %s document differs", policyName))
+		}
+	}
+	if !found && policy.Version != "" {
+		drifts = append(drifts, fmt.Sprintf("inline policy %s is missing", policyName))
+	}
+	return
+}
+
+// ReconcileRole restores the role trust policy and permissions boundary and puts back the generated inline policy,
+// managed policies are left attached since wekactl never attaches any
+func ReconcileRole(rolesPath, roleBaseName, roleName, policyName, permissionsBoundary string, assumeRolePolicy AssumeRolePolicyDocument, policy PolicyDocument, versionTag []*iam.Tag) error {
+	svc := connectors.GetAWSSession().IAM
+
+	_, err := svc.UpdateAssumeRolePolicy(&iam.UpdateAssumeRolePolicyInput{
+		RoleName:       &roleName,
+		PolicyDocument: aws.String(assumeRolePolicy.String()),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = SetRolePermissionsBoundary(roleName, permissionsBoundary)
+	if err != nil {
+		return err
+	}
+
+	return UpdateRolePolicy(rolesPath, roleBaseName, policyName, policy, versionTag)
+}
+
+func rolePermissionsBoundary(role *iam.Role) string {
+	if role.PermissionsBoundary == nil {
+		return ""
+	}
+	return aws.StringValue(role.PermissionsBoundary.PermissionsBoundaryArn)
+}
+
+func GetRolePermissionsBoundary(roleName string) (permissionsBoundary string, err error) {
+	svc := connectors.GetAWSSession().IAM
+	roleOutput, err := svc.GetRole(&iam.GetRoleInput{RoleName: &roleName})
+	if err != nil {
+		return
+	}
+	permissionsBoundary = rolePermissionsBoundary(roleOutput.Role)
+	return
+}
+
+// SetRolePermissionsBoundary puts the role permissions boundary, an empty one deletes it
+func SetRolePermissionsBoundary(roleName, permissionsBoundary string) error {
+	svc := connectors.GetAWSSession().IAM
+	current, err := GetRolePermissionsBoundary(roleName)
+	if err != nil || current == permissionsBoundary {
+		return err
+	}
+	if permissionsBoundary == "" {
+		log.Debug().Msgf("deleting role %s permissions boundary", roleName)
+		_, err = svc.DeleteRolePermissionsBoundary(&iam.DeleteRolePermissionsBoundaryInput{RoleName: &roleName})
+		return err
+	}
+	log.Debug().Msgf("setting role %s permissions boundary %s", roleName, permissionsBoundary)
+	_, err = svc.PutRolePermissionsBoundary(&iam.PutRolePermissionsBoundaryInput{
+		RoleName:            &roleName,
+		PermissionsBoundary: &permissionsBoundary,
+	})
+	return err
+}
+
+func UpdateRoleTags(roleName string, tags []*iam.Tag, removedKeys []string) error {
+	svc := connectors.GetAWSSession().IAM
+	if len(removedKeys) > 0 {
+		_, err := svc.UntagRole(&iam.UntagRoleInput{RoleName: &roleName, TagKeys: aws.StringSlice(removedKeys)})
+		if err != nil {
+			return err
+		}
+	}
+	_, err := svc.TagRole(&iam.TagRoleInput{RoleName: &roleName, Tags: tags})
+	return err
+}
+
+func getRoleTrustPolicy(roleName string) (trustPolicy string, err error) {
+	svc := connectors.GetAWSSession().IAM
+	roleOutput, err := svc.GetRole(&iam.GetRoleInput{RoleName: &roleName})
+	if err != nil {
+		return
+	}
+	return url.QueryUnescape(aws.StringValue(roleOutput.Role.AssumeRolePolicyDocument))
+}
+
+// GetRolePolicyDocuments returns the decoded role trust policy and inline policy, the policy is empty when the role
+// has no inline policy named policyName
+func GetRolePolicyDocuments(roleName, policyName string) (assumeRolePolicy, policy string, err error) {
+	svc := connectors.GetAWSSession().IAM
+	assumeRolePolicy, err = getRoleTrustPolicy(roleName)
+	if err != nil {
+		return
+	}
+
+	policyOutput, err := svc.GetRolePolicy(&iam.GetRolePolicyInput{RoleName: &roleName, PolicyName: &policyName})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == iam.ErrCodeNoSuchEntityException {
+			err = nil
+		}
+		return
+	}
+	policy, err = url.QueryUnescape(aws.StringValue(policyOutput.PolicyDocument))
+	return
+}
+
+// stringOrList returns the values of a policy element that is either a string or a list of strings
+func stringOrList(value interface{}) (values []string) {
+	switch typed := value.(type) {
+	case string:
+		values = append(values, typed)
+	case []interface{}:
+		for _, item := range typed {
+			if itemString, ok := item.(string); ok {
+				values = append(values, itemString)
+			}
+		}
+	}
+	return
+}
+
+// trustedServices returns the services the trust policy allows to assume the role
+func trustedServices(trustPolicy string) (services []string, err error) {
+	var document struct {
+		Statement []struct {
+			Effect    string
+			Action    interface{}
+			Principal interface{}
+		}
+	}
+	err = json.Unmarshal([]byte(trustPolicy), &document)
+	if err != nil {
+		return
+	}
+	for _, statement := range document.Statement {
+		if statement.Effect != "Allow" || !strings2.AnyOf("sts:AssumeRole", stringOrList(statement.Action)...) {
+			continue
+		}
+		if principal, ok := statement.Principal.(map[string]interface{}); ok {
+			services = append(services, stringOrList(principal["Service"])...)
+		}
+	}
+	return
+}
+
+// GetExternalRoleDrift checks a role supplied on import, which wekactl doesn't own: its trust policy must allow the
+// services of assumeRolePolicy and its policies must allow the actions of policy on their resources. Permissions beyond
+// the generated policy are not reported
+func GetExternalRoleDrift(roleArn string, assumeRolePolicy AssumeRolePolicyDocument, policy PolicyDocument) (drifts []string, err error) {
+	trustPolicy, err := getRoleTrustPolicy(RoleNameFromArn(roleArn))
+	if err != nil {
+		return
+	}
+	services, err := trustedServices(trustPolicy)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("failed parsing role %s trust policy: %v", roleArn, err))
+		return
+	}
+	for _, statement := range assumeRolePolicy.Statement {
+		if !strings2.AnyOf(statement.Principal.Service, services...) {
+			drifts = append(drifts, fmt.Sprintf("trust policy doesn't allow %s to assume the role", statement.Principal.Service))
+		}
+	}
+
+	if policy.Version == "" {
+		return
+	}
+	denied, err := GetDeniedPolicyActions(roleArn, policy)
+	if err != nil {
+		return
+	}
+	if len(denied) > 0 {
+		drifts = append(drifts, fmt.Sprintf("policies don't allow %s", strings.Join(denied, ", ")))
+	}
+	return
+}
+
+// GetInstanceProfileRoleArn returns the arn of the role of the instance profile
+func GetInstanceProfileRoleArn(instanceProfileArn string) (roleArn string, err error) {
+	svc := connectors.GetAWSSession().IAM
+	name := instanceProfileArn[strings.LastIndex(instanceProfileArn, "/")+1:]
+	output, err := svc.GetInstanceProfile(&iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+	})
+	if err != nil {
+		return
+	}
+	if len(output.InstanceProfile.Roles) == 0 {
+		err = errors.New(fmt.Sprintf("instance profile %s has no role", instanceProfileArn))
+		return
+	}
+	roleArn = aws.StringValue(output.InstanceProfile.Roles[0].Arn)
+	return
+}
@@ -0,0 +1,54 @@
+package iam
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrustedServices(t *testing.T) {
+	tests := []struct {
+		name        string
+		trustPolicy string
+		services    []string
+		err         bool
+	}{
+		{
+			name:        "generated",
+			trustPolicy: GetLambdaAssumeRolePolicy().String(),
+			services:    []string{"lambda.amazonaws.com"},
+		},
+		{
+			name: "strings",
+			trustPolicy: `{"Version": "2012-10-17", "Statement": [
+				{"Effect": "Allow", "Action": "sts:AssumeRole", "Principal": {"Service": "states.amazonaws.com"}}]}`,
+			services: []string{"states.amazonaws.com"},
+		},
+		{
+			name: "lists",
+			trustPolicy: `{"Version": "2012-10-17", "Statement": [
+				{"Effect": "Allow", "Action": ["sts:TagSession", "sts:AssumeRole"],
+				 "Principal": {"Service": ["lambda.amazonaws.com", "events.amazonaws.com"]}}]}`,
+			services: []string{"lambda.amazonaws.com", "events.amazonaws.com"},
+		},
+		{
+			name: "skipped statements",
+			trustPolicy: `{"Version": "2012-10-17", "Statement": [
+				{"Effect": "Deny", "Action": "sts:AssumeRole", "Principal": {"Service": "lambda.amazonaws.com"}},
+				{"Effect": "Allow", "Action": "sts:TagSession", "Principal": {"Service": "states.amazonaws.com"}},
+				{"Effect": "Allow", "Action": "sts:AssumeRole", "Principal": "*"},
+				{"Effect": "Allow", "Action": "sts:AssumeRole", "Principal": {"AWS": "arn:aws:iam::123456789012:root"}}]}`,
+		},
+		{name: "invalid", trustPolicy: `{"Statement": `, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			services, err := trustedServices(test.trustPolicy)
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v, expected error %t", err, test.err)
+			}
+			if !reflect.DeepEqual(services, test.services) {
+				t.Errorf("got services %v, expected %v", services, test.services)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package iam
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/rs/zerolog/log"
+	"strings"
+	"wekactl/internal/connectors"
+)
+
+// simulatedActionsBatch keeps the simulation requests small
+const simulatedActionsBatch = 50
+
+var UnsupportedPrincipal = errors.New("the caller permissions can't be simulated, only iam users and roles are supported")
+
+// GetCallerPrincipalArn returns the iam user or role of the caller, assumed role sessions are mapped to their role
+func GetCallerPrincipalArn() (principalArn string, err error) {
+	identity, err := connectors.GetAWSSession().STS.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return
+	}
+	callerArn, err := arn.Parse(*identity.Arn)
+	if err != nil {
+		return
+	}
+	log.Debug().Msgf("caller identity is %s", callerArn.String())
+
+	switch {
+	case callerArn.Service == "iam" && strings.HasPrefix(callerArn.Resource, "user/"):
+		principalArn = callerArn.String()
+	case callerArn.Service == "sts" && strings.HasPrefix(callerArn.Resource, "assumed-role/"):
+		// assumed-role/ROLE_NAME/SESSION_NAME, the role path is missing so the role arn is fetched
+		roleName := strings.Split(callerArn.Resource, "/")[1]
+		var roleOutput *iam.GetRoleOutput
+		roleOutput, err = connectors.GetAWSSession().IAM.GetRole(&iam.GetRoleInput{RoleName: &roleName})
+		if err != nil {
+			return
+		}
+		principalArn = *roleOutput.Role.Arn
+	default:
+		err = UnsupportedPrincipal
+	}
+	return
+}
+
+// simulateDenied runs the simulation, returning the actions that aren't allowed on every simulated resource
+func simulateDenied(input *iam.SimulatePrincipalPolicyInput) (denied []string, err error) {
+	svc := connectors.GetAWSSession().IAM
+	err = svc.SimulatePrincipalPolicyPages(input, func(page *iam.SimulatePolicyResponse, lastPage bool) bool {
+		for _, result := range page.EvaluationResults {
+			allowed := *result.EvalDecision == iam.PolicyEvaluationDecisionTypeAllowed
+			for _, resourceResult := range result.ResourceSpecificResults {
+				if *resourceResult.EvalResourceDecision != iam.PolicyEvaluationDecisionTypeAllowed {
+					allowed = false
+				}
+			}
+			if !allowed {
+				log.Debug().Msgf("action %s is %s", *result.EvalActionName, *result.EvalDecision)
+				denied = append(denied, *result.EvalActionName)
+			}
+		}
+		return true
+	})
+	return
+}
+
+// GetDeniedActions simulates the principal identity policies, permissions boundary and organization SCPs against the
+// given actions, returning the ones that aren't allowed
+func GetDeniedActions(principalArn string, actions []string) (denied []string, err error) {
+	for start := 0; start < len(actions); start += simulatedActionsBatch {
+		end := start + simulatedActionsBatch
+		if end > len(actions) {
+			end = len(actions)
+		}
+		var batchDenied []string
+		batchDenied, err = simulateDenied(&iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(principalArn),
+			ActionNames:     aws.StringSlice(actions[start:end]),
+		})
+		if err != nil {
+			return
+		}
+		denied = append(denied, batchDenied...)
+	}
+	return
+}
+
+// simulatedActions returns the statement actions to simulate, the operations the lambda calls in place of wildcards
+func simulatedActions(statement StatementEntry) []string {
+	if len(statement.SimulatedAction) > 0 {
+		return statement.SimulatedAction
+	}
+	return statement.Action
+}
+
+// GetDeniedPolicyActions simulates the principal policies against each statement of policy, on the statement resources
+// and with its condition values, returning the actions that aren't allowed. The generated policies leave the account
+// out of the resources arns, the principal account is simulated in its place
+func GetDeniedPolicyActions(principalArn string, policy PolicyDocument) (denied []string, err error) {
+	principal, err := arn.Parse(principalArn)
+	if err != nil {
+		return
+	}
+	for _, statement := range policy.Statement {
+		var resources []string
+		for _, resource := range statement.Resource {
+			if resourceArn, parseErr := arn.Parse(resource); parseErr == nil && resourceArn.AccountID == "*" {
+				resourceArn.AccountID = principal.AccountID
+				resource = resourceArn.String()
+			}
+			resources = append(resources, resource)
+		}
+		var contextEntries []*iam.ContextEntry
+		for _, values := range statement.Condition {
+			for key, value := range values {
+				contextEntries = append(contextEntries, &iam.ContextEntry{
+					ContextKeyName:   aws.String(key),
+					ContextKeyType:   aws.String(iam.ContextKeyTypeEnumString),
+					ContextKeyValues: aws.StringSlice([]string{value}),
+				})
+			}
+		}
+		var statementDenied []string
+		statementDenied, err = simulateDenied(&iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(principalArn),
+			ActionNames:     aws.StringSlice(simulatedActions(statement)),
+			ResourceArns:    aws.StringSlice(resources),
+			ContextEntries:  contextEntries,
+		})
+		if err != nil {
+			return
+		}
+		denied = append(denied, statementDenied...)
+	}
+	return
+}
+
+// GetRolesQuota returns the number of roles in the account and the account roles quota
+func GetRolesQuota() (used, quota int64, err error) {
+	summary, err := connectors.GetAWSSession().IAM.GetAccountSummary(&iam.GetAccountSummaryInput{})
+	if err != nil {
+		return
+	}
+	usedRef, quotaRef := summary.SummaryMap["Roles"], summary.SummaryMap["RolesQuota"]
+	if usedRef == nil || quotaRef == nil {
+		err = errors.New("roles quota is missing from the account summary")
+		return
+	}
+	return *usedRef, *quotaRef, nil
+}
@@ -0,0 +1,30 @@
+package iam
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSimulatedActions(t *testing.T) {
+	scope := Scope{Region: "eu-west-1", ClusterName: "test", TableName: "weka-test", ASGName: "test-Backends"}
+	policies := map[string]PolicyDocument{
+		"join":      GetJoinAndFetchLambdaPolicy(scope, "test-join"),
+		"scale":     GetScaleLambdaPolicy(scope, "test-scale"),
+		"metrics":   GetMetricsLambdaPolicy(scope, "test-metrics"),
+		"terminate": GetTerminateLambdaPolicy(scope, "test-terminate"),
+		"sm":        GetStateMachineRolePolicy(scope, []string{"test-join"}),
+		"cw":        GetCloudWatchEventRolePolicy(scope, "test-sm"),
+	}
+	for name, policy := range policies {
+		for _, statement := range policy.Statement {
+			for _, action := range simulatedActions(statement) {
+				if strings.Contains(action, "*") {
+					t.Errorf("%s policy simulates the wildcard action %s", name, action)
+				}
+			}
+		}
+		if strings.Contains(policy.String(), "SimulatedAction") {
+			t.Errorf("%s policy document holds the simulated actions", name)
+		}
+	}
+}
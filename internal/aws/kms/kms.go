@@ -182,3 +182,19 @@ func DeleteKmsKey(kmsKey *kms.KeyListEntry, clusterName cluster.ClusterName) err
 	}
 	return nil
 }
+
+func UpdateKMSKeyTags(clusterName cluster.ClusterName, tags []*kms.Tag, removedKeys []string) error {
+	svc := connectors.GetAWSSession().KMS
+	keyId, err := GetKMSKeyId(clusterName)
+	if err != nil || keyId == "" {
+		return err
+	}
+	if len(removedKeys) > 0 {
+		_, err = svc.UntagResource(&kms.UntagResourceInput{KeyId: &keyId, TagKeys: aws.StringSlice(removedKeys)})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = svc.TagResource(&kms.TagResourceInput{KeyId: &keyId, Tags: tags})
+	return err
+}
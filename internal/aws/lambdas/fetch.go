@@ -5,11 +5,12 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/weka/go-cloud-lib/protocol"
 	"wekactl/internal/aws/common"
-	"wekactl/internal/aws/db"
 	"wekactl/internal/connectors"
 )
 
-func GetFetchDataParams(clusterName, asgName, tableName, role string, useDynamoDBEndpoint bool) (fd protocol.HostGroupInfoResponse, err error) {
+// GetFetchDataParams returns the hostgroup info passed through the state machine, it must never hold the weka
+// credentials since executions history is readable with states:GetExecutionHistory, scale lambda reads them from DB
+func GetFetchDataParams(clusterName, asgName, role string) (fd protocol.HostGroupInfoResponse, err error) {
 	svc := connectors.GetAWSSession().ASG
 	input := &autoscaling.DescribeAutoScalingGroupsInput{AutoScalingGroupNames: []*string{&asgName}}
 	asgOutput, err := svc.DescribeAutoScalingGroups(input)
@@ -28,17 +29,7 @@ func GetFetchDataParams(clusterName, asgName, tableName, role string, useDynamoD
 		return
 	}
 
-	var creds db.ClusterCreds
-	if !useDynamoDBEndpoint {
-		creds, err = db.GetUsernameAndPassword(tableName)
-		if err != nil {
-			return
-		}
-	}
-
 	return protocol.HostGroupInfoResponse{
-		Username:        creds.Username,
-		Password:        creds.Password,
 		DesiredCapacity: getAutoScalingGroupDesiredCapacity(asgOutput),
 		Instances:       getHostGroupInfoInstances(instances),
 		BackendIps:      backendIps,
@@ -5,19 +5,23 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/lithammer/dedent"
-	"github.com/weka/go-cloud-lib/bash_functions"
 	common2 "github.com/weka/go-cloud-lib/common"
 	"github.com/weka/go-cloud-lib/functions_def"
 	"github.com/weka/go-cloud-lib/join"
+	"strings"
 	"wekactl/internal/aws/common"
 	"wekactl/internal/aws/db"
 	"wekactl/internal/connectors"
 )
 
 type AwsFuncDef struct {
+	Overrides map[string]string
 }
 
 func (d *AwsFuncDef) GetFunctionCmdDefinition(name functions_def.FunctionName) string {
+	if body, ok := d.Overrides[string(name)]; ok {
+		return fmt.Sprintf("\nfunction %s {\n%s\n}\n", name, body)
+	}
 	defTemplate := `
 	function %s {
 		echo "currently ${FUNCNAME[0]} is not supported, ignoring..."
@@ -44,6 +48,15 @@ func GetJoinParams(ctx context.Context, clusterName, asgName, tableName, role st
 	if err != nil {
 		return "", err
 	}
+	hooks, err := db.GetJoinHooks(tableName)
+	if err != nil {
+		return "", err
+	}
+	var clusterSettings db.ClusterSettings
+	err = db.GetItem(tableName, db.ModelClusterSettings, &clusterSettings)
+	if err != nil {
+		return "", err
+	}
 
 	scriptBase := `
 	#!/bin/bash
@@ -102,6 +115,17 @@ func GetJoinParams(ctx context.Context, clusterName, asgName, tableName, role st
 		print(d['devPath'])
 	`
 
+	// the generator uses script base and find drives script as part of its format string
+	findDrivesScript = dedent.Dedent(findDrivesScript)
+	if hooks.FindDrivesScript != "" {
+		findDrivesScript = "\n" + strings.ReplaceAll(strings.Trim(hooks.FindDrivesScript, "\n"), "%", "%%") + "\n"
+	}
+
+	scriptBase = dedent.Dedent(scriptBase)
+	if hooks.PreJoin != "" {
+		scriptBase += "\n# pre-join hook\n" + strings.ReplaceAll(hooks.PreJoin, "%", "%%") + "\n"
+	}
+
 	backendCoreCounts := common.GetBackendCoreCounts()
 	instanceParams := backendCoreCounts[instanceType]
 
@@ -114,14 +138,17 @@ func GetJoinParams(ctx context.Context, clusterName, asgName, tableName, role st
 	}
 
 	joinScriptGenerator := join.JoinScriptGenerator{
-		FailureDomainCmd:   bash_functions.GetHashedPrivateIpBashCmd(),
+		FailureDomainCmd:   common.GetFailureDomainCmd(clusterSettings.FailureDomain, clusterSettings.FailureDomainCmd),
 		GetInstanceNameCmd: "",
-		FindDrivesScript:   dedent.Dedent(findDrivesScript),
-		ScriptBase:         dedent.Dedent(scriptBase),
+		FindDrivesScript:   findDrivesScript,
+		ScriptBase:         scriptBase,
 		Params:             joinParams,
-		FuncDef:            &AwsFuncDef{},
+		FuncDef:            &AwsFuncDef{Overrides: hooks.Functions},
 	}
 	bashScript := joinScriptGenerator.GetJoinScript(ctx)
+	if hooks.PostJoin != "" {
+		bashScript += "\n# post-join hook\n" + hooks.PostJoin + "\n"
+	}
 
 	return bashScript, nil
 }
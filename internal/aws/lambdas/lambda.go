@@ -2,10 +2,10 @@ package lambdas
 
 import (
 	"fmt"
-	"strconv"
 	"time"
 	"wekactl/internal/aws/common"
 	"wekactl/internal/aws/dist"
+	"wekactl/internal/aws/lambdas/scale_down"
 	"wekactl/internal/cluster"
 	"wekactl/internal/connectors"
 	"wekactl/internal/env"
@@ -14,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/rs/zerolog/log"
 )
 
@@ -46,7 +47,23 @@ func handleAwsInvalidParameterValueException(err error, lambdaName string, shoul
 	return false
 }
 
-func CreateLambda(tags cluster.TagsRefsValues, lambdaType LambdaType, resourceName, roleArn, asgName, tableName string, hostGroupInfo common.HostGroupInfo, vpcConfig lambda.VpcConfig, useDynamoDBEndpoint bool) (*lambda.FunctionConfiguration, error) {
+// GetLambdaEnvironmentVariables returns the lambda environment, recordTarget is set only on the scale lambda
+func GetLambdaEnvironmentVariables(lambdaType LambdaType, asgName, tableName string, hostGroupInfo common.HostGroupInfo, recordTarget string) map[string]*string {
+	variables := map[string]*string{
+		"LAMBDA":       aws.String(string(lambdaType)),
+		"REGION":       aws.String(env.Config.Region),
+		"CLUSTER_NAME": aws.String(string(hostGroupInfo.ClusterName)),
+		"ASG_NAME":     aws.String(asgName),
+		"TABLE_NAME":   aws.String(tableName),
+		"ROLE":         aws.String(string(hostGroupInfo.Role)),
+	}
+	if lambdaType == LambdaScale && recordTarget != "" {
+		variables[scale_down.RecordTargetEnv] = aws.String(recordTarget)
+	}
+	return variables
+}
+
+func CreateLambda(tags cluster.TagsRefsValues, lambdaType LambdaType, resourceName, roleArn, asgName, tableName string, hostGroupInfo common.HostGroupInfo, vpcConfig lambda.VpcConfig, recordTarget string) (*lambda.FunctionConfiguration, error) {
 	svc := connectors.GetAWSSession().Lambda
 
 	bucket, err := dist.GetLambdaBucket()
@@ -70,27 +87,19 @@ func CreateLambda(tags cluster.TagsRefsValues, lambdaType LambdaType, resourceNa
 		},
 		Description: aws.String(fmt.Sprintf("Wekactl %s", string(lambdaType))),
 		Environment: &lambda.Environment{
-			Variables: map[string]*string{
-				"LAMBDA":                aws.String(string(lambdaType)),
-				"REGION":                aws.String(env.Config.Region),
-				"CLUSTER_NAME":          aws.String(string(hostGroupInfo.ClusterName)),
-				"ASG_NAME":              aws.String(asgName),
-				"TABLE_NAME":            aws.String(tableName),
-				"ROLE":                  aws.String(string(hostGroupInfo.Role)),
-				"USE_DYNAMODB_ENDPOINT": aws.String(strconv.FormatBool(useDynamoDBEndpoint)),
-			},
+			Variables: GetLambdaEnvironmentVariables(lambdaType, asgName, tableName, hostGroupInfo, recordTarget),
 		},
 		Handler:       aws.String(lambdaHandler),
 		FunctionName:  aws.String(lambdaName),
-		MemorySize:    aws.Int64(256),
+		MemorySize:    aws.Int64(LambdaMemorySize),
 		Publish:       aws.Bool(true),
 		Role:          &roleArn,
 		Runtime:       aws.String(string(runtime)),
 		Architectures: []*string{aws.String(string(arch))},
 		Tags:          tags,
-		Timeout:       aws.Int64(15),
+		Timeout:       aws.Int64(LambdaTimeout),
 		TracingConfig: &lambda.TracingConfig{
-			Mode: aws.String("Active"),
+			Mode: aws.String(LambdaTracingMode),
 		},
 		VpcConfig: &vpcConfig,
 	}
@@ -171,6 +180,27 @@ func InvokePolicyExists(lambdaName string) bool {
 	return err == nil
 }
 
+// AddEventRuleInvokePermission allows the cloudwatch event rule to invoke the lambda
+func AddEventRuleInvokePermission(lambdaName, ruleArn string) error {
+	svc := connectors.GetAWSSession().Lambda
+	_, err := svc.AddPermission(&lambda.AddPermissionInput{
+		FunctionName: &lambdaName,
+		StatementId:  aws.String(lambdaName + "-events"),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("events.amazonaws.com"),
+		SourceArn:    &ruleArn,
+	})
+	if err != nil {
+		if _, ok := err.(*lambda.ResourceConflictException); ok {
+			// the permission already exists
+			return nil
+		}
+		return err
+	}
+	log.Debug().Msgf("event rule %s was allowed to invoke lambda %s", ruleArn, lambdaName)
+	return nil
+}
+
 func UpdateLambdaHandler(lambdaName string, versionTag cluster.TagsRefsValues) error {
 	svc := connectors.GetAWSSession().Lambda
 	bucket, err := dist.GetLambdaBucket()
@@ -388,3 +418,108 @@ func UpdateLambdaEnvironmentVariable(lambdaName string, environmentVariables map
 		}})
 	return waitForLambdaLastUpdateStatusSuccess(lambdaName, 5*time.Second, 12)
 }
+
+func GetLambdaConfiguration(lambdaName string) (configuration *lambda.FunctionConfiguration, err error) {
+	svc := connectors.GetAWSSession().Lambda
+	lambdaOutput, err := svc.GetFunction(&lambda.GetFunctionInput{
+		FunctionName: &lambdaName,
+	})
+	if err != nil {
+		return
+	}
+	configuration = lambdaOutput.Configuration
+	return
+}
+
+// UpdateLambdaConfiguration sets back every configuration field CreateLambda sets, except for the code and runtime
+func UpdateLambdaConfiguration(lambdaName, roleArn string, environmentVariables map[string]*string, vpcConfig lambda.VpcConfig) (err error) {
+	svc := connectors.GetAWSSession().Lambda
+
+	if vpcConfig.SubnetIds == nil {
+		vpcConfig.SubnetIds = []*string{}
+	}
+	if vpcConfig.SecurityGroupIds == nil {
+		vpcConfig.SecurityGroupIds = []*string{}
+	}
+
+	// it takes some time for the trust entity to be updated
+	retry := true
+	retries := 3
+	for i := 0; i < retries && retry; i++ {
+		log.Info().Msgf("updating lambda %s configuration ...", lambdaName)
+		_, err = svc.UpdateFunctionConfiguration(&lambda.UpdateFunctionConfigurationInput{
+			FunctionName: &lambdaName,
+			Role:         &roleArn,
+			Environment: &lambda.Environment{
+				Variables: environmentVariables,
+			},
+			MemorySize: aws.Int64(LambdaMemorySize),
+			Timeout:    aws.Int64(LambdaTimeout),
+			TracingConfig: &lambda.TracingConfig{
+				Mode: aws.String(LambdaTracingMode),
+			},
+			VpcConfig: &vpcConfig,
+		})
+		retry = handleAwsInvalidParameterValueException(err, lambdaName, retries > i+1)
+	}
+	if err != nil {
+		return
+	}
+	return waitForLambdaLastUpdateStatusSuccess(lambdaName, 5*time.Second, 12)
+}
+
+// LambdaPackageExists tells whether the lambdas package of the given ID was uploaded to the region bucket
+func LambdaPackageExists(lambdasId string) (exists bool, err error) {
+	_, err = GetLambdaPackageSize(lambdasId)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NotFound" {
+			return false, nil
+		}
+		return
+	}
+	return true, nil
+}
+
+// GetLambdaPackageSize returns the size in bytes of the lambdas package of the given ID in the region bucket
+func GetLambdaPackageSize(lambdasId string) (size int64, err error) {
+	svc := connectors.GetAWSSession().S3
+	bucket, err := dist.GetLambdaBucket()
+	if err != nil {
+		return
+	}
+
+	objectOutput, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(fmt.Sprintf("%s/%s", lambdasId, string(dist.WekaCtl))),
+	})
+	if err != nil {
+		return
+	}
+	size = aws.Int64Value(objectOutput.ContentLength)
+	return
+}
+
+// GetCodeStorageQuota returns the lambda code storage used in the region and its quota, in bytes
+func GetCodeStorageQuota() (used, quota int64, err error) {
+	settings, err := connectors.GetAWSSession().Lambda.GetAccountSettings(&lambda.GetAccountSettingsInput{})
+	if err != nil {
+		return
+	}
+	return *settings.AccountUsage.TotalCodeSize, *settings.AccountLimit.TotalCodeSize, nil
+}
+
+func UpdateLambdaTags(lambdaName string, tags cluster.TagsRefsValues, removedKeys []string) error {
+	svc := connectors.GetAWSSession().Lambda
+	arn, err := GetLambdaArn(lambdaName)
+	if err != nil {
+		return err
+	}
+	if len(removedKeys) > 0 {
+		_, err = svc.UntagResource(&lambda.UntagResourceInput{Resource: &arn, TagKeys: aws.StringSlice(removedKeys)})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = svc.TagResource(&lambda.TagResourceInput{Resource: &arn, Tags: tags})
+	return err
+}
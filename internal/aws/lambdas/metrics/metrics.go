@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/rs/zerolog/log"
+	"os"
+	"time"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/connectors"
+	"wekactl/internal/lib/jrpc"
+	"wekactl/internal/lib/weka"
+)
+
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func metricData(clusterName string, metrics weka.ClusterMetrics, timestamp time.Time) (data []*cloudwatch.MetricDatum) {
+	values := []struct {
+		name  string
+		value float64
+		unit  string
+	}{
+		{"CapacityTotalBytes", float64(metrics.CapacityTotalBytes), cloudwatch.StandardUnitBytes},
+		{"CapacityUsedBytes", float64(metrics.CapacityUsedBytes), cloudwatch.StandardUnitBytes},
+		{"CapacityFreeBytes", float64(metrics.CapacityFreeBytes), cloudwatch.StandardUnitBytes},
+		{"CapacityUsedPercent", metrics.CapacityUsedPercent, cloudwatch.StandardUnitPercent},
+		{"Throughput", metrics.ThroughputBytesPerSecond, cloudwatch.StandardUnitBytesSecond},
+		{"Iops", metrics.Iops, cloudwatch.StandardUnitCountSecond},
+		{"ActiveBackends", float64(metrics.ActiveBackends), cloudwatch.StandardUnitCount},
+		{"InactiveBackends", float64(metrics.InactiveBackends), cloudwatch.StandardUnitCount},
+		{"FullyProtected", boolValue(metrics.FullyProtected), cloudwatch.StandardUnitNone},
+	}
+	for _, value := range values {
+		data = append(data, &cloudwatch.MetricDatum{
+			MetricName: aws.String(value.name),
+			Value:      aws.Float64(value.value),
+			Unit:       aws.String(value.unit),
+			Timestamp:  &timestamp,
+			Dimensions: []*cloudwatch.Dimension{
+				{
+					Name:  aws.String("ClusterName"),
+					Value: &clusterName,
+				},
+			},
+		})
+	}
+	return
+}
+
+// Handler publishes weka capacity, performance and health as CloudWatch metrics. It runs inside the vpc to reach the
+// backends, so it requires the vpc to reach the EC2 and CloudWatch apis (NAT or interface endpoints).
+func Handler(ctx context.Context) error {
+	clusterName := os.Getenv("CLUSTER_NAME")
+	creds, err := db.GetUsernameAndPassword(os.Getenv("TABLE_NAME"))
+	if err != nil {
+		return err
+	}
+	username, err := common.DecodeBase64(creds.Username)
+	if err != nil {
+		return err
+	}
+	password, err := common.DecodeBase64(creds.Password)
+	if err != nil {
+		return err
+	}
+
+	ips, err := common.GetBackendsPrivateIps(clusterName)
+	if err != nil {
+		return err
+	}
+	if len(ips) == 0 {
+		return errors.New("no running backends were found")
+	}
+	pool := &jrpc.Pool{
+		Ips:     ips,
+		Clients: map[string]*jrpc.BaseClient{},
+		Builder: func(ip string) *jrpc.BaseClient {
+			return connectors.NewJrpcClient(ctx, ip, weka.ManagementJrpcPort, username, password)
+		},
+		Ctx: ctx,
+	}
+
+	status := weka.StatusResponse{}
+	err = pool.Call(weka.JrpcStatus, struct{}{}, &status)
+	if err != nil {
+		return err
+	}
+	filesystems := weka.FilesystemListResponse{}
+	err = pool.Call(weka.JrpcFilesystemsList, struct{}{}, &filesystems)
+	if err != nil {
+		return err
+	}
+
+	metrics := weka.GetClusterMetrics(status, filesystems)
+	svc := connectors.GetAWSSession().CloudWatch
+	_, err = svc.PutMetricData(&cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(common.WekaMetricsNamespace),
+		MetricData: metricData(clusterName, metrics, time.Now()),
+	})
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("cluster %s metrics were published: %+v", clusterName, metrics)
+	return nil
+}
@@ -7,6 +7,7 @@ const LambdaScale LambdaType = "scale"
 const LambdaTerminate LambdaType = "terminate"
 const LambdaJoin LambdaType = "join"
 const LambdaTransient LambdaType = "transient"
+const LambdaMetrics LambdaType = "metrics"
 
 type LambdaRuntime string
 
@@ -21,3 +22,7 @@ const LambdaArchX86_64 LambdaArch = "x86_64"
 const LambdaArchDefault LambdaArch = LambdaArchArm64
 
 const LambdaHandlerName = "bootstrap"
+
+const LambdaMemorySize = 256
+const LambdaTimeout = 15
+const LambdaTracingMode = "Active"
@@ -0,0 +1,83 @@
+package scale_down
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"wekactl/internal/connectors"
+	"wekactl/internal/lib/jrpc"
+	"wekactl/internal/lib/weka"
+)
+
+// RecordTargetEnv enables recording of the weka management traffic of the scale lambda when set, it is managed by
+// the cluster record target setting (hostgroup record).
+// Value is s3://bucket/prefix, a file named <cluster>/<timestamp>.json is written under it.
+// Writing to s3 from the lambda requires the lambda vpc to reach s3 (NAT or s3 gateway endpoint).
+const RecordTargetEnv = "JRPC_RECORD_TARGET"
+
+// ParseRecordTarget splits a s3://bucket/prefix record target, the prefix is optional
+func ParseRecordTarget(target string) (bucket, prefix string, err error) {
+	if !strings.HasPrefix(target, "s3://") {
+		err = errors.New(fmt.Sprintf("invalid record target %s, expected s3://bucket/prefix", target))
+		return
+	}
+	bucketAndPrefix := strings.SplitN(strings.TrimPrefix(target, "s3://"), "/", 2)
+	bucket = bucketAndPrefix[0]
+	if bucket == "" {
+		err = errors.New(fmt.Sprintf("invalid record target %s, bucket is missing", target))
+		return
+	}
+	if len(bucketAndPrefix) == 2 {
+		prefix = strings.Trim(bucketAndPrefix[1], "/")
+	}
+	return
+}
+
+// startRecording routes the management traffic of every backend through a local recording proxy
+func startRecording(backendIps []string, recorder *jrpc.Recorder) (proxyIps []string, stop func(), err error) {
+	return jrpc.ServeLoopback(backendIps, weka.ManagementJrpcPort, func(ip string) http.Handler {
+		return jrpc.NewRecordingProxy(ip, weka.ManagementJrpcPort, recorder)
+	})
+}
+
+// recordingKey names the recording after the hostgroup auto scaling group and the lambda request, so that the
+// executions of several hostgroups, or several executions within a second, don't overwrite each other
+func recordingKey(prefix, clusterName, asgName, requestId string, created time.Time) string {
+	name := created.UTC().Format(time.RFC3339Nano)
+	if requestId != "" {
+		name += "-" + requestId
+	}
+	return path.Join(prefix, clusterName, asgName, name+".json")
+}
+
+func saveRecording(target, clusterName, asgName, requestId string, recording jrpc.Recording) (location string, err error) {
+	bucket, prefix, err := ParseRecordTarget(target)
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	err = recording.Write(&buf)
+	if err != nil {
+		return
+	}
+	key := recordingKey(prefix, clusterName, asgName, requestId, recording.Created)
+	_, err = connectors.GetAWSSession().S3.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return
+	}
+	location = fmt.Sprintf("s3://%s/%s", bucket, key)
+	log.Debug().Msgf("jrpc recording saved to %s", location)
+	return
+}
@@ -0,0 +1,49 @@
+package scale_down
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRecordTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		bucket string
+		prefix string
+		err    bool
+	}{
+		{target: "s3://bucket", bucket: "bucket"},
+		{target: "s3://bucket/", bucket: "bucket"},
+		{target: "s3://bucket/a/b/", bucket: "bucket", prefix: "a/b"},
+		{target: "s3:///prefix", err: true},
+		{target: "/tmp/recordings", err: true},
+	}
+	for _, test := range tests {
+		bucket, prefix, err := ParseRecordTarget(test.target)
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error %v", test.target, err)
+			continue
+		}
+		if bucket != test.bucket || prefix != test.prefix {
+			t.Errorf("%s: got bucket %q prefix %q, expected %q %q", test.target, bucket, prefix, test.bucket, test.prefix)
+		}
+	}
+}
+
+func TestRecordingKey(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 0, 0, 123456789, time.UTC)
+	key := recordingKey("debug", "cluster", "cluster-Backends", "request", created)
+	expected := "debug/cluster/cluster-Backends/2024-03-01T10:00:00.123456789Z-request.json"
+	if key != expected {
+		t.Errorf("got %s, expected %s", key, expected)
+	}
+
+	other := recordingKey("debug", "cluster", "cluster-Clients", "request", created)
+	if other == key {
+		t.Errorf("hostgroups recordings share the key %s", key)
+	}
+	later := recordingKey("debug", "cluster", "cluster-Backends", "", created.Add(time.Millisecond))
+	if later == key {
+		t.Errorf("recordings within a second share the key %s", key)
+	}
+}
@@ -2,39 +2,58 @@ package scale_down
 
 import (
 	"context"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/rs/zerolog/log"
 	"github.com/weka/go-cloud-lib/protocol"
 	"github.com/weka/go-cloud-lib/scale_down"
 	"os"
-	"strconv"
 	"wekactl/internal/aws/common"
 	"wekactl/internal/aws/db"
+	"wekactl/internal/lib/jrpc"
 )
 
 func Handler(ctx context.Context, info protocol.HostGroupInfoResponse) (response protocol.ScaleResponse, err error) {
 	tableName := os.Getenv("TABLE_NAME")
-	useDynamoDBEndpoint, err := strconv.ParseBool(os.Getenv("USE_DYNAMODB_ENDPOINT"))
+	// credentials are read by reference from DB and never come with the state machine input
+	creds, err := db.GetUsernameAndPassword(tableName)
 	if err != nil {
-		return protocol.ScaleResponse{}, err
-	}
-	if useDynamoDBEndpoint {
-		creds, err2 := db.GetUsernameAndPassword(tableName)
-		if err2 != nil {
-			err = err2
-			return
-		}
-		info.Username = creds.Username
-		info.Password = creds.Password
+		return
 	}
 
-	info.Username, err = common.DecodeBase64(info.Username)
+	info.Username, err = common.DecodeBase64(creds.Username)
 	if err != nil {
 		return
 	}
 
-	info.Password, err = common.DecodeBase64(info.Password)
+	info.Password, err = common.DecodeBase64(creds.Password)
 	if err != nil {
 		return
 	}
 
-	return scale_down.ScaleDown(ctx, info)
+	target := os.Getenv(RecordTargetEnv)
+	if target == "" {
+		return scale_down.ScaleDown(ctx, info)
+	}
+
+	recorder := jrpc.NewRecorder()
+	_ = recorder.SetInput(info)
+	proxyIps, stop, err := startRecording(info.BackendIps, recorder)
+	if err != nil {
+		log.Error().Err(err).Msg("failed starting jrpc recording, running without it")
+		return scale_down.ScaleDown(ctx, info)
+	}
+	info.BackendIps = proxyIps
+	response, err = scale_down.ScaleDown(ctx, info)
+	stop()
+	var requestId string
+	if lambdaContext, ok := lambdacontext.FromContext(ctx); ok {
+		requestId = lambdaContext.AwsRequestID
+	}
+	location, saveErr := saveRecording(target, os.Getenv("CLUSTER_NAME"), os.Getenv("ASG_NAME"), requestId, recorder.Recording())
+	if saveErr != nil {
+		log.Error().Err(saveErr).Msg("failed saving jrpc recording")
+	} else {
+		log.Info().Msgf("jrpc recording saved to %s", location)
+	}
+	return
 }
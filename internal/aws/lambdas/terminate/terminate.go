@@ -11,6 +11,7 @@ import (
 	"time"
 	autoscaling2 "wekactl/internal/aws/autoscaling"
 	"wekactl/internal/aws/common"
+	"wekactl/internal/cluster"
 	"wekactl/internal/connectors"
 	"wekactl/internal/lib/strings"
 	"wekactl/internal/lib/types"
@@ -18,6 +19,9 @@ import (
 
 type instancesMap map[string]*ec2.Instance
 
+// nics that failed to attach are left available by failed joins (instance shuts down), the join itself takes minutes
+const leakedNetworkInterfaceMinAge = time.Minute * 30
+
 func getInstancePrivateIpsSet(scaleResponse protocol.ScaleResponse) common.InstancePrivateIpsSet {
 	instancePrivateIpsSet := make(common.InstancePrivateIpsSet)
 	for _, instance := range scaleResponse.Hosts {
@@ -169,6 +173,11 @@ func Handler(scaleResponse protocol.ScaleResponse) (response protocol.Terminated
 		response.AddTransientErrors(errs)
 	}
 
+	errs = deleteLeakedNetworkInterfaces(cluster.ClusterName(os.Getenv("CLUSTER_NAME")))
+	if len(errs) != 0 {
+		response.AddTransientErrors(errs)
+	}
+
 	deltaInstanceIds, err := getDeltaInstancesIds(asgInstanceIds, scaleResponse)
 	if err != nil {
 		return
@@ -262,3 +271,16 @@ func detachUnhealthyInstances(instances []*autoscaling.Instance, asgName string)
 	}
 	return
 }
+
+func deleteLeakedNetworkInterfaces(clusterName cluster.ClusterName) (errs []error) {
+	leaked, err := common.GetLeakedNetworkInterfaces(clusterName, leakedNetworkInterfaceMinAge)
+	if err != nil {
+		return []error{err}
+	}
+	if len(leaked) == 0 {
+		return
+	}
+	deleted, errs := common.DeleteNetworkInterfaces(leaked)
+	log.Info().Msgf("Deleted leaked network interfaces %s", deleted)
+	return
+}
@@ -2,13 +2,16 @@ package launchtemplate
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/lithammer/dedent"
 	"github.com/rs/zerolog/log"
+	"sort"
 	"strconv"
+	"strings"
 	"wekactl/internal/aws/apigateway"
 	"wekactl/internal/aws/common"
 	"wekactl/internal/cluster"
@@ -16,7 +19,7 @@ import (
 	"wekactl/internal/env"
 )
 
-const LaunchtemplateVersion = "v3"
+const LaunchtemplateVersion = "v6"
 
 func generateBlockDeviceMappingRequest(name common.HostGroupName, volumesInfo []common.VolumeInfo) (request []*ec2.LaunchTemplateBlockDeviceMappingRequest) {
 	log.Debug().Msgf("generating %s launch template block device mapping", string(name))
@@ -35,7 +38,27 @@ func generateBlockDeviceMappingRequest(name common.HostGroupName, volumesInfo []
 	return
 }
 
-func getUserData(restApiGateway apigateway.RestApiGateway, subnetId, instanceType string, securityGroupsIds []*string) string {
+// getEniTags returns the json tags list given to additional nics, escaped for single quotes in bash, sorted by key so
+// that the same tags always render the same user data
+func getEniTags(tags []*ec2.Tag) string {
+	type eniTag struct {
+		Key   string
+		Value string
+	}
+	var eniTags []eniTag
+	for _, tag := range tags {
+		eniTags = append(eniTags, eniTag{Key: *tag.Key, Value: *tag.Value})
+	}
+	sort.Slice(eniTags, func(i, j int) bool {
+		return eniTags[i].Key < eniTags[j].Key
+	})
+	tagsJson, _ := json.Marshal(eniTags)
+	return strings.ReplaceAll(string(tagsJson), "'", `'"'"'`)
+}
+
+// GetUserData returns the launch template user data script, which creates the additional nics and runs the join script
+// served by the join api
+func GetUserData(restApiGateway apigateway.RestApiGateway, instanceType string, securityGroupsIds []*string, tags []*ec2.Tag) string {
 	securityGroupsIdsStr := ""
 	for _, securityGroupsId := range securityGroupsIds {
 		securityGroupsIdsStr = securityGroupsIdsStr + *securityGroupsId + " "
@@ -48,20 +71,31 @@ func getUserData(restApiGateway apigateway.RestApiGateway, subnetId, instanceTyp
 	set -ex
 	
 	region=%s
-	subnet_id=%s
 	groups=%s
 	nics_num=%d
 	join_url=%s
+	eni_tags='%s'
 
 	token=$(curl -X PUT "http://169.254.169.254/latest/api/token" -H "X-aws-ec2-metadata-token-ttl-seconds: 21600")
 	instance_id=$(curl -H "X-aws-ec2-metadata-token: $token" http://169.254.169.254/latest/meta-data/instance-id)
+	# the auto scaling group may spread instances over several subnets, additional nics must be in the instance subnet
+	mac=$(curl -H "X-aws-ec2-metadata-token: $token" http://169.254.169.254/latest/meta-data/mac)
+	subnet_id=$(curl -H "X-aws-ec2-metadata-token: $token" http://169.254.169.254/latest/meta-data/network/interfaces/macs/$mac/subnet-id)
 
 	for (( i=1; i<=$nics_num; i++ ))
 	do
 		eni_file="/tmp/eni-$i.json"
-		aws ec2 create-network-interface --region $region --subnet-id $subnet_id  --groups $groups > $eni_file
+		# nics are tagged on creation, so that the terminate lambda and cluster destroy find the ones leaked by failed joins.
+		# The instance role must allow ec2:CreateTags on created network interfaces, an untagged nic is never created.
+		tag_specifications="[{\"ResourceType\":\"network-interface\",\"Tags\":${eni_tags%%]},{\"Key\":\"%s\",\"Value\":\"$(date +%%s)\"}]}]"
+		if ! aws ec2 create-network-interface --region $region --subnet-id $subnet_id  --groups $groups --tag-specifications "$tag_specifications" > $eni_file; then
+			echo "failed creating tagged network interface"
+			shutdown now
+			exit 1
+		fi
 		network_interface_id=$(cat $eni_file | python3 -c "import sys, json; print(json.load(sys.stdin)['NetworkInterface']['NetworkInterfaceId'])")
-		aws ec2 attach-network-interface --region $region --device-index $i --instance-id $instance_id --network-interface-id $network_interface_id
+		attachment_id=$(aws ec2 attach-network-interface --region $region --device-index $i --instance-id $instance_id --network-interface-id $network_interface_id --query AttachmentId --output text)
+		aws ec2 modify-network-interface-attribute --region $region --network-interface-id $network_interface_id --attachment AttachmentId=$attachment_id,DeleteOnTermination=true || echo "failed setting $network_interface_id delete on termination"
 	done
 
 	set +x
@@ -73,10 +107,11 @@ func getUserData(restApiGateway apigateway.RestApiGateway, subnetId, instanceTyp
 	return fmt.Sprintf(
 		dedent.Dedent(userDataTemplate),
 		env.Config.Region,
-		subnetId,
 		securityGroupsIdsStr,
 		additionalNicsNum,
 		restApiGateway.Url(),
+		getEniTags(tags),
+		common.NetworkInterfaceCreatedTagKey,
 		restApiGateway.ApiKey,
 	)
 }
@@ -89,41 +124,49 @@ func getKeyName(keyName string) *string {
 	}
 }
 
-func CreateLaunchTemplate(tags []*ec2.Tag, hostGroupName common.HostGroupName, hostGroupParams common.HostGroupParams, restApiGateway apigateway.RestApiGateway, launchTemplateName string, associatePublicIpAddress bool) (err error) {
-	svc := connectors.GetAWSSession().EC2
-	userData := getUserData(restApiGateway, hostGroupParams.Subnet, hostGroupParams.InstanceType, hostGroupParams.SecurityGroupsIds)
+// generateLaunchTemplateData returns the launch template data of new templates and versions, params without http tokens
+// keep the metadata options of the source version
+func generateLaunchTemplateData(tags []*ec2.Tag, hostGroupName common.HostGroupName, hostGroupParams common.HostGroupParams, restApiGateway apigateway.RestApiGateway, associatePublicIpAddress bool) (launchTemplateData *ec2.RequestLaunchTemplateData) {
+	userData := GetUserData(restApiGateway, hostGroupParams.InstanceType, hostGroupParams.SecurityGroupsIds, tags)
 	keyName := getKeyName(hostGroupParams.KeyName)
 
-	input := &ec2.CreateLaunchTemplateInput{
-		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
-			ImageId:               &hostGroupParams.ImageID,
-			InstanceType:          &hostGroupParams.InstanceType,
-			KeyName:               keyName,
-			UserData:              aws.String(base64.StdEncoding.EncodeToString([]byte(userData))),
-			DisableApiTermination: aws.Bool(true),
-			IamInstanceProfile: &ec2.LaunchTemplateIamInstanceProfileSpecificationRequest{
-				Arn: &hostGroupParams.IamArn,
-			},
-			BlockDeviceMappings: generateBlockDeviceMappingRequest(hostGroupName, hostGroupParams.VolumesInfo),
-			TagSpecifications: []*ec2.LaunchTemplateTagSpecificationRequest{
-				{
-					ResourceType: aws.String("instance"),
-					Tags:         tags,
-				},
-			},
-			NetworkInterfaces: []*ec2.LaunchTemplateInstanceNetworkInterfaceSpecificationRequest{
-				{
-					AssociatePublicIpAddress: aws.Bool(associatePublicIpAddress),
-					DeviceIndex:              aws.Int64(0),
-					Ipv6AddressCount:         aws.Int64(0),
-					SubnetId:                 &hostGroupParams.Subnet,
-					Groups:                   hostGroupParams.SecurityGroupsIds,
-				},
+	launchTemplateData = &ec2.RequestLaunchTemplateData{
+		ImageId:               &hostGroupParams.ImageID,
+		InstanceType:          &hostGroupParams.InstanceType,
+		KeyName:               keyName,
+		UserData:              aws.String(base64.StdEncoding.EncodeToString([]byte(userData))),
+		DisableApiTermination: aws.Bool(true),
+		IamInstanceProfile: &ec2.LaunchTemplateIamInstanceProfileSpecificationRequest{
+			Arn: &hostGroupParams.IamArn,
+		},
+		BlockDeviceMappings: generateBlockDeviceMappingRequest(hostGroupName, hostGroupParams.VolumesInfo),
+		TagSpecifications: []*ec2.LaunchTemplateTagSpecificationRequest{
+			{
+				ResourceType: aws.String("instance"),
+				Tags:         tags,
 			},
-			MetadataOptions: &ec2.LaunchTemplateInstanceMetadataOptionsRequest{
-				HttpTokens: &hostGroupParams.HttpTokens,
+		},
+		NetworkInterfaces: []*ec2.LaunchTemplateInstanceNetworkInterfaceSpecificationRequest{
+			{
+				AssociatePublicIpAddress: aws.Bool(associatePublicIpAddress),
+				DeviceIndex:              aws.Int64(0),
+				Ipv6AddressCount:         aws.Int64(0),
+				Groups:                   hostGroupParams.SecurityGroupsIds,
 			},
 		},
+	}
+	if hostGroupParams.HttpTokens != "" {
+		launchTemplateData.MetadataOptions = &ec2.LaunchTemplateInstanceMetadataOptionsRequest{
+			HttpTokens: &hostGroupParams.HttpTokens,
+		}
+	}
+	return
+}
+
+func CreateLaunchTemplate(tags []*ec2.Tag, hostGroupName common.HostGroupName, hostGroupParams common.HostGroupParams, restApiGateway apigateway.RestApiGateway, launchTemplateName string, associatePublicIpAddress bool) (err error) {
+	svc := connectors.GetAWSSession().EC2
+	input := &ec2.CreateLaunchTemplateInput{
+		LaunchTemplateData: generateLaunchTemplateData(tags, hostGroupName, hostGroupParams, restApiGateway, associatePublicIpAddress),
 		VersionDescription: aws.String(LaunchtemplateVersion),
 		LaunchTemplateName: aws.String(launchTemplateName),
 		TagSpecifications: []*ec2.TagSpecification{
@@ -163,36 +206,8 @@ func CreateNewLaunchTemplateVersion(tags []*ec2.Tag, hostGroupName common.HostGr
 		return
 	}
 
-	userData := getUserData(restApiGateway, hostGroupParams.Subnet, hostGroupParams.InstanceType, hostGroupParams.SecurityGroupsIds)
-	keyName := getKeyName(hostGroupParams.KeyName)
-
 	input := &ec2.CreateLaunchTemplateVersionInput{
-		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
-			ImageId:               &hostGroupParams.ImageID,
-			InstanceType:          &hostGroupParams.InstanceType,
-			KeyName:               keyName,
-			UserData:              aws.String(base64.StdEncoding.EncodeToString([]byte(userData))),
-			DisableApiTermination: aws.Bool(true),
-			IamInstanceProfile: &ec2.LaunchTemplateIamInstanceProfileSpecificationRequest{
-				Arn: &hostGroupParams.IamArn,
-			},
-			BlockDeviceMappings: generateBlockDeviceMappingRequest(hostGroupName, hostGroupParams.VolumesInfo),
-			TagSpecifications: []*ec2.LaunchTemplateTagSpecificationRequest{
-				{
-					ResourceType: aws.String("instance"),
-					Tags:         tags,
-				},
-			},
-			NetworkInterfaces: []*ec2.LaunchTemplateInstanceNetworkInterfaceSpecificationRequest{
-				{
-					AssociatePublicIpAddress: aws.Bool(associatePublicIpAddress),
-					DeviceIndex:              aws.Int64(0),
-					Ipv6AddressCount:         aws.Int64(0),
-					SubnetId:                 &hostGroupParams.Subnet,
-					Groups:                   hostGroupParams.SecurityGroupsIds,
-				},
-			},
-		},
+		LaunchTemplateData: generateLaunchTemplateData(tags, hostGroupName, hostGroupParams, restApiGateway, associatePublicIpAddress),
 		VersionDescription: aws.String(LaunchtemplateVersion),
 		LaunchTemplateName: aws.String(launchTemplateName),
 		SourceVersion:      aws.String(strconv.Itoa(int(*launchTemplateVersion.VersionNumber))),
@@ -209,6 +224,36 @@ func CreateNewLaunchTemplateVersion(tags []*ec2.Tag, hostGroupName common.HostGr
 	return
 }
 
+// CreateTagsLaunchTemplateVersion creates a new version from the latest one that only changes the instances tags, the
+// rest of the launch template data, user data included, stays the one the deployed wekactl version generated
+func CreateTagsLaunchTemplateVersion(launchTemplateName string, tags []*ec2.Tag) (newVersion string, err error) {
+	svc := connectors.GetAWSSession().EC2
+	launchTemplateVersion, err := GetLatestLaunchTemplateVersion(launchTemplateName)
+	if err != nil {
+		return
+	}
+
+	launchTemplateVersionOutput, err := svc.CreateLaunchTemplateVersion(&ec2.CreateLaunchTemplateVersionInput{
+		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
+			TagSpecifications: []*ec2.LaunchTemplateTagSpecificationRequest{
+				{
+					ResourceType: aws.String("instance"),
+					Tags:         tags,
+				},
+			},
+		},
+		VersionDescription: launchTemplateVersion.VersionDescription,
+		LaunchTemplateName: aws.String(launchTemplateName),
+		SourceVersion:      aws.String("$Latest"),
+	})
+	if err != nil {
+		return
+	}
+	newVersion = strconv.Itoa(int(*launchTemplateVersionOutput.LaunchTemplateVersion.VersionNumber))
+	log.Debug().Msgf("launch template %s version %s with the new instances tags was created", launchTemplateName, newVersion)
+	return
+}
+
 func ModifyLaunchTemplateDefaultVersion(launchTemplateName, newVersion string) (err error) {
 	svc := connectors.GetAWSSession().EC2
 	_, err = svc.ModifyLaunchTemplate(&ec2.ModifyLaunchTemplateInput{
@@ -283,3 +328,147 @@ func DeleteLaunchTemplates(launchTemplates []*ec2.LaunchTemplate) error {
 	}
 	return nil
 }
+
+// getBlockDevicesDrift compares the launch template block devices with the generated ones by device name
+func getBlockDevicesDrift(expected []*ec2.LaunchTemplateBlockDeviceMappingRequest, actual []*ec2.LaunchTemplateBlockDeviceMapping) (drifts []string) {
+	actualDevices := make(map[string]*ec2.LaunchTemplateEbsBlockDevice)
+	for _, mapping := range actual {
+		actualDevices[aws.StringValue(mapping.DeviceName)] = mapping.Ebs
+	}
+	for _, mapping := range expected {
+		deviceName := aws.StringValue(mapping.DeviceName)
+		ebs, ok := actualDevices[deviceName]
+		if !ok || ebs == nil {
+			drifts = append(drifts, fmt.Sprintf("block device %s is missing", deviceName))
+			continue
+		}
+		delete(actualDevices, deviceName)
+		if aws.StringValue(ebs.VolumeType) != aws.StringValue(mapping.Ebs.VolumeType) {
+			drifts = append(drifts, fmt.Sprintf("block device %s volume type is %s instead of %s",
+				deviceName, aws.StringValue(ebs.VolumeType), aws.StringValue(mapping.Ebs.VolumeType)))
+		}
+		if aws.Int64Value(ebs.VolumeSize) != aws.Int64Value(mapping.Ebs.VolumeSize) {
+			drifts = append(drifts, fmt.Sprintf("block device %s volume size is %d instead of %d",
+				deviceName, aws.Int64Value(ebs.VolumeSize), aws.Int64Value(mapping.Ebs.VolumeSize)))
+		}
+		if aws.BoolValue(ebs.DeleteOnTermination) != aws.BoolValue(mapping.Ebs.DeleteOnTermination) {
+			drifts = append(drifts, fmt.Sprintf("block device %s delete on termination is not %t",
+				deviceName, aws.BoolValue(mapping.Ebs.DeleteOnTermination)))
+		}
+	}
+	var unexpectedDevices []string
+	for deviceName := range actualDevices {
+		unexpectedDevices = append(unexpectedDevices, deviceName)
+	}
+	sort.Strings(unexpectedDevices)
+	for _, deviceName := range unexpectedDevices {
+		drifts = append(drifts, fmt.Sprintf("block device %s is not generated by wekactl", deviceName))
+	}
+	return
+}
+
+func getSortedStrings(values []*string) []string {
+	sorted := aws.StringValueSlice(values)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// getLaunchTemplateDataDrift compares the launch template data with the generated one, user data embeds tags in no
+// particular order, so only its join url and api key are compared
+func getLaunchTemplateDataDrift(expected *ec2.RequestLaunchTemplateData, actual *ec2.ResponseLaunchTemplateData, restApiGateway apigateway.RestApiGateway) (drifts []string, err error) {
+	type stringField struct {
+		name     string
+		expected *string
+		actual   *string
+	}
+	stringFields := []stringField{
+		{"image", expected.ImageId, actual.ImageId},
+		{"instance type", expected.InstanceType, actual.InstanceType},
+		{"key name", expected.KeyName, actual.KeyName},
+	}
+	if actual.IamInstanceProfile == nil {
+		drifts = append(drifts, "instance profile is missing")
+	} else {
+		stringFields = append(stringFields, stringField{"instance profile", expected.IamInstanceProfile.Arn, actual.IamInstanceProfile.Arn})
+	}
+	if expected.MetadataOptions != nil {
+		var httpTokens *string
+		if actual.MetadataOptions != nil {
+			httpTokens = actual.MetadataOptions.HttpTokens
+		}
+		stringFields = append(stringFields, stringField{"metadata http tokens", expected.MetadataOptions.HttpTokens, httpTokens})
+	}
+	for _, field := range stringFields {
+		if aws.StringValue(field.actual) != aws.StringValue(field.expected) {
+			drifts = append(drifts, fmt.Sprintf("%s is \"%s\" instead of \"%s\"",
+				field.name, aws.StringValue(field.actual), aws.StringValue(field.expected)))
+		}
+	}
+
+	if !aws.BoolValue(actual.DisableApiTermination) {
+		drifts = append(drifts, "api termination is not disabled")
+	}
+	if len(actual.NetworkInterfaces) == 0 {
+		drifts = append(drifts, "network interface is missing")
+	} else {
+		expectedInterface := expected.NetworkInterfaces[0]
+		actualInterface := actual.NetworkInterfaces[0]
+		if aws.BoolValue(actualInterface.AssociatePublicIpAddress) != aws.BoolValue(expectedInterface.AssociatePublicIpAddress) {
+			drifts = append(drifts, fmt.Sprintf("associate public ip address is not %t", aws.BoolValue(expectedInterface.AssociatePublicIpAddress)))
+		}
+		expectedGroups := getSortedStrings(expectedInterface.Groups)
+		actualGroups := getSortedStrings(actualInterface.Groups)
+		if strings.Join(actualGroups, ",") != strings.Join(expectedGroups, ",") {
+			drifts = append(drifts, fmt.Sprintf("security groups are %v instead of %v", actualGroups, expectedGroups))
+		}
+	}
+	drifts = append(drifts, getBlockDevicesDrift(expected.BlockDeviceMappings, actual.BlockDeviceMappings)...)
+
+	userData, err := base64.StdEncoding.DecodeString(aws.StringValue(actual.UserData))
+	if err != nil {
+		return
+	}
+	if !strings.Contains(string(userData), fmt.Sprintf("join_url=%s\n", restApiGateway.Url())) {
+		drifts = append(drifts, "user data join url differs")
+	}
+	if !strings.Contains(string(userData), fmt.Sprintf(`echo "%s"`, restApiGateway.ApiKey)) {
+		drifts = append(drifts, "user data api key differs")
+	}
+	return
+}
+
+// GetLaunchTemplateDrift compares the latest launch template version with the data generated from the hostgroup params
+func GetLaunchTemplateDrift(hostGroupName common.HostGroupName, hostGroupParams common.HostGroupParams, restApiGateway apigateway.RestApiGateway, launchTemplateName string, associatePublicIpAddress bool) (drifts []string, err error) {
+	svc := connectors.GetAWSSession().EC2
+
+	launchTemplateVersion, err := GetLatestLaunchTemplateVersion(launchTemplateName)
+	if err != nil {
+		return
+	}
+
+	templatesOutput, err := svc.DescribeLaunchTemplates(&ec2.DescribeLaunchTemplatesInput{
+		LaunchTemplateNames: []*string{&launchTemplateName},
+	})
+	if err != nil {
+		return
+	}
+	if len(templatesOutput.LaunchTemplates) > 0 &&
+		aws.Int64Value(templatesOutput.LaunchTemplates[0].DefaultVersionNumber) != aws.Int64Value(launchTemplateVersion.VersionNumber) {
+		drifts = append(drifts, "default version is not the latest version")
+	}
+
+	expected := generateLaunchTemplateData(nil, hostGroupName, hostGroupParams, restApiGateway, associatePublicIpAddress)
+	dataDrifts, err := getLaunchTemplateDataDrift(expected, launchTemplateVersion.LaunchTemplateData, restApiGateway)
+	drifts = append(drifts, dataDrifts...)
+	return
+}
+
+// UpdateLaunchTemplateTags tags the launch template itself, the instances tags are part of the launch template data
+// and need a new version
+func UpdateLaunchTemplateTags(launchTemplateName string, tags []*ec2.Tag, removedKeys []string) error {
+	launchTemplateVersion, err := GetLatestLaunchTemplateVersion(launchTemplateName)
+	if err != nil {
+		return err
+	}
+	return common.UpdateEc2Tags([]*string{launchTemplateVersion.LaunchTemplateId}, tags, removedKeys)
+}
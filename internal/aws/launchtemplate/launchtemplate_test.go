@@ -0,0 +1,112 @@
+package launchtemplate
+
+import (
+	"encoding/base64"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"reflect"
+	"testing"
+	"wekactl/internal/aws/apigateway"
+	"wekactl/internal/aws/common"
+)
+
+func TestGetLaunchTemplateDataDrift(t *testing.T) {
+	restApiGateway := apigateway.RestApiGateway{Id: "api", ApiKey: "key"}
+	params := common.HostGroupParams{
+		SecurityGroupsIds: aws.StringSlice([]string{"sg-2", "sg-1"}),
+		ImageID:           "ami-1",
+		IamArn:            "arn:aws:iam::123456789012:instance-profile/weka",
+		InstanceType:      "i3en.2xlarge",
+		VolumesInfo:       []common.VolumeInfo{{Name: "/dev/xvda", Type: "gp3", Size: 48}},
+		HttpTokens:        "optional",
+	}
+	expected := generateLaunchTemplateData(nil, "Backends", params, restApiGateway, true)
+
+	generated := func() *ec2.ResponseLaunchTemplateData {
+		return &ec2.ResponseLaunchTemplateData{
+			ImageId:               aws.String("ami-1"),
+			InstanceType:          aws.String("i3en.2xlarge"),
+			UserData:              expected.UserData,
+			DisableApiTermination: aws.Bool(true),
+			IamInstanceProfile: &ec2.LaunchTemplateIamInstanceProfileSpecification{
+				Arn: aws.String("arn:aws:iam::123456789012:instance-profile/weka"),
+			},
+			BlockDeviceMappings: []*ec2.LaunchTemplateBlockDeviceMapping{
+				{
+					DeviceName: aws.String("/dev/xvda"),
+					Ebs: &ec2.LaunchTemplateEbsBlockDevice{
+						VolumeType:          aws.String("gp3"),
+						VolumeSize:          aws.Int64(48),
+						DeleteOnTermination: aws.Bool(true),
+					},
+				},
+			},
+			NetworkInterfaces: []*ec2.LaunchTemplateInstanceNetworkInterfaceSpecification{
+				{AssociatePublicIpAddress: aws.Bool(true), Groups: aws.StringSlice([]string{"sg-1", "sg-2"})},
+			},
+			MetadataOptions: &ec2.LaunchTemplateInstanceMetadataOptions{HttpTokens: aws.String("optional")},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		edit   func(data *ec2.ResponseLaunchTemplateData)
+		drifts []string
+	}{
+		{name: "generated", edit: func(data *ec2.ResponseLaunchTemplateData) {}},
+		{
+			name: "instance",
+			edit: func(data *ec2.ResponseLaunchTemplateData) {
+				data.ImageId = aws.String("ami-2")
+				data.InstanceType = aws.String("i3en.3xlarge")
+				data.IamInstanceProfile.Arn = aws.String("arn:aws:iam::123456789012:instance-profile/other")
+				data.MetadataOptions.HttpTokens = aws.String("required")
+			},
+			drifts: []string{
+				`image is "ami-2" instead of "ami-1"`,
+				`instance type is "i3en.3xlarge" instead of "i3en.2xlarge"`,
+				`instance profile is "arn:aws:iam::123456789012:instance-profile/other" instead of "arn:aws:iam::123456789012:instance-profile/weka"`,
+				`metadata http tokens is "required" instead of "optional"`,
+			},
+		},
+		{
+			name: "network interface",
+			edit: func(data *ec2.ResponseLaunchTemplateData) {
+				data.NetworkInterfaces[0].AssociatePublicIpAddress = aws.Bool(false)
+				data.NetworkInterfaces[0].Groups = aws.StringSlice([]string{"sg-1"})
+			},
+			drifts: []string{"associate public ip address is not true", "security groups are [sg-1] instead of [sg-1 sg-2]"},
+		},
+		{
+			name: "block devices",
+			edit: func(data *ec2.ResponseLaunchTemplateData) {
+				data.BlockDeviceMappings[0].Ebs.VolumeSize = aws.Int64(100)
+				data.BlockDeviceMappings = append(data.BlockDeviceMappings, &ec2.LaunchTemplateBlockDeviceMapping{
+					DeviceName: aws.String("/dev/sdp"),
+					Ebs:        &ec2.LaunchTemplateEbsBlockDevice{},
+				})
+			},
+			drifts: []string{"block device /dev/xvda volume size is 100 instead of 48", "block device /dev/sdp is not generated by wekactl"},
+		},
+		{
+			name: "user data",
+			edit: func(data *ec2.ResponseLaunchTemplateData) {
+				data.UserData = aws.String(base64.StdEncoding.EncodeToString([]byte("#!/bin/bash")))
+			},
+			drifts: []string{"user data join url differs", "user data api key differs"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := generated()
+			test.edit(data)
+			drifts, err := getLaunchTemplateDataDrift(expected, data, restApiGateway)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(drifts, test.drifts) {
+				t.Errorf("got drifts %v, expected %v", drifts, test.drifts)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package scalemachine
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+	"github.com/weka/go-cloud-lib/protocol"
+	"strings"
+	"time"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/connectors"
+	"wekactl/internal/env"
+)
+
+const redacted = "<redacted>"
+
+// credentialKeys are the HostGroupInfoResponse fields holding base64 weka credentials
+var credentialKeys = []string{"username", "password"}
+
+type ExecutionStep struct {
+	Name   string
+	Input  string
+	Output string
+}
+
+// ExecutionDetails is a state machine execution with its task steps, inputs and outputs have the credentials redacted
+type ExecutionDetails struct {
+	Arn    string
+	Name   string
+	Status string
+	Start  time.Time
+	Stop   *time.Time
+	Steps  []ExecutionStep
+	Error  string
+	Cause  string
+}
+
+type ExecutionSummary struct {
+	HostsRemoved        []string
+	InstancesTerminated []string
+	TransientErrors     []string
+}
+
+func (d ExecutionDetails) Duration() time.Duration {
+	if d.Stop == nil {
+		return time.Since(d.Start).Round(time.Second)
+	}
+	return d.Stop.Sub(d.Start).Round(time.Millisecond)
+}
+
+func (d ExecutionDetails) step(name string) (step ExecutionStep, ok bool) {
+	for _, step = range d.Steps {
+		if step.Name == name {
+			return step, true
+		}
+	}
+	return
+}
+
+// Summary returns what the execution did: instances of removed hosts returned by scale, instances set to termination and
+// the transient errors of both, or the failure of the execution
+func (d ExecutionDetails) Summary() (summary ExecutionSummary) {
+	if step, ok := d.step("Scale"); ok && step.Output != "" {
+		scaleResponse := protocol.ScaleResponse{}
+		if json.Unmarshal([]byte(step.Output), &scaleResponse) == nil {
+			for _, instance := range scaleResponse.ToTerminate {
+				summary.HostsRemoved = append(summary.HostsRemoved, instance.Id)
+			}
+		}
+	}
+	if step, ok := d.step("Terminate"); ok && step.Output != "" {
+		terminateResponse := protocol.TerminatedInstancesResponse{}
+		if json.Unmarshal([]byte(step.Output), &terminateResponse) == nil {
+			for _, instance := range terminateResponse.Instances {
+				summary.InstancesTerminated = append(summary.InstancesTerminated, instance.InstanceId)
+			}
+			summary.TransientErrors = terminateResponse.TransientErrors
+		}
+	}
+	if len(summary.TransientErrors) == 0 && d.Status == sfn.ExecutionStatusFailed {
+		summary.TransientErrors = []string{d.failure()}
+	}
+	return
+}
+
+// failure returns the lambda error message of a failed execution, or its raw error and cause
+func (d ExecutionDetails) failure() string {
+	lambdaError := struct {
+		ErrorMessage string `json:"errorMessage"`
+	}{}
+	if json.Unmarshal([]byte(d.Cause), &lambdaError) == nil && lambdaError.ErrorMessage != "" {
+		return lambdaError.ErrorMessage
+	}
+	return fmt.Sprintf("%s: %s", d.Error, d.Cause)
+}
+
+// redactValue replaces the credential keys values of the objects at any depth of the decoded json value, found reports
+// whether any was replaced
+func redactValue(value interface{}) (found bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			credential := false
+			for _, credentialKey := range credentialKeys {
+				if strings.EqualFold(key, credentialKey) {
+					credential = true
+				}
+			}
+			if credential {
+				v[key] = redacted
+				found = true
+			} else if redactValue(item) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if redactValue(item) {
+				found = true
+			}
+		}
+	}
+	return
+}
+
+func redactCredentials(data string) string {
+	var value interface{}
+	if json.Unmarshal([]byte(data), &value) != nil {
+		return data
+	}
+	if !redactValue(value) {
+		return data
+	}
+	redactedData, err := json.Marshal(value)
+	if err != nil {
+		return redacted
+	}
+	return string(redactedData)
+}
+
+// ListExecutions returns state machine executions started after the given time, newest first, at most limit of them
+// unless limit is 0
+func ListExecutions(stateMachineArn string, since time.Time, failedOnly bool, limit int) (executions []*sfn.ExecutionListItem, err error) {
+	svc := connectors.GetAWSSession().SFN
+	input := &sfn.ListExecutionsInput{
+		StateMachineArn: &stateMachineArn,
+	}
+	if failedOnly {
+		input.StatusFilter = aws.String(sfn.ExecutionStatusFailed)
+	}
+	err = svc.ListExecutionsPages(input, func(page *sfn.ListExecutionsOutput, lastPage bool) bool {
+		for _, execution := range page.Executions {
+			if execution.StartDate.Before(since) || (limit > 0 && len(executions) == limit) {
+				return false
+			}
+			executions = append(executions, execution)
+		}
+		return true
+	})
+	return
+}
+
+// GetRegionLastExecution returns the latest execution of the state machine, nil if it has none or doesn't exist
+func GetRegionLastExecution(region, stateMachineName string) (execution *sfn.ExecutionListItem, err error) {
+	stateMachineArn, err := GetRegionStateMachineArn(region, stateMachineName)
+	if err != nil {
+		return
+	}
+	svc := connectors.GetRegionAWSSession(region).SFN
+	output, err := svc.ListExecutions(&sfn.ListExecutionsInput{
+		StateMachineArn: &stateMachineArn,
+		MaxResults:      aws.Int64(1),
+	})
+	if err != nil {
+		if _, ok := err.(*sfn.StateMachineDoesNotExist); ok {
+			err = nil
+		}
+		return
+	}
+	if len(output.Executions) > 0 {
+		execution = output.Executions[0]
+	}
+	return
+}
+
+// GetExecutionArn accepts execution arn or the execution name of the given state machine
+func GetExecutionArn(stateMachineName, execution string) (arn string, err error) {
+	if strings.HasPrefix(execution, "arn:") {
+		return execution, nil
+	}
+	account, err := common.GetAccountId()
+	if err != nil {
+		return
+	}
+	arn = fmt.Sprintf("arn:aws:states:%s:%s:execution:%s:%s", env.Config.Region, account, stateMachineName, execution)
+	return
+}
+
+func describeExecution(executionArn string) (details ExecutionDetails, err error) {
+	svc := connectors.GetAWSSession().SFN
+	execution, err := svc.DescribeExecution(&sfn.DescribeExecutionInput{ExecutionArn: &executionArn})
+	if err != nil {
+		return
+	}
+	details = ExecutionDetails{
+		Arn:    executionArn,
+		Name:   *execution.Name,
+		Status: *execution.Status,
+		Start:  *execution.StartDate,
+		Stop:   execution.StopDate,
+	}
+	if execution.Error != nil {
+		details.Error = *execution.Error
+	}
+	if execution.Cause != nil {
+		details.Cause = *execution.Cause
+	}
+	return
+}
+
+func GetExecutionDetails(executionArn string) (details ExecutionDetails, err error) {
+	details, err = describeExecution(executionArn)
+	if err != nil {
+		return
+	}
+
+	svc := connectors.GetAWSSession().SFN
+	err = svc.GetExecutionHistoryPages(&sfn.GetExecutionHistoryInput{
+		ExecutionArn: &executionArn,
+	}, func(page *sfn.GetExecutionHistoryOutput, lastPage bool) bool {
+		for _, event := range page.Events {
+			if event.StateEnteredEventDetails != nil && event.StateEnteredEventDetails.Input != nil {
+				details.Steps = append(details.Steps, ExecutionStep{
+					Name:  *event.StateEnteredEventDetails.Name,
+					Input: redactCredentials(*event.StateEnteredEventDetails.Input),
+				})
+			}
+			if event.StateExitedEventDetails != nil && event.StateExitedEventDetails.Output != nil {
+				for i := range details.Steps {
+					if details.Steps[i].Name == *event.StateExitedEventDetails.Name {
+						details.Steps[i].Output = redactCredentials(*event.StateExitedEventDetails.Output)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return
+}
+
+// GetExecutionFailures returns the errors of state machine executions that failed since the given time, newest first
+func GetExecutionFailures(stateMachineArn string, since time.Time) (failures []string, err error) {
+	executions, err := ListExecutions(stateMachineArn, since, true, 0)
+	if err != nil {
+		return
+	}
+	for _, execution := range executions {
+		var details ExecutionDetails
+		details, err = describeExecution(*execution.ExecutionArn)
+		if err != nil {
+			return
+		}
+		failures = append(failures, details.failure())
+	}
+	return
+}
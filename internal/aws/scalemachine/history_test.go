@@ -0,0 +1,59 @@
+package scalemachine
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRedactCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "top level",
+			data:     `{"username":"dXNlcg==","Password":"cGFzcw==","backend_ips":["10.0.0.1"]}`,
+			expected: `{"username":"<redacted>","Password":"<redacted>","backend_ips":["10.0.0.1"]}`,
+		},
+		{
+			name:     "nested object",
+			data:     `{"Payload":{"info":{"username":"dXNlcg==","password":"cGFzcw=="}},"desired_capacity":3}`,
+			expected: `{"Payload":{"info":{"username":"<redacted>","password":"<redacted>"}},"desired_capacity":3}`,
+		},
+		{
+			name:     "array of objects",
+			data:     `[{"password":"cGFzcw=="},{"id":"i-1","hosts":[{"PASSWORD":"cGFzcw=="}]}]`,
+			expected: `[{"password":"<redacted>"},{"id":"i-1","hosts":[{"PASSWORD":"<redacted>"}]}]`,
+		},
+		{
+			name:     "no credentials",
+			data:     `{"instances":[{"id":"i-1"}]}`,
+			expected: `{"instances":[{"id":"i-1"}]}`,
+		},
+		{
+			name:     "not json",
+			data:     `password=secret`,
+			expected: `password=secret`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := redactCredentials(test.data)
+			var got, expected interface{}
+			if json.Unmarshal([]byte(test.expected), &expected) != nil {
+				if result != test.expected {
+					t.Errorf("got %s, expected %s", result, test.expected)
+				}
+				return
+			}
+			if err := json.Unmarshal([]byte(result), &got); err != nil {
+				t.Fatalf("redacted data isn't json: %s", result)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("got %s, expected %s", result, test.expected)
+			}
+		})
+	}
+}
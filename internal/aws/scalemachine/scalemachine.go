@@ -6,15 +6,15 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/sfn"
 	"github.com/rs/zerolog/log"
+	"reflect"
 	"wekactl/internal/aws/common"
 	"wekactl/internal/cluster"
 	"wekactl/internal/connectors"
 	"wekactl/internal/env"
 )
 
-func CreateStateMachine(tags []*sfn.Tag, lambda StateMachineLambdasArn, roleArn, stateMachineName string) (*string, error) {
-	svc := connectors.GetAWSSession().SFN
-
+// GetStateMachineDefinition returns the json definition of the state machine running the given lambdas
+func GetStateMachineDefinition(lambda StateMachineLambdasArn) (definition string, err error) {
 	states := make(map[string]interface{})
 	states["HostGroupInfo"] = NextState{
 		Type:     "Task",
@@ -62,9 +62,19 @@ func CreateStateMachine(tags []*sfn.Tag, lambda StateMachineLambdasArn, roleArn,
 	b, err := json.Marshal(&stateMachine)
 	if err != nil {
 		log.Debug().Msg("Error marshaling stateMachine")
+		return
+	}
+	definition = string(b)
+	return
+}
+
+func CreateStateMachine(tags []*sfn.Tag, lambda StateMachineLambdasArn, roleArn, stateMachineName string) (*string, error) {
+	svc := connectors.GetAWSSession().SFN
+
+	definition, err := GetStateMachineDefinition(lambda)
+	if err != nil {
 		return nil, err
 	}
-	definition := string(b)
 	log.Debug().Msgf("Creating state machine :%s", stateMachineName)
 
 	result, err := svc.CreateStateMachine(&sfn.CreateStateMachineInput{
@@ -127,11 +137,15 @@ func GetStateMachineVersion(stateMachineName string) (version string, err error)
 }
 
 func GetStateMachineArn(stateMachineName string) (arn string, err error) {
-	account, err := common.GetAccountId()
+	return GetRegionStateMachineArn(env.Config.Region, stateMachineName)
+}
+
+func GetRegionStateMachineArn(region, stateMachineName string) (arn string, err error) {
+	account, err := common.GetRegionAccountId(region)
 	if err != nil {
 		return
 	}
-	arn = fmt.Sprintf("arn:aws:states:%s:%s:stateMachine:%s", env.Config.Region, account, stateMachineName)
+	arn = fmt.Sprintf("arn:aws:states:%s:%s:stateMachine:%s", region, account, stateMachineName)
 	return
 }
 
@@ -196,3 +210,43 @@ func UpdateStateMachineRoleArn(stateMachineArn, roleArn string) error {
 	})
 	return err
 }
+
+// EqualDefinitions compares two json state machine definitions regardless of their formatting
+func EqualDefinitions(liveDefinition, definition string) bool {
+	var liveStateMachine, stateMachine interface{}
+	if json.Unmarshal([]byte(liveDefinition), &liveStateMachine) != nil || json.Unmarshal([]byte(definition), &stateMachine) != nil {
+		return false
+	}
+	return reflect.DeepEqual(liveStateMachine, stateMachine)
+}
+
+func DescribeStateMachine(stateMachineArn string) (*sfn.DescribeStateMachineOutput, error) {
+	svc := connectors.GetAWSSession().SFN
+	return svc.DescribeStateMachine(&sfn.DescribeStateMachineInput{StateMachineArn: &stateMachineArn})
+}
+
+func UpdateStateMachine(stateMachineArn, definition, roleArn string) error {
+	svc := connectors.GetAWSSession().SFN
+	_, err := svc.UpdateStateMachine(&sfn.UpdateStateMachineInput{
+		StateMachineArn: &stateMachineArn,
+		Definition:      &definition,
+		RoleArn:         &roleArn,
+	})
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("state machine %s definition and role were updated", stateMachineArn)
+	return nil
+}
+
+func UpdateStateMachineTags(stateMachineArn string, tags []*sfn.Tag, removedKeys []string) error {
+	svc := connectors.GetAWSSession().SFN
+	if len(removedKeys) > 0 {
+		_, err := svc.UntagResource(&sfn.UntagResourceInput{ResourceArn: &stateMachineArn, TagKeys: aws.StringSlice(removedKeys)})
+		if err != nil {
+			return err
+		}
+	}
+	_, err := svc.TagResource(&sfn.TagResourceInput{ResourceArn: &stateMachineArn, Tags: tags})
+	return err
+}
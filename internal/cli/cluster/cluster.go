@@ -3,9 +3,9 @@ package cluster
 import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"wekactl/internal/env"
 )
 
-var Region string
 var StackName string
 var DryRun bool
 
@@ -18,16 +18,26 @@ var Cluster = &cobra.Command{
 		}
 	},
 	SilenceUsage: true,
+	Annotations:  map[string]string{env.DefaultClusterAnnotation: ""},
 	Aliases:      []string{"clusters"},
 }
 
 func init() {
 	//Cluster.AddCommand(createCmd)
 	Cluster.AddCommand(importCmd)
+	Cluster.AddCommand(preflightCmd)
 	Cluster.AddCommand(listCmd)
+	Cluster.AddCommand(statusCmd)
 	Cluster.AddCommand(destroyCmd)
 	Cluster.AddCommand(updateCmd)
+	Cluster.AddCommand(verifyCmd)
+	Cluster.AddCommand(versionsCmd)
+	Cluster.AddCommand(tagsCmd)
 	Cluster.AddCommand(changeCredentialsCmd)
 	Cluster.AddCommand(joinParamsCmd)
+	Cluster.AddCommand(joinHooksCmd)
+	Cluster.AddCommand(failureDomainCmd)
+	Cluster.AddCommand(exportCmd)
+	Cluster.AddCommand(applyCmd)
 	_ = Cluster.MarkPersistentFlagRequired("region")
 }
@@ -55,14 +55,16 @@ var destroyCmd = &cobra.Command{
 			}
 
 			resources = append(resources,
-				&cleaner.IamProfile{ClusterName: clusterName},
+				&cleaner.IamProfile{ClusterName: clusterName, RolesPath: clusterSettings.IamRolesPath, IamRoles: clusterSettings.IamRoles},
 				&cleaner.Lambda{ClusterName: clusterName},
 				&cleaner.ApiGateway{ClusterName: clusterName},
 				&cleaner.LaunchTemplate{ClusterName: clusterName},
 				&cleaner.ScaleMachine{ClusterName: clusterName},
 				&cleaner.CloudWatch{ClusterName: clusterName},
+				&cleaner.MetricAlarm{ClusterName: clusterName},
 				&cleaner.AutoscalingGroup{ClusterName: clusterName},
 				&cleaner.ApplicationLoadBalancer{ClusterName: clusterName},
+				&cleaner.VpcEndpoint{ClusterName: clusterName},
 				&cleaner.DynamoDb{ClusterName: clusterName},
 				&cleaner.KmsKey{ClusterName: clusterName},
 			)
@@ -88,9 +90,20 @@ var destroyCmd = &cobra.Command{
 					if err != nil {
 						log.Error().Err(err)
 					}
+					// additional nics are released only once their instances are gone
+					logging.UserProgress("Waiting for instances termination ...")
+					err = common.WaitForInstancesTermination(ids)
+					if err != nil {
+						return err
+					}
 				}
 			}
 
+			err = cluster.CleanupResource(&cleaner.NetworkInterface{ClusterName: clusterName}, DryRun)
+			if err != nil {
+				return err
+			}
+
 			logging.UserSuccess("Destroying finished successfully!")
 		} else {
 			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
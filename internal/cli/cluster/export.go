@@ -0,0 +1,68 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"wekactl/internal/aws/cluster"
+	cluster2 "wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var clusterFilePath string
+
+var exportCmd = &cobra.Command{
+	Use:   "export [flags]",
+	Short: "Export cluster settings to a yaml file",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			err := cluster.ExportCluster(cluster2.ClusterName(StackName), clusterFilePath)
+			if err != nil {
+				logging.UserFailure("Export failed!")
+				return err
+			}
+			logging.UserSuccess("Cluster settings were exported to %s", clusterFilePath)
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply [flags]",
+	Short: "Apply cluster settings from a yaml file written by export",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			err := cluster.ApplyClusterFile(clusterFilePath, DryRun)
+			if err != nil {
+				logging.UserFailure("Apply failed!")
+				return err
+			}
+			if !DryRun {
+				logging.UserSuccess("Apply finished successfully!")
+			}
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&StackName, "name", "n", "", "weka cluster name")
+	exportCmd.Flags().StringVarP(&clusterFilePath, "file", "f", "", "cluster file path")
+	_ = exportCmd.MarkFlagRequired("name")
+	_ = exportCmd.MarkFlagRequired("file")
+
+	applyCmd.Flags().StringVarP(&clusterFilePath, "file", "f", "", "cluster file path")
+	applyCmd.Flags().BoolVarP(&DryRun, "dry-run", "d", false, "validate the file and print the changes without applying them")
+	_ = applyCmd.MarkFlagRequired("file")
+}
@@ -0,0 +1,100 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"wekactl/internal/aws/common"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var failureDomainParams struct {
+	Name     string
+	Strategy string
+	Cmd      string
+}
+
+var failureDomainCmd = &cobra.Command{
+	Use:   "failure-domain [command] [flags]",
+	Short: "Manage failure domain strategy of joining backends",
+	Run: func(c *cobra.Command, _ []string) {
+		if err := c.Help(); err != nil {
+			log.Debug().Msgf("ignoring cobra error %q", err.Error())
+		}
+	},
+}
+
+var failureDomainSetCmd = &cobra.Command{
+	Use:   "set [flags]",
+	Short: "Set failure domain strategy, applies to backends joining from now on",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			strategy := common.FailureDomainStrategy(failureDomainParams.Strategy)
+			err := common.ValidateFailureDomain(strategy, failureDomainParams.Cmd)
+			if err != nil {
+				return err
+			}
+			name := cluster.ClusterName(failureDomainParams.Name)
+			clusterSettings, err := db.GetClusterSettings(name)
+			if err != nil {
+				return err
+			}
+			clusterSettings.FailureDomain = strategy
+			clusterSettings.FailureDomainCmd = failureDomainParams.Cmd
+			err = db.SaveClusterSettings(db.GetTableName(name), clusterSettings)
+			if err != nil {
+				logging.UserFailure("Setting failure domain strategy failed!")
+				return err
+			}
+			logging.UserSuccess("Failure domain strategy was set to %s", strategy)
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+var failureDomainGetCmd = &cobra.Command{
+	Use:   "get [flags]",
+	Short: "Print failure domain strategy",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			clusterSettings, err := db.GetClusterSettings(cluster.ClusterName(failureDomainParams.Name))
+			if err != nil {
+				return err
+			}
+			strategy := clusterSettings.FailureDomain
+			if strategy == "" {
+				strategy = common.FailureDomainHashedIp
+			}
+			fmt.Println(strategy)
+			if strategy == common.FailureDomainCustom {
+				fmt.Println(clusterSettings.FailureDomainCmd)
+			}
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{failureDomainSetCmd, failureDomainGetCmd} {
+		cmd.Flags().StringVarP(&failureDomainParams.Name, "name", "n", "", "weka cluster name")
+		_ = cmd.MarkFlagRequired("name")
+		failureDomainCmd.AddCommand(cmd)
+	}
+	failureDomainSetCmd.Flags().StringVarP(&failureDomainParams.Strategy, "strategy", "s", "", fmt.Sprintf("Failure domain strategy, one of: %v", common.FailureDomainStrategies))
+	failureDomainSetCmd.Flags().StringVarP(&failureDomainParams.Cmd, "cmd", "", "", "Bash command printing the failure domain name, for custom strategy")
+	_ = failureDomainSetCmd.MarkFlagRequired("strategy")
+}
@@ -8,6 +8,7 @@ import (
 	"syscall"
 	"wekactl/internal/aws/alb"
 	"wekactl/internal/aws/cluster"
+	"wekactl/internal/aws/common"
 	cluster2 "wekactl/internal/cluster"
 	"wekactl/internal/env"
 	"wekactl/internal/logging"
@@ -55,11 +56,20 @@ func init() {
 	importCmd.Flags().StringVarP(&importParams.Username, "username", "u", "", "cluster admin username")
 	importCmd.Flags().StringVarP(&importParams.Password, "password", "p", "", "cluster admin password")
 	importCmd.Flags().StringArrayVarP(&importParams.TagsList, "tags", "t", []string{}, "cloud resources tags, each tag should be passed in this pattern: '-t key=value'")
+	importCmd.Flags().StringVarP(&importParams.TagsFile, "tags-file", "", "", "YAML or JSON file of cloud resources tags, holding a key: value map, '-t' tags take precedence")
 	importCmd.Flags().BoolVarP(&importParams.PrivateSubnet, "private-subnet", "s", false, "cluster runs in private subnet, requires execute-api VPC endpoint to present on VPC")
 	importCmd.Flags().StringVarP(&importParams.AdditionalAlbSubnet, "additional-alb-subnet", "a", "", "Additional subnet to use for ALB")
 	importCmd.Flags().StringVarP(&importParams.DnsAlias, "dns-alias", "l", "", "ALB dns alias")
 	importCmd.Flags().StringVarP(&importParams.DnsZoneId, "dns-zone-id", "z", "", "ALB dns zone id")
-	importCmd.Flags().BoolVarP(&importParams.UseDynamoDBEndpoint, "use-dynamodb-endpoint", "d", false, "Use dynamoDB endpoint, this will allow avoiding the need to pass the weka cluster password from fetch lambda to scale down lambda and will not show it on the step function input/output")
+	importCmd.Flags().BoolVarP(&importParams.UseDynamoDBEndpoint, "use-dynamodb-endpoint", "d", false, "The VPC already routes DynamoDB traffic of the hostgroup subnets (e.g. using an existing DynamoDB endpoint), when not set wekactl creates a DynamoDB gateway endpoint so the scale lambda can read the weka credentials")
+	importCmd.Flags().StringVarP(&importParams.FailureDomain, "failure-domain", "", string(common.FailureDomainHashedIp), fmt.Sprintf("Failure domain strategy of joining backends, one of: %v", common.FailureDomainStrategies))
+	importCmd.Flags().StringVarP(&importParams.FailureDomainCmd, "failure-domain-cmd", "", "", "Bash command printing the failure domain name, for custom failure domain strategy")
+	importCmd.Flags().StringSliceVarP(&importParams.Subnets, "subnets", "", []string{}, "Additional subnets for the hostgroups auto scaling groups, in addition to the subnets of the imported instances")
+	importCmd.Flags().StringVarP(&importParams.IamRolesPath, "iam-roles-path", "", "", "Path prefix of the IAM roles wekactl creates, e.g. /platform/, the roles are created under PATH/wekactl/CLUSTER_NAME/")
+	importCmd.Flags().StringVarP(&importParams.IamRolesNamePrefix, "iam-roles-name-prefix", "", "", "Name prefix of the IAM roles wekactl creates")
+	importCmd.Flags().StringVarP(&importParams.IamPermissionsBoundary, "iam-permissions-boundary", "", "", "ARN of the managed policy set as permissions boundary of the IAM roles wekactl creates")
+	importCmd.Flags().StringArrayVarP(&importParams.IamRoles, "iam-role", "", []string{}, "Existing IAM role used instead of one wekactl creates, NAME=ARN, where NAME is one of join, fetch, scale, terminate, transient, metrics, sm (state machine) and cw (CloudWatch rule), the role is validated and never changed or deleted")
+	importCmd.Flags().BoolVarP(&importParams.SkipPreflight, "skip-preflight", "", false, "Import without checking permissions, quotas and vpc prerequisites first")
 	_ = importCmd.MarkFlagRequired("name")
 	_ = importCmd.MarkFlagRequired("username")
 }
@@ -0,0 +1,184 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/weka/go-cloud-lib/functions_def"
+	"os"
+	"sort"
+	"wekactl/internal/aws/db"
+	"wekactl/internal/cluster"
+	"wekactl/internal/env"
+	strings2 "wekactl/internal/lib/strings"
+	"wekactl/internal/logging"
+)
+
+var joinHooksParams struct {
+	Name             string
+	PreJoin          string
+	PostJoin         string
+	FindDrivesScript string
+	Functions        map[string]string
+}
+
+var overridableFunctions = []string{
+	string(functions_def.Report),
+	string(functions_def.JoinFinalization),
+	string(functions_def.Status),
+}
+
+var joinHooksCmd = &cobra.Command{
+	Use:   "join-hooks [command] [flags]",
+	Short: "Manage backend join script hooks",
+	Run: func(c *cobra.Command, _ []string) {
+		if err := c.Help(); err != nil {
+			log.Debug().Msgf("ignoring cobra error %q", err.Error())
+		}
+	},
+}
+
+func readHookFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+var joinHooksSetCmd = &cobra.Command{
+	Use:   "set [flags]",
+	Short: "Set join script hooks, hooks that are not specified are kept",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			tableName := db.GetTableName(cluster.ClusterName(joinHooksParams.Name))
+			hooks, err := db.GetJoinHooks(tableName)
+			if err != nil {
+				return err
+			}
+
+			for _, hook := range []struct {
+				path   string
+				target *string
+			}{
+				{joinHooksParams.PreJoin, &hooks.PreJoin},
+				{joinHooksParams.PostJoin, &hooks.PostJoin},
+				{joinHooksParams.FindDrivesScript, &hooks.FindDrivesScript},
+			} {
+				if hook.path == "" {
+					continue
+				}
+				*hook.target, err = readHookFile(hook.path)
+				if err != nil {
+					return err
+				}
+			}
+
+			for name, path := range joinHooksParams.Functions {
+				if !strings2.AnyOf(name, overridableFunctions...) {
+					return errors.New(fmt.Sprintf("function '%s' can't be overridden, supported functions: %v", name, overridableFunctions))
+				}
+				body, err := readHookFile(path)
+				if err != nil {
+					return err
+				}
+				if hooks.Functions == nil {
+					hooks.Functions = map[string]string{}
+				}
+				hooks.Functions[name] = body
+			}
+
+			if hooks.IsEmpty() {
+				return errors.New("no hooks were given")
+			}
+			err = db.SaveJoinHooks(tableName, hooks)
+			if err != nil {
+				logging.UserFailure("Setting join hooks failed!")
+				return err
+			}
+			logging.UserSuccess("Join hooks were set, they will be used by instances joining from now on")
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+var joinHooksGetCmd = &cobra.Command{
+	Use:   "get [flags]",
+	Short: "Print join script hooks",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			hooks, err := db.GetJoinHooks(db.GetTableName(cluster.ClusterName(joinHooksParams.Name)))
+			if err != nil {
+				return err
+			}
+			if hooks.IsEmpty() {
+				logging.UserInfo("No join hooks are set")
+				return nil
+			}
+			printHook := func(name, content string) {
+				if content != "" {
+					fmt.Printf("### %s\n%s\n", name, content)
+				}
+			}
+			printHook("pre-join", hooks.PreJoin)
+			printHook("post-join", hooks.PostJoin)
+			printHook("find-drives", hooks.FindDrivesScript)
+			var names []string
+			for name := range hooks.Functions {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				printHook("function "+name, hooks.Functions[name])
+			}
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+var joinHooksClearCmd = &cobra.Command{
+	Use:   "clear [flags]",
+	Short: "Remove all join script hooks",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			err := db.ClearJoinHooks(db.GetTableName(cluster.ClusterName(joinHooksParams.Name)))
+			if err != nil {
+				logging.UserFailure("Clearing join hooks failed!")
+				return err
+			}
+			logging.UserSuccess("Join hooks were cleared")
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{joinHooksSetCmd, joinHooksGetCmd, joinHooksClearCmd} {
+		cmd.Flags().StringVarP(&joinHooksParams.Name, "name", "n", "", "weka cluster name")
+		_ = cmd.MarkFlagRequired("name")
+		joinHooksCmd.AddCommand(cmd)
+	}
+	joinHooksSetCmd.Flags().StringVarP(&joinHooksParams.PreJoin, "pre-join", "", "", "File with bash fragment to run before weka installation")
+	joinHooksSetCmd.Flags().StringVarP(&joinHooksParams.PostJoin, "post-join", "", "", "File with bash fragment to run after the instance joined the cluster")
+	joinHooksSetCmd.Flags().StringVarP(&joinHooksParams.FindDrivesScript, "find-drives", "", "", "File with python script selecting drives to add (reads machine disks json from stdin, prints device paths)")
+	joinHooksSetCmd.Flags().StringToStringVarP(&joinHooksParams.Functions, "function", "f", map[string]string{}, fmt.Sprintf("Function body override in the form name=file, supported functions: %v", overridableFunctions))
+}
@@ -1,21 +1,55 @@
 package cluster
 
 import (
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"wekactl/internal/aws/cluster"
 	"wekactl/internal/env"
+	"wekactl/internal/logging"
 )
 
+var listStacks bool
+var regionsParams cluster.RegionsParams
+
 var listCmd = &cobra.Command{
 	Use:   "list",
-	Short: "",
+	Short: "List clusters managed by wekactl",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			if listStacks {
+				return cluster.RenderStacksTable(regionsParams)
+			}
+			return cluster.RenderClustersTable(regionsParams)
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show clusters hostgroups instances health and last scale execution",
 	Long:  "",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if env.Config.Provider == "aws" {
-			cluster.RenderStacksTable(Region)
+			return cluster.RenderClustersStatus(regionsParams)
 		} else {
-			fmt.Printf("Cloud provider '%s' is not supported with this action\n", env.Config.Provider)
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
 		}
 	},
 }
+
+func init() {
+	listCmd.Flags().BoolVarP(&listStacks, "stacks", "", false, "List weka CloudFormation stacks, the candidates for import")
+	for _, cmd := range []*cobra.Command{listCmd, statusCmd} {
+		cmd.Flags().BoolVarP(&regionsParams.AllRegions, "all-regions", "", false, "Run on every region wekactl is distributed to")
+		cmd.Flags().StringSliceVarP(&regionsParams.Regions, "regions", "", []string{}, "Regions to run on, instead of --region")
+		cmd.Flags().IntVarP(&regionsParams.Parallel, "parallel", "", 4, "Number of regions to query concurrently")
+	}
+}
@@ -0,0 +1,46 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"wekactl/internal/aws/cluster"
+	cluster2 "wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var preflightParams cluster2.ImportParams
+
+var preflightCmd = &cobra.Command{
+	Use:   "preflight [flags]",
+	Short: "Check the permissions, quotas and vpc prerequisites of a cluster import",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			checks := cluster.PreflightCluster(preflightParams)
+			cluster.RenderPreflightChecks(checks)
+			err := cluster.PreflightError(checks)
+			if err != nil {
+				logging.UserFailure(err.Error())
+				return err
+			}
+			logging.UserSuccess("Preflight checks passed!")
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	preflightCmd.Flags().StringVarP(&preflightParams.Name, "name", "n", "", "weka cluster name")
+	preflightCmd.Flags().StringArrayVarP(&preflightParams.InstanceIds, "instance-ids", "i", []string{}, "weka cluster instance ids")
+	preflightCmd.Flags().BoolVarP(&preflightParams.PrivateSubnet, "private-subnet", "s", false, "cluster runs in private subnet, requires execute-api VPC endpoint to present on VPC")
+	preflightCmd.Flags().StringVarP(&preflightParams.AdditionalAlbSubnet, "additional-alb-subnet", "a", "", "Additional subnet to use for ALB")
+	preflightCmd.Flags().StringVarP(&preflightParams.DnsAlias, "dns-alias", "l", "", "ALB dns alias")
+	preflightCmd.Flags().StringArrayVarP(&preflightParams.IamRoles, "iam-role", "", []string{}, "Existing IAM role used instead of one wekactl creates, NAME=ARN")
+	_ = preflightCmd.MarkFlagRequired("name")
+}
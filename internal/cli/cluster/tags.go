@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"wekactl/internal/aws/cluster"
+	cluster2 "wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var tagsParams struct {
+	Name     string
+	TagsList []string
+	TagsFile string
+	Keys     []string
+}
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags [command] [flags]",
+	Short: "Manage the tags of the cluster cloud resources",
+	Run: func(c *cobra.Command, _ []string) {
+		if err := c.Help(); err != nil {
+			log.Debug().Msgf("ignoring cobra error %q", err.Error())
+		}
+	},
+}
+
+var tagsListCmd = &cobra.Command{
+	Use:   "list [flags]",
+	Short: "List the cluster tags",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			err := cluster.RenderClusterTags(cluster2.ClusterName(tagsParams.Name))
+			if err != nil {
+				logging.UserFailure(err.Error())
+				return err
+			}
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+var tagsSetCmd = &cobra.Command{
+	Use:   "set [flags]",
+	Short: "Add or change cluster tags and re-tag the cluster resources",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			tags, err := cluster2.BuildTags(tagsParams.TagsList, tagsParams.TagsFile)
+			if err != nil {
+				logging.UserFailure(err.Error())
+				return err
+			}
+			if len(tags) == 0 {
+				err = errors.New("no tags were given")
+				logging.UserFailure(err.Error())
+				return err
+			}
+			err = cluster.UpdateClusterTags(cluster2.ClusterName(tagsParams.Name), tags, nil)
+			if err != nil {
+				logging.UserFailure("Setting tags failed!")
+				return err
+			}
+			logging.UserSuccess("Tags were set successfully!")
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+var tagsRemoveCmd = &cobra.Command{
+	Use:   "remove [flags]",
+	Short: "Remove cluster tags from the cluster resources",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			err := cluster.UpdateClusterTags(cluster2.ClusterName(tagsParams.Name), nil, tagsParams.Keys)
+			if err != nil {
+				logging.UserFailure("Removing tags failed!")
+				return err
+			}
+			logging.UserSuccess("Tags were removed successfully!")
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{tagsListCmd, tagsSetCmd, tagsRemoveCmd} {
+		cmd.Flags().StringVarP(&tagsParams.Name, "name", "n", "", "weka cluster name")
+		_ = cmd.MarkFlagRequired("name")
+		tagsCmd.AddCommand(cmd)
+	}
+	tagsSetCmd.Flags().StringArrayVarP(&tagsParams.TagsList, "tags", "t", []string{}, "cloud resources tags, each tag should be passed in this pattern: '-t key=value'")
+	tagsSetCmd.Flags().StringVarP(&tagsParams.TagsFile, "tags-file", "", "", "YAML or JSON file of cloud resources tags, holding a key: value map, '-t' tags take precedence")
+	tagsRemoveCmd.Flags().StringArrayVarP(&tagsParams.Keys, "key", "k", []string{}, "key of the tag to remove, can be repeated")
+	_ = tagsRemoveCmd.MarkFlagRequired("key")
+}
@@ -10,13 +10,23 @@ import (
 	"wekactl/internal/logging"
 )
 
+var updateSubnets []string
+var updateAllowDowngrade bool
+var updateToVersion string
+
 var updateCmd = &cobra.Command{
 	Use:   "update [flags]",
 	Short: "",
 	Long:  "",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if env.Config.Provider == "aws" {
-			err := cluster.UpdateCluster(cluster2.ClusterName(StackName), DryRun)
+			err := cluster.UpdateCluster(cluster.UpdateParams{
+				Name:           cluster2.ClusterName(StackName),
+				DryRun:         DryRun,
+				Subnets:        updateSubnets,
+				AllowDowngrade: updateAllowDowngrade,
+				ToVersion:      updateToVersion,
+			})
 			if err != nil {
 				logging.UserFailure("Update failed!")
 				return err
@@ -36,6 +46,9 @@ var updateCmd = &cobra.Command{
 func init() {
 	updateCmd.Flags().StringVarP(&StackName, "name", "n", "", "weka cluster name")
 	updateCmd.Flags().BoolVarP(&DryRun, "dry-run", "d", false, "dry run")
+	updateCmd.Flags().StringSliceVarP(&updateSubnets, "subnets", "", []string{}, "Subnets to add to the hostgroups auto scaling groups")
+	updateCmd.Flags().BoolVarP(&updateAllowDowngrade, "allow-downgrade", "", false, "Allow updating a cluster deployed by a newer wekactl or lambdas package")
+	updateCmd.Flags().StringVarP(&updateToVersion, "to-version", "", "", "Lambdas package ID to deploy (e.g. release/1.0.5), defaults to the one wekactl was built with")
 
 	_ = updateCmd.MarkFlagRequired("name")
 }
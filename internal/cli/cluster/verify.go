@@ -0,0 +1,51 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"wekactl/internal/aws/cluster"
+	cluster2 "wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var verifyFix bool
+var verifyAllowDowngrade bool
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [flags]",
+	Short: "Report cluster resources whose configuration drifted from what wekactl generates",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			drifts, err := cluster.VerifyCluster(cluster2.ClusterName(StackName), verifyFix, verifyAllowDowngrade)
+			if err != nil {
+				logging.UserFailure("Verify failed!")
+				return err
+			}
+			if len(drifts) == 0 {
+				return nil
+			}
+			if verifyFix {
+				logging.UserSuccess("%d drifts were reconciled", len(drifts))
+				return nil
+			}
+			err = errors.New(fmt.Sprintf("%d drifts found, run with --fix to reconcile them", len(drifts)))
+			logging.UserFailure(err.Error())
+			return err
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+	},
+}
+
+func init() {
+	verifyCmd.Flags().StringVarP(&StackName, "name", "n", "", "weka cluster name")
+	verifyCmd.Flags().BoolVarP(&verifyFix, "fix", "", false, "reconcile the drifted resources")
+	verifyCmd.Flags().BoolVarP(&verifyAllowDowngrade, "allow-downgrade", "", false, "Allow reconciling a cluster deployed by a newer wekactl or lambdas package")
+
+	_ = verifyCmd.MarkFlagRequired("name")
+}
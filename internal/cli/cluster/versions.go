@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"wekactl/internal/aws/cluster"
+	cluster2 "wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var versionsCmd = &cobra.Command{
+	Use:   "versions [flags]",
+	Short: "Show the cluster resources deployed versions against the ones this wekactl deploys",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			err := cluster.RenderClusterVersions(cluster2.ClusterName(StackName))
+			if err != nil {
+				logging.UserFailure(err.Error())
+				return err
+			}
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	versionsCmd.Flags().StringVarP(&StackName, "name", "n", "", "weka cluster name")
+
+	_ = versionsCmd.MarkFlagRequired("name")
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var Config = &cobra.Command{
+	Use:   "config [command] [flags]",
+	Short: "Manage wekactl configuration profiles",
+	Run: func(c *cobra.Command, _ []string) {
+		if err := c.Help(); err != nil {
+			log.Debug().Msgf("ignoring cobra error %q", err.Error())
+		}
+	},
+	// profiles are managed here, selecting a profile that doesn't exist yet is fine
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return setLogLevel()
+	},
+	SilenceUsage: true,
+}
+
+var viewCmd = &cobra.Command{
+	Use:   "view",
+	Short: "Print the configuration file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := env.ConfigFilePath()
+		if err != nil {
+			return err
+		}
+		configFile, err := env.LoadConfigFile()
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(configFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# %s\n%s", path, data)
+		return nil
+	},
+}
+
+var setCmd = &cobra.Command{
+	Use:   "set KEY VALUE",
+	Short: fmt.Sprintf("Set a profile setting, one of: %v", env.ProfileKeys),
+	Long:  "Set a setting of the profile selected by --profile, or of the current profile",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile, err := env.LoadConfigFile()
+		if err != nil {
+			return err
+		}
+		name := env.Config.Profile
+		if name == "" {
+			name = configFile.CurrentProfile
+		}
+		if name == "" {
+			name = env.DefaultProfile
+		}
+		if configFile.Profiles == nil {
+			configFile.Profiles = map[string]env.Profile{}
+		}
+		profile := configFile.Profiles[name]
+		err = profile.Set(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		configFile.Profiles[name] = profile
+		err = env.SaveConfigFile(configFile)
+		if err != nil {
+			return err
+		}
+		logging.UserSuccess("Profile %s %s was set to %s", name, args[0], args[1])
+		return nil
+	},
+}
+
+var useProfileCmd = &cobra.Command{
+	Use:   "use-profile NAME",
+	Short: "Set the current profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile, err := env.LoadConfigFile()
+		if err != nil {
+			return err
+		}
+		if _, ok := configFile.Profiles[args[0]]; !ok {
+			return errors.New(fmt.Sprintf("profile %s was not found, existing profiles: %v", args[0], configFile.ProfileNames()))
+		}
+		configFile.CurrentProfile = args[0]
+		err = env.SaveConfigFile(configFile)
+		if err != nil {
+			return err
+		}
+		logging.UserSuccess("Current profile was set to %s", args[0])
+		return nil
+	},
+}
+
+func init() {
+	Config.AddCommand(viewCmd)
+	Config.AddCommand(setCmd)
+	Config.AddCommand(useProfileCmd)
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"os"
+	"wekactl/internal/env"
+	strings2 "wekactl/internal/lib/strings"
+)
+
+// settings are resolved by precedence: flag, WEKACTL_* environment variable, profile, flag default
+var settings = []struct {
+	key    string
+	flag   string
+	envVar string
+}{
+	{"region", "region", "WEKACTL_REGION"},
+	{"aws-profile", "aws-profile", "WEKACTL_AWS_PROFILE"},
+	{"output", "output", "WEKACTL_OUTPUT"},
+	{"log-level", "log-level", "WEKACTL_LOG_LEVEL"},
+	{"cluster", "name", "WEKACTL_CLUSTER"},
+}
+
+func usesDefaultCluster(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if _, ok := c.Annotations[env.DefaultClusterAnnotation]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selectedProfile returns the profile selected by --profile, WEKACTL_PROFILE or the config current profile
+func selectedProfile(cmd *cobra.Command, configFile env.ConfigFile) (name string, profile env.Profile, err error) {
+	name = env.Config.Profile
+	if flag := cmd.Flags().Lookup("profile"); flag == nil || !flag.Changed {
+		name = os.Getenv("WEKACTL_PROFILE")
+	}
+	if name == "" {
+		name = configFile.CurrentProfile
+	}
+	if name == "" {
+		return env.DefaultProfile, configFile.Profiles[env.DefaultProfile], nil
+	}
+	profile, ok := configFile.Profiles[name]
+	if !ok {
+		err = errors.New(fmt.Sprintf("profile %s was not found, existing profiles: %v", name, configFile.ProfileNames()))
+	}
+	return
+}
+
+// Resolve fills the flags that were not set from the environment and the selected profile
+func Resolve(cmd *cobra.Command) error {
+	configFile, err := env.LoadConfigFile()
+	if err != nil {
+		return err
+	}
+	name, profile, err := selectedProfile(cmd, configFile)
+	if err != nil {
+		return err
+	}
+	env.Config.Profile = name
+
+	for _, setting := range settings {
+		if setting.key == "cluster" && !usesDefaultCluster(cmd) {
+			continue
+		}
+		flag := cmd.Flags().Lookup(setting.flag)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		value := os.Getenv(setting.envVar)
+		if value == "" {
+			value, _ = profile.Get(setting.key)
+		}
+		if value == "" {
+			continue
+		}
+		err = cmd.Flags().Set(setting.flag, value)
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid %s %q: %v", setting.key, value, err))
+		}
+	}
+
+	if !strings2.AnyOf(env.Config.Output, env.Outputs...) {
+		return errors.New(fmt.Sprintf("unsupported output %s, supported outputs: %v", env.Config.Output, env.Outputs))
+	}
+	return setLogLevel()
+}
+
+// setLogLevel keeps supporting LOG_LEVEL, which predates WEKACTL_LOG_LEVEL
+func setLogLevel() error {
+	logLevel := env.Config.LogLevel
+	if logLevel == "" {
+		logLevel = os.Getenv("LOG_LEVEL")
+	}
+	if logLevel == "" {
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+		return nil
+	}
+	level, err := zerolog.ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	zerolog.SetGlobalLevel(level)
+	return nil
+}
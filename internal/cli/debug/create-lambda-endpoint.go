@@ -27,7 +27,7 @@ var createLambdaEndPointCmd = &cobra.Command{
 				ClusterName: cluster.ClusterName(StackName),
 			}
 
-			functionConfiguration, err := createLambda(hostGroup, lambdas.LambdaJoin, iam.GetJoinAndFetchLambdaPolicy(), lambda.VpcConfig{})
+			functionConfiguration, err := createLambda(hostGroup, lambdas.LambdaJoin, iam.GetJoinAndFetchLambdaPolicy, lambda.VpcConfig{})
 			if err != nil {
 				return err
 			}
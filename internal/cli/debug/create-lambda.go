@@ -23,14 +23,29 @@ func generateLambdaName(lambdaType lambdas.LambdaType) string {
 	return strings2.ElfHashSuffixed(n, 64)
 }
 
-func createLambda(hostGroup common.HostGroupInfo, lambdaType lambdas.LambdaType, policy iam.PolicyDocument, vpcConfig lambda.VpcConfig) (functionConfiguration *lambda.FunctionConfiguration, err error) {
+type lambdaPolicyFunc func(scope iam.Scope, lambdaName string) iam.PolicyDocument
+
+func debugPolicyScope(hostGroup common.HostGroupInfo) iam.Scope {
+	return iam.Scope{
+		Region:      env.Config.Region,
+		ClusterName: hostGroup.ClusterName,
+		TableName:   common.GenerateResourceName(hostGroup.ClusterName, ""),
+		ASGName:     common.GenerateResourceName(hostGroup.ClusterName, hostGroup.Name),
+	}
+}
+
+func createLambda(hostGroup common.HostGroupInfo, lambdaType lambdas.LambdaType, policyFunc lambdaPolicyFunc, vpcConfig lambda.VpcConfig) (functionConfiguration *lambda.FunctionConfiguration, err error) {
 	assumeRolePolicy := iam.GetLambdaAssumeRolePolicy()
 	roleName := fmt.Sprintf("wekactl-%s-%s-%s", hostGroup.Name, string(lambdaType), uuid.New().String())
 	lambdaName := generateLambdaName(lambdaType)
 	policyName := lambdaName
+	var policy iam.PolicyDocument
+	if policyFunc != nil {
+		policy = policyFunc(debugPolicyScope(hostGroup), lambdaName)
+	}
 	iamTargetVersion := policy.VersionHash()
 	iamTags := cluster2.GetHostGroupResourceTags(hostGroup, iamTargetVersion).AsIam()
-	roleArn, err := iam.CreateIamRole(hostGroup.ClusterName, iamTags, roleName, policyName, assumeRolePolicy, policy)
+	roleArn, err := iam.CreateIamRole(iam.RolesPath(hostGroup.ClusterName, ""), "", iamTags, roleName, policyName, assumeRolePolicy, policy)
 	if err != nil {
 		return
 	}
@@ -38,7 +53,7 @@ func createLambda(hostGroup common.HostGroupInfo, lambdaType lambdas.LambdaType,
 	tableName := common.GenerateResourceName(hostGroup.ClusterName, "")
 	lambdaTargetVersion := dist.LambdasID + iamTargetVersion
 	lambdaTags := cluster2.GetHostGroupResourceTags(hostGroup, lambdaTargetVersion).AsStringRefs()
-	functionConfiguration, err = lambdas.CreateLambda(lambdaTags, lambdaType, lambdaName, *roleArn, asgName, tableName, hostGroup, vpcConfig, false)
+	functionConfiguration, err = lambdas.CreateLambda(lambdaTags, lambdaType, lambdaName, *roleArn, asgName, tableName, hostGroup, vpcConfig, "")
 	if err != nil {
 		return
 	}
@@ -63,21 +78,21 @@ var createLambdaCmd = &cobra.Command{
 			}
 			var lambdaVpcConfig lambda.VpcConfig
 			var lambdaType lambdas.LambdaType
-			var policy iam.PolicyDocument
+			var policy lambdaPolicyFunc
 			switch Lambda {
 			case "join":
-				policy = iam.GetJoinAndFetchLambdaPolicy()
+				policy = iam.GetJoinAndFetchLambdaPolicy
 				lambdaType = lambdas.LambdaJoin
 			case "fetch":
-				policy = iam.GetJoinAndFetchLambdaPolicy()
+				policy = iam.GetJoinAndFetchLambdaPolicy
 				lambdaType = lambdas.LambdaFetchInfo
 			case "scale":
-				policy = iam.GetScaleLambdaPolicy()
+				policy = iam.GetScaleLambdaPolicy
 				lambdaType = lambdas.LambdaScale
 				instance := stackInstances.Backends[0]
 				lambdaVpcConfig = lambdas.GetLambdaVpcConfig(*instance.SubnetId, cluster2.GetInstanceSecurityGroupsId(instance))
 			case "terminate":
-				policy = iam.GetTerminateLambdaPolicy()
+				policy = iam.GetTerminateLambdaPolicy
 				lambdaType = lambdas.LambdaTerminate
 			default:
 				err = errors.New("invalid lambda type")
@@ -34,27 +34,27 @@ var createStateMachineCmd = &cobra.Command{
 			instance := stackInstances.Backends[0]
 			lambdaVpcConfig := lambdas.GetLambdaVpcConfig(*instance.SubnetId, cluster2.GetInstanceSecurityGroupsId(instance))
 
-			fetchLambda, err := createLambda(hostGroup, lambdas.LambdaFetchInfo, iam.GetJoinAndFetchLambdaPolicy(), lambda.VpcConfig{})
+			fetchLambda, err := createLambda(hostGroup, lambdas.LambdaFetchInfo, iam.GetJoinAndFetchLambdaPolicy, lambda.VpcConfig{})
 			if err != nil {
 				return err
 			}
 
-			scaleLambda, err := createLambda(hostGroup, lambdas.LambdaScale, iam.GetScaleLambdaPolicy(), lambdaVpcConfig)
+			scaleLambda, err := createLambda(hostGroup, lambdas.LambdaScale, iam.GetScaleLambdaPolicy, lambdaVpcConfig)
 			if err != nil {
 				return err
 			}
 
-			terminateLambda, err := createLambda(hostGroup, lambdas.LambdaTerminate, iam.GetTerminateLambdaPolicy(), lambda.VpcConfig{})
+			terminateLambda, err := createLambda(hostGroup, lambdas.LambdaTerminate, iam.GetTerminateLambdaPolicy, lambda.VpcConfig{})
 			if err != nil {
 				return err
 			}
 
-			transientLambda, err := createLambda(hostGroup, lambdas.LambdaTransient, iam.PolicyDocument{}, lambda.VpcConfig{})
+			transientLambda, err := createLambda(hostGroup, lambdas.LambdaTransient, nil, lambda.VpcConfig{})
 			if err != nil {
 				return err
 			}
 
-			lambdas := scalemachine.StateMachineLambdasArn{
+			lambdasArn := scalemachine.StateMachineLambdasArn{
 				Fetch:     *fetchLambda.FunctionArn,
 				Scale:     *scaleLambda.FunctionArn,
 				Terminate: *terminateLambda.FunctionArn,
@@ -64,18 +64,20 @@ var createStateMachineCmd = &cobra.Command{
 			roleName := fmt.Sprintf("wekactl-%s-sm-%s", hostGroup.Name, uuid.New().String())
 			policyName := fmt.Sprintf("wekactl-%s-sm-%s", string(hostGroup.ClusterName), string(hostGroup.Name))
 			assumeRolePolicy := iam.GetStateMachineAssumeRolePolicy()
-			policy := iam.GetStateMachineRolePolicy()
+			policy := iam.GetStateMachineRolePolicy(debugPolicyScope(hostGroup), []string{
+				generateLambdaName(lambdas.LambdaFetchInfo), generateLambdaName(lambdas.LambdaScale),
+				generateLambdaName(lambdas.LambdaTerminate), generateLambdaName(lambdas.LambdaTransient)})
 
 			iamTargetVersion := policy.VersionHash()
 			iamTags := cluster2.GetHostGroupResourceTags(hostGroup, iamTargetVersion).AsIam()
-			roleArn, err := iam.CreateIamRole(hostGroup.ClusterName, iamTags, roleName, policyName, assumeRolePolicy, policy)
+			roleArn, err := iam.CreateIamRole(iam.RolesPath(hostGroup.ClusterName, ""), "", iamTags, roleName, policyName, assumeRolePolicy, policy)
 			if err != nil {
 				return err
 			}
 
 			stateMachineName := common.GenerateResourceName(hostGroup.ClusterName, hostGroup.Name)
 			stateMachineTags := cluster2.GetHostGroupResourceTags(hostGroup, "v1").AsSfn()
-			_, err = scalemachine.CreateStateMachine(stateMachineTags, lambdas, *roleArn, stateMachineName)
+			_, err = scalemachine.CreateStateMachine(stateMachineTags, lambdasArn, *roleArn, stateMachineName)
 			if err != nil {
 				return err
 			}
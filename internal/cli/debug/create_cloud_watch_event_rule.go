@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
+	"strings"
 	"wekactl/internal/aws/cloudwatch"
 	cluster2 "wekactl/internal/aws/cluster"
 	"wekactl/internal/aws/common"
@@ -29,11 +30,12 @@ var createCloudWatchEventCmd = &cobra.Command{
 			roleName := fmt.Sprintf("wekactl-%s-cw-%s", hostGroup.Name, uuid.New().String())
 			policyName := fmt.Sprintf("wekactl-%s-cw-%s", string(hostGroup.ClusterName), string(hostGroup.Name))
 			assumeRolePolicy := iam.GetCloudWatchEventAssumeRolePolicy()
-			policy := iam.GetCloudWatchEventRolePolicy()
+			stateMachineName := StateMachineArn[strings.LastIndex(StateMachineArn, ":")+1:]
+			policy := iam.GetCloudWatchEventRolePolicy(debugPolicyScope(hostGroup), stateMachineName)
 
 			iamTargetVersion := policy.VersionHash()
 			iamTags := cluster2.GetHostGroupResourceTags(hostGroup, iamTargetVersion).AsIam()
-			roleArn, err := iam.CreateIamRole(hostGroup.ClusterName, iamTags, roleName, policyName, assumeRolePolicy, policy)
+			roleArn, err := iam.CreateIamRole(iam.RolesPath(hostGroup.ClusterName, ""), "", iamTags, roleName, policyName, assumeRolePolicy, policy)
 			if err != nil {
 				return err
 			}
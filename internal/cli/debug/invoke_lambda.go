@@ -0,0 +1,94 @@
+package debug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/weka/go-cloud-lib/protocol"
+	"github.com/weka/go-cloud-lib/scale_down"
+	"net/http"
+	"os"
+	"strings"
+	"wekactl/internal/lib/jrpc"
+	"wekactl/internal/lib/weka"
+	"wekactl/internal/logging"
+)
+
+var invokeLambdaArgs struct {
+	Lambda string
+	Replay string
+	Input  string
+}
+
+var invokeLambdaCmd = &cobra.Command{
+	Use:   "invoke-lambda",
+	Short: "Run lambda logic locally against a recorded jrpc session",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if invokeLambdaArgs.Lambda != "scale" {
+			return errors.New(fmt.Sprintf("lambda '%s' is not supported, only scale can be replayed", invokeLambdaArgs.Lambda))
+		}
+
+		file, err := os.Open(invokeLambdaArgs.Replay)
+		if err != nil {
+			return err
+		}
+		recording, err := jrpc.ReadRecording(file)
+		_ = file.Close()
+		if err != nil {
+			return err
+		}
+
+		input := []byte(recording.Input)
+		if invokeLambdaArgs.Input != "" {
+			input, err = os.ReadFile(invokeLambdaArgs.Input)
+			if err != nil {
+				return err
+			}
+		}
+		if len(input) == 0 {
+			return errors.New("recording has no input, please provide one with --input")
+		}
+		info := protocol.HostGroupInfoResponse{}
+		err = json.Unmarshal(input, &info)
+		if err != nil {
+			return err
+		}
+		if len(info.BackendIps) == 0 {
+			return errors.New("input has no backend ips")
+		}
+
+		replay := jrpc.NewReplayHandler(recording)
+		ips, stop, err := jrpc.ServeLoopback(info.BackendIps, weka.ManagementJrpcPort, func(ip string) http.Handler {
+			return replay
+		})
+		if err != nil {
+			return err
+		}
+		defer stop()
+		info.BackendIps = ips
+
+		response, err := scale_down.ScaleDown(cmd.Context(), info)
+		if err != nil {
+			return err
+		}
+		output, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+		if len(replay.Unmatched) > 0 {
+			logging.UserWarning("Calls not found in recording: %s", strings.Join(replay.Unmatched, ", "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	invokeLambdaCmd.Flags().StringVarP(&invokeLambdaArgs.Lambda, "lambda", "l", "scale", "Lambda type")
+	invokeLambdaCmd.Flags().StringVarP(&invokeLambdaArgs.Replay, "replay", "", "", "jrpc recording file to replay")
+	invokeLambdaCmd.Flags().StringVarP(&invokeLambdaArgs.Input, "input", "i", "", "Lambda input file, defaults to the input saved in the recording")
+	_ = invokeLambdaCmd.MarkFlagRequired("replay")
+	Debug.AddCommand(invokeLambdaCmd)
+}
@@ -0,0 +1,112 @@
+package hostgroup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"strings"
+	"time"
+	"wekactl/internal/aws/autoscaling"
+	"wekactl/internal/aws/cluster"
+	"wekactl/internal/aws/common"
+	cluster2 "wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var autoscaleParams struct {
+	Name          string
+	HostGroupName string
+	PolicyType    string
+	Metric        string
+	Target        float64
+	Min           int64
+	Max           int64
+	Cooldown      time.Duration
+	ScaleInTarget float64
+}
+
+var autoscaleCmd = &cobra.Command{
+	Use:   "autoscale [command] [flags]",
+	Short: "Hostgroup scaling policies operations",
+	Run: func(c *cobra.Command, _ []string) {
+		if err := c.Help(); err != nil {
+			log.Debug().Msgf("ignoring cobra error %q", err.Error())
+		}
+	},
+}
+
+var autoscaleSetCmd = &cobra.Command{
+	Use:   "set [flags]",
+	Short: "Set hostgroup scaling policy",
+	Long: "Set a target tracking or step scaling policy on the hostgroup auto scaling group. Target tracking keeps the " +
+		"metric at the target, step scaling adds an instance above the target and removes one below the scale in target " +
+		"(half of the target by default). " +
+		"Weka metrics deploy the Backends metrics lambda, which is removed once no scaling policy uses them. " +
+		"Removed backends are deactivated safely by the hostgroup state machine.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			err := cluster.SetHostGroupAutoscaling(cluster.AutoscaleParams{
+				Name:          cluster2.ClusterName(autoscaleParams.Name),
+				HostGroupName: common.HostGroupName(autoscaleParams.HostGroupName),
+				PolicyType:    autoscaleParams.PolicyType,
+				Metric:        autoscaleParams.Metric,
+				Target:        autoscaleParams.Target,
+				Min:           autoscaleParams.Min,
+				Max:           autoscaleParams.Max,
+				Cooldown:      autoscaleParams.Cooldown,
+				ScaleInTarget: autoscaleParams.ScaleInTarget,
+			})
+			if err != nil {
+				return err
+			}
+			logging.UserSuccess("Scaling policy was set successfully!")
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+var autoscaleUnsetCmd = &cobra.Command{
+	Use:   "unset [flags]",
+	Short: "Remove hostgroup scaling policy",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			err := cluster.UnsetHostGroupAutoscaling(cluster2.ClusterName(autoscaleParams.Name), common.HostGroupName(autoscaleParams.HostGroupName))
+			if err != nil {
+				return err
+			}
+			logging.UserSuccess("Scaling policy was removed successfully!")
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{autoscaleSetCmd, autoscaleUnsetCmd} {
+		cmd.Flags().StringVarP(&autoscaleParams.Name, "name", "n", "", "weka cluster name")
+		cmd.Flags().StringVarP(&autoscaleParams.HostGroupName, "hostgroup", "g", "Backends", "hostgroup name")
+		_ = cmd.MarkFlagRequired("name")
+		autoscaleCmd.AddCommand(cmd)
+	}
+	autoscaleSetCmd.Flags().StringVarP(&autoscaleParams.PolicyType, "policy-type", "", cluster.PolicyTypeTargetTracking, fmt.Sprintf("policy type, one of: %s, %s", cluster.PolicyTypeTargetTracking, cluster.PolicyTypeStep))
+	autoscaleSetCmd.Flags().StringVarP(&autoscaleParams.Metric, "metric", "", "cpu", fmt.Sprintf("metric, one of: %s", strings.Join(autoscaling.ScalingMetricNames(), ", ")))
+	autoscaleSetCmd.Flags().Float64VarP(&autoscaleParams.Target, "target", "", 0, "metric target value (percent for cpu, bytes for network), step policy adds an instance above it")
+	autoscaleSetCmd.Flags().Float64VarP(&autoscaleParams.ScaleInTarget, "scale-in-target", "", 0, "step policy removes an instance below this metric value, defaults to half of the target")
+	autoscaleSetCmd.Flags().Int64VarP(&autoscaleParams.Min, "min", "", 0, "hostgroup min size")
+	autoscaleSetCmd.Flags().Int64VarP(&autoscaleParams.Max, "max", "", 0, "hostgroup max size")
+	autoscaleSetCmd.Flags().DurationVarP(&autoscaleParams.Cooldown, "cooldown", "", 5*time.Minute, "time until a new instance contributes to the metric")
+	_ = autoscaleSetCmd.MarkFlagRequired("target")
+	_ = autoscaleSetCmd.MarkFlagRequired("min")
+	_ = autoscaleSetCmd.MarkFlagRequired("max")
+	HostGroup.AddCommand(autoscaleCmd)
+}
@@ -0,0 +1,69 @@
+package hostgroup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"time"
+	"wekactl/internal/aws/cluster"
+	"wekactl/internal/aws/common"
+	cluster2 "wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var historyParams struct {
+	Name          string
+	HostGroupName string
+	Since         time.Duration
+	FailedOnly    bool
+	Limit         int
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history [flags]",
+	Short: "List hostgroup scale executions",
+	Long:  "",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			return cluster.RenderHostGroupHistory(cluster.HistoryParams{
+				Name:          cluster2.ClusterName(historyParams.Name),
+				HostGroupName: common.HostGroupName(historyParams.HostGroupName),
+				Since:         historyParams.Since,
+				FailedOnly:    historyParams.FailedOnly,
+				Limit:         historyParams.Limit,
+			})
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show EXECUTION [flags]",
+	Short: "Print scale execution states input and output, execution is given by name or arn",
+	Long:  "",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			return cluster.PrintExecution(cluster2.ClusterName(historyParams.Name), common.HostGroupName(historyParams.HostGroupName), args[0])
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+	},
+}
+
+func init() {
+	historyCmd.PersistentFlags().StringVarP(&historyParams.Name, "name", "n", "", "weka cluster name")
+	historyCmd.PersistentFlags().StringVarP(&historyParams.HostGroupName, "hostgroup", "g", "Backends", "hostgroup name")
+	historyCmd.Flags().DurationVarP(&historyParams.Since, "since", "", 6*time.Hour, "list executions started within this duration")
+	historyCmd.Flags().BoolVarP(&historyParams.FailedOnly, "failed-only", "", false, "list only failed executions")
+	historyCmd.Flags().IntVarP(&historyParams.Limit, "limit", "", 20, "maximal number of executions listed")
+	_ = historyCmd.MarkPersistentFlagRequired("name")
+	historyCmd.AddCommand(historyShowCmd)
+	HostGroup.AddCommand(historyCmd)
+}
@@ -3,6 +3,7 @@ package hostgroup
 import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"wekactl/internal/env"
 )
 
 var HostGroup = &cobra.Command{
@@ -14,4 +15,5 @@ var HostGroup = &cobra.Command{
 		}
 	},
 	SilenceUsage: true,
+	Annotations:  map[string]string{env.DefaultClusterAnnotation: ""},
 }
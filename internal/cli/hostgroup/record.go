@@ -0,0 +1,60 @@
+package hostgroup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"wekactl/internal/aws/cluster"
+	"wekactl/internal/aws/db"
+	cluster2 "wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var recordParams struct {
+	Name   string
+	Target string
+}
+
+var recordCmd = &cobra.Command{
+	Use:   "record [flags]",
+	Short: "Record the weka management traffic of the hostgroups scale executions",
+	Long: "Every scale execution writes its weka management requests and responses, with credentials redacted, to " +
+		"<target>/<cluster>/<time>.json. The recordings can be replayed with 'debug invoke-lambda --replay'. " +
+		"Without --target the current target is printed.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			name := cluster2.ClusterName(recordParams.Name)
+			if recordParams.Target == "" {
+				clusterSettings, err := db.GetClusterSettings(name)
+				if err != nil {
+					return err
+				}
+				target := clusterSettings.RecordTarget
+				if target == "" {
+					target = cluster.RecordTargetOff
+				}
+				fmt.Println(target)
+				return nil
+			}
+			err := cluster.SetRecordTarget(name, recordParams.Target)
+			if err != nil {
+				logging.UserFailure("Setting record target failed!")
+				return err
+			}
+			logging.UserSuccess("Record target was set to %s", recordParams.Target)
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	recordCmd.Flags().StringVarP(&recordParams.Name, "name", "n", "", "weka cluster name")
+	recordCmd.Flags().StringVarP(&recordParams.Target, "target", "", "", fmt.Sprintf("s3://bucket/prefix to record to, or %s", cluster.RecordTargetOff))
+	_ = recordCmd.MarkFlagRequired("name")
+	HostGroup.AddCommand(recordCmd)
+}
@@ -0,0 +1,63 @@
+package hostgroup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"time"
+	"wekactl/internal/aws/cluster"
+	"wekactl/internal/aws/common"
+	cluster2 "wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var rollParams struct {
+	Name          string
+	HostGroupName string
+	InstanceType  string
+	ImageID       string
+	BatchSize     int64
+	Timeout       time.Duration
+}
+
+var rollCmd = &cobra.Command{
+	Use:   "roll [flags]",
+	Short: "Replace hostgroup instances with a new instance type or ami",
+	Long: "Replace hostgroup instances batch by batch: launches new instances, waits for them to join weka and for data " +
+		"to be fully protected, then lets scale down remove the old ones. Rerun the command to resume an interrupted roll. " +
+		"Requires network access to the backends management port.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			err := cluster.RollHostGroup(cmd.Context(), cluster.RollParams{
+				Name:          cluster2.ClusterName(rollParams.Name),
+				HostGroupName: common.HostGroupName(rollParams.HostGroupName),
+				InstanceType:  rollParams.InstanceType,
+				ImageID:       rollParams.ImageID,
+				BatchSize:     rollParams.BatchSize,
+				Timeout:       rollParams.Timeout,
+			})
+			if err != nil {
+				logging.UserFailure("Roll failed!")
+				return err
+			}
+			logging.UserSuccess("Roll finished successfully!")
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	rollCmd.Flags().StringVarP(&rollParams.Name, "name", "n", "", "weka cluster name")
+	rollCmd.Flags().StringVarP(&rollParams.HostGroupName, "hostgroup", "g", "Backends", "hostgroup name")
+	rollCmd.Flags().StringVarP(&rollParams.InstanceType, "instance-type", "", "", "new instance type")
+	rollCmd.Flags().StringVarP(&rollParams.ImageID, "ami", "", "", "new ami id")
+	rollCmd.Flags().Int64VarP(&rollParams.BatchSize, "batch", "b", 1, "number of instances replaced at once")
+	rollCmd.Flags().DurationVarP(&rollParams.Timeout, "timeout", "", time.Hour, "max time to wait for each batch step")
+	_ = rollCmd.MarkFlagRequired("name")
+	HostGroup.AddCommand(rollCmd)
+}
@@ -0,0 +1,64 @@
+package hostgroup
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"time"
+	"wekactl/internal/aws/cluster"
+	"wekactl/internal/aws/common"
+	cluster2 "wekactl/internal/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var scaleParams struct {
+	Name          string
+	HostGroupName string
+	Desired       int64
+	Wait          bool
+	Timeout       time.Duration
+}
+
+var scaleCmd = &cobra.Command{
+	Use:   "scale [flags]",
+	Short: "Change hostgroup desired capacity",
+	Long: "Change hostgroup desired capacity, with --wait follows the scale executions until weka has the desired number " +
+		"of active hosts and data is fully protected. Waiting requires network access to the backends management port.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if env.Config.Provider == "aws" {
+			err := cluster.ScaleHostGroup(cmd.Context(), cluster.ScaleParams{
+				Name:          cluster2.ClusterName(scaleParams.Name),
+				HostGroupName: common.HostGroupName(scaleParams.HostGroupName),
+				Desired:       scaleParams.Desired,
+				Wait:          scaleParams.Wait,
+				Timeout:       scaleParams.Timeout,
+			})
+			if err != nil {
+				logging.UserFailure("Scale failed!")
+				return err
+			}
+			if scaleParams.Wait {
+				logging.UserSuccess("Hostgroup converged to %d instances", scaleParams.Desired)
+			} else {
+				logging.UserSuccess("Hostgroup desired capacity is %d", scaleParams.Desired)
+			}
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	scaleCmd.Flags().StringVarP(&scaleParams.Name, "name", "n", "", "weka cluster name")
+	scaleCmd.Flags().StringVarP(&scaleParams.HostGroupName, "hostgroup", "g", "Backends", "hostgroup name")
+	scaleCmd.Flags().Int64VarP(&scaleParams.Desired, "desired", "", 0, "desired capacity")
+	scaleCmd.Flags().BoolVarP(&scaleParams.Wait, "wait", "w", false, "wait until the cluster converges")
+	scaleCmd.Flags().DurationVarP(&scaleParams.Timeout, "timeout", "", 2*time.Hour, "max time to wait for convergence")
+	_ = scaleCmd.MarkFlagRequired("name")
+	_ = scaleCmd.MarkFlagRequired("desired")
+	HostGroup.AddCommand(scaleCmd)
+}
@@ -0,0 +1,65 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"wekactl/internal/aws/cluster"
+	"wekactl/internal/env"
+	"wekactl/internal/logging"
+)
+
+var clusterFilePath string
+var outDir string
+var showDiff bool
+
+var Render = &cobra.Command{
+	Use:   "render [flags]",
+	Short: "Render the IAM policies, state machine definition, user data and api gateway policy of a cluster file",
+	Long: "Render writes the documents wekactl generates for the cluster file to the output directory without any cloud " +
+		"provider call, values only known once the cluster is deployed are replaced by placeholders. " +
+		"With --diff they are compared with the ones of the deployed cluster instead",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if outDir == "" && !showDiff {
+			err := errors.New("either --out or --diff must be set")
+			logging.UserFailure(err.Error())
+			return err
+		}
+		if env.Config.Provider == "aws" {
+			if outDir != "" {
+				err := cluster.RenderCluster(clusterFilePath, outDir)
+				if err != nil {
+					logging.UserFailure("Render failed!")
+					return err
+				}
+				logging.UserSuccess("Cluster file %s was rendered to %s", clusterFilePath, outDir)
+			}
+			if showDiff {
+				differences, err := cluster.DiffCluster(clusterFilePath)
+				if err != nil {
+					logging.UserFailure("Diff failed!")
+					return err
+				}
+				if differences > 0 {
+					err = errors.New(fmt.Sprintf("%d rendered documents differ from the deployed ones", differences))
+					logging.UserFailure(err.Error())
+					return err
+				}
+				logging.UserSuccess("Rendered documents match the deployed ones")
+			}
+		} else {
+			err := errors.New(fmt.Sprintf("Cloud provider '%s' is not supported with this action", env.Config.Provider))
+			logging.UserFailure(err.Error())
+			return err
+		}
+		return nil
+	},
+	SilenceUsage: true,
+}
+
+func init() {
+	Render.Flags().StringVarP(&clusterFilePath, "cluster-file", "f", "", "cluster file path, as written by cluster export")
+	Render.Flags().StringVarP(&outDir, "out", "", "", "directory the documents are written to")
+	Render.Flags().BoolVarP(&showDiff, "diff", "", false, "compare the documents with the deployed cluster ones")
+	_ = Render.MarkFlagRequired("cluster-file")
+}
@@ -25,7 +25,7 @@ type Resource interface {
 	Create(tags Tags) error
 	Update(tags Tags) error
 	Init()
-	//UpdateTags(tags Tags) error
+	UpdateTags(tags Tags, removedKeys []string) error
 }
 
 func EnsureResource(r Resource, clusterSettings IClusterSettings, dryRun bool) error {
@@ -66,11 +66,149 @@ func EnsureResource(r Resource, clusterSettings IClusterSettings, dryRun bool) e
 		return r.Update(tags)
 	}
 
-	//if len(clusterSettings.Tags) > 0 {
-	//	log.Info().Msgf("updating resource %s %s ...", resourceType, r.ResourceName())
-	//	return r.UpdateTags(tags)
-	//}
-
 	log.Debug().Msgf("resource %s %s exists and updated", resourceType, r.ResourceName())
 	return nil
 }
+
+// VerifiableResource is a resource able to compare its live configuration with the one wekactl would generate,
+// Verify describes every difference found and Reconcile brings the live configuration back to the generated one
+type VerifiableResource interface {
+	Resource
+	Verify() (drifts []string, err error)
+	Reconcile(tags Tags) error
+}
+
+type Drift struct {
+	ResourceType string
+	ResourceName string
+	Description  string
+}
+
+// VerifyResource walks the resource tree the same way EnsureResource does, reporting missing and outdated resources
+// and the configuration drift of existing ones, when fix is set every drift found is reconciled
+func VerifyResource(r Resource, clusterSettings IClusterSettings, fix bool) (drifts []Drift, err error) {
+	for _, subresource := range r.SubResources() {
+		subresourceDrifts, err := VerifyResource(subresource, clusterSettings, fix)
+		if err != nil {
+			return drifts, err
+		}
+		drifts = append(drifts, subresourceDrifts...)
+	}
+
+	resourceType := strings.TrimLeft(reflect.TypeOf(r).String(), "*cluster.")
+	tags := r.Tags().Update(clusterSettings.Tags())
+
+	err = r.Fetch()
+	if err != nil {
+		return
+	}
+
+	if r.DeployedVersion() == "" {
+		if resourceType == "HostGroup" || resourceType == "AWSCluster" {
+			// these resources are not actual aws resources, there is nothing to verify
+			return
+		}
+		drifts = append(drifts, Drift{resourceType, r.ResourceName(), "resource is missing"})
+		if fix {
+			log.Info().Msgf("creating resource %s %s ...", resourceType, r.ResourceName())
+			err = r.Create(tags)
+		}
+		return
+	}
+
+	if r.DeployedVersion() != r.TargetVersion() {
+		drifts = append(drifts, Drift{resourceType, r.ResourceName(), "resource version is outdated"})
+		if fix {
+			log.Info().Msgf("updating resource %s %s ...", resourceType, r.ResourceName())
+			err = r.Update(tags)
+		}
+		return
+	}
+
+	verifiable, ok := r.(VerifiableResource)
+	if !ok {
+		log.Debug().Msgf("resource %s %s has no configuration to verify", resourceType, r.ResourceName())
+		return
+	}
+
+	descriptions, err := verifiable.Verify()
+	if err != nil {
+		return
+	}
+	for _, description := range descriptions {
+		drifts = append(drifts, Drift{resourceType, r.ResourceName(), description})
+	}
+	if len(descriptions) > 0 && fix {
+		log.Info().Msgf("reconciling resource %s %s ...", resourceType, r.ResourceName())
+		err = verifiable.Reconcile(tags)
+	}
+	return
+}
+
+type ResourceVersion struct {
+	ResourceType    string
+	ResourceName    string
+	DeployedVersion string
+	TargetVersion   string
+}
+
+// GetResourceVersions fetches the resource tree and returns the deployed and target versions of every actual resource,
+// nothing is created or updated
+func GetResourceVersions(r Resource) (versions []ResourceVersion, err error) {
+	for _, subresource := range r.SubResources() {
+		subresourceVersions, err := GetResourceVersions(subresource)
+		if err != nil {
+			return versions, err
+		}
+		versions = append(versions, subresourceVersions...)
+	}
+
+	resourceType := strings.TrimLeft(reflect.TypeOf(r).String(), "*cluster.")
+	if resourceType == "HostGroup" || resourceType == "AWSCluster" {
+		return
+	}
+
+	err = r.Fetch()
+	if err != nil {
+		return
+	}
+	versions = append(versions, ResourceVersion{resourceType, r.ResourceName(), r.DeployedVersion(), r.TargetVersion()})
+	return
+}
+
+// UpdateResourceTags re-tags the existing resources of the tree with their own tags and the cluster settings ones, and
+// removes the removedKeys tags. The version tag is left as is, so that outdated resources are still updated later on.
+func UpdateResourceTags(r Resource, clusterSettings IClusterSettings, removedKeys []string) error {
+	for _, subresource := range r.SubResources() {
+		if err := UpdateResourceTags(subresource, clusterSettings, removedKeys); err != nil {
+			return err
+		}
+	}
+
+	resourceType := strings.TrimLeft(reflect.TypeOf(r).String(), "*cluster.")
+	if resourceType == "HostGroup" || resourceType == "AWSCluster" {
+		return nil
+	}
+
+	err := r.Fetch()
+	if err != nil {
+		return err
+	}
+	if r.DeployedVersion() == "" {
+		logging.UserInfo("resource %s \"%s\" is missing, it will be tagged when created by cluster update", resourceType, r.ResourceName())
+		return nil
+	}
+
+	tags := r.Tags().Update(clusterSettings.Tags())
+	delete(tags, VersionTagKey)
+	log.Info().Msgf("updating resource %s %s tags ...", resourceType, r.ResourceName())
+	return r.UpdateTags(tags, removedKeys)
+}
+
+// CountResources counts the resource and its sub resources by resource type, without fetching them
+func CountResources(r Resource, counts map[string]int) {
+	for _, subresource := range r.SubResources() {
+		CountResources(subresource, counts)
+	}
+	counts[strings.TrimLeft(reflect.TypeOf(r).String(), "*cluster.")]++
+}
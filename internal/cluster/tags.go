@@ -1,8 +1,11 @@
 package cluster
 
 import (
+	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/ec2"
@@ -10,6 +13,12 @@ import (
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/sfn"
+	"gopkg.in/yaml.v3"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
 type Tags map[string]string
@@ -18,6 +27,116 @@ type TagsRefsValues map[string]*string
 const VersionTagKey = "wekactl.io/version"
 const ClusterNameTagKey = "wekactl.io/cluster_name"
 
+// ReservedTagKeyPrefix prefixes the tags wekactl manages on its resources
+const ReservedTagKeyPrefix = "wekactl.io/"
+
+const (
+	// MaxTagsPerResource is the lowest tags limit of the resources wekactl creates
+	MaxTagsPerResource = 50
+	// MaxReservedTags is the most wekactl tags put on a single resource (e.g. ALB listener, hostgroup instance)
+	MaxReservedTags = 10
+	// MaxUserTags leaves room for the wekactl tags on every resource
+	MaxUserTags    = MaxTagsPerResource - MaxReservedTags
+	MaxTagKeyLen   = 128
+	MaxTagValueLen = 256
+)
+
+// tagCharsRe is the characters set allowed by all the tagged services, IAM and EC2 included
+var tagCharsRe = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// ParseTags parses key=value tags, values may hold '=' themselves
+func ParseTags(tagsList []string) (tags Tags, err error) {
+	tags = Tags{}
+	for _, tag := range tagsList {
+		keyVal := strings.SplitN(tag, "=", 2)
+		if len(keyVal) != 2 || keyVal[0] == "" {
+			err = errors.New(fmt.Sprintf("invalid tag '%s', tags should be passed as key=value", tag))
+			return
+		}
+		if _, ok := tags[keyVal[0]]; ok {
+			err = errors.New(fmt.Sprintf("tag %s is given more than once", keyVal[0]))
+			return
+		}
+		tags[keyVal[0]] = keyVal[1]
+	}
+	return
+}
+
+// ReadTagsFile reads tags from a YAML (or JSON) file holding a key: value map
+func ReadTagsFile(path string) (tags Tags, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	tags = Tags{}
+	err = yaml.Unmarshal(data, &tags)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("failed parsing tags file %s: %s", path, err.Error()))
+		return
+	}
+	return
+}
+
+// BuildTags merges the tags file with the key=value tags, which take precedence, and validates the result
+func BuildTags(tagsList []string, tagsFile string) (tags Tags, err error) {
+	tags = Tags{}
+	if tagsFile != "" {
+		tags, err = ReadTagsFile(tagsFile)
+		if err != nil {
+			return
+		}
+	}
+	parsed, err := ParseTags(tagsList)
+	if err != nil {
+		return
+	}
+	tags.Update(parsed)
+	err = ValidateTags(tags)
+	return
+}
+
+func validateTag(key, value string) error {
+	keyLen := utf8.RuneCountInString(key)
+	if keyLen == 0 || keyLen > MaxTagKeyLen {
+		return errors.New(fmt.Sprintf("tag key '%s' length must be between 1 and %d characters", key, MaxTagKeyLen))
+	}
+	if utf8.RuneCountInString(value) > MaxTagValueLen {
+		return errors.New(fmt.Sprintf("tag %s value is longer than %d characters", key, MaxTagValueLen))
+	}
+	if strings.HasPrefix(strings.ToLower(key), "aws:") {
+		return errors.New(fmt.Sprintf("tag %s is invalid, the aws: prefix is reserved for AWS use", key))
+	}
+	if strings.HasPrefix(key, ReservedTagKeyPrefix) {
+		return errors.New(fmt.Sprintf("tag %s is reserved, %s* tags are managed by wekactl", key, ReservedTagKeyPrefix))
+	}
+	if !tagCharsRe.MatchString(key) {
+		return errors.New(fmt.Sprintf("tag key '%s' contains invalid characters, allowed: letters, numbers, spaces and _.:/=+-@", key))
+	}
+	if !tagCharsRe.MatchString(value) {
+		return errors.New(fmt.Sprintf("tag %s value contains invalid characters, allowed: letters, numbers, spaces and _.:/=+-@", key))
+	}
+	return nil
+}
+
+// ValidateTags checks the user tags against the AWS tagging constraints and the wekactl reserved keys
+func ValidateTags(tags Tags) error {
+	if len(tags) > MaxUserTags {
+		return errors.New(fmt.Sprintf("%d tags were given, at most %d tags are supported, %d of the %d tags per resource are used by wekactl",
+			len(tags), MaxUserTags, MaxReservedTags, MaxTagsPerResource))
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := validateTag(key, tags[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (t Tags) ToDynamoDb() (ret []*dynamodb.Tag) {
 	for k, v := range t {
 		ret = append(ret, &dynamodb.Tag{
@@ -74,6 +193,17 @@ func (t Tags) AsCloudWatch() []*cloudwatchevents.Tag {
 	return cloudWatchEventTags
 }
 
+func (t Tags) AsCloudWatchAlarm() []*cloudwatch.Tag {
+	var cloudWatchTags []*cloudwatch.Tag
+	for key, value := range t {
+		cloudWatchTags = append(cloudWatchTags, &cloudwatch.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(value),
+		})
+	}
+	return cloudWatchTags
+}
+
 func (t Tags) AsIam() []*iam.Tag {
 	var iamTags []*iam.Tag
 	for key, value := range t {
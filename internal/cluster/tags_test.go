@@ -0,0 +1,81 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tags, err := ParseTags([]string{"owner=team-a", "secret=YWJjZA==", "url=https://x.io/?a=b", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Tags{"owner": "team-a", "secret": "YWJjZA==", "url": "https://x.io/?a=b", "empty": ""}
+	if len(tags) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, tags)
+	}
+	for k, v := range expected {
+		if tags[k] != v {
+			t.Fatalf("tag %s: expected %q, got %q", k, v, tags[k])
+		}
+	}
+
+	for _, invalid := range [][]string{{"novalue"}, {"=value"}, {"a=1", "a=2"}} {
+		if _, err = ParseTags(invalid); err == nil {
+			t.Fatalf("%v: expected an error", invalid)
+		}
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	valid := Tags{"owner": "team a", "cost-center": "rd_1", "path": "a/b:c@d+e=f.g", "שם": "ערך"}
+	if err := ValidateTags(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	tooMany := Tags{}
+	for i := 0; i <= MaxUserTags; i++ {
+		tooMany[fmt.Sprintf("tag%d", i)] = "v"
+	}
+	cases := []Tags{
+		{"aws:cloudformation": "x"},
+		{"AWS:x": "x"},
+		{"wekactl.io/cluster_name": "x"},
+		{strings.Repeat("k", MaxTagKeyLen+1): "x"},
+		{"k": strings.Repeat("v", MaxTagValueLen+1)},
+		{"k": "a,b"},
+		{"k#": "v"},
+		tooMany,
+	}
+	for _, tags := range cases {
+		if err := ValidateTags(tags); err == nil {
+			t.Fatalf("%v: expected an error", tags)
+		}
+	}
+}
+
+func TestBuildTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags.yaml")
+	err := os.WriteFile(path, []byte("owner: team-a\nenv: dev\nenabled: true\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := BuildTags([]string{"env=prod"}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags["owner"] != "team-a" || tags["env"] != "prod" || tags["enabled"] != "true" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	err = os.WriteFile(path, []byte(`{"wekactl.io/managed": "false"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = BuildTags(nil, path); err == nil {
+		t.Fatal("expected reserved tag error")
+	}
+}
@@ -1,10 +1,5 @@
 package cluster
 
-import (
-	"github.com/rs/zerolog/log"
-	"strings"
-)
-
 //goland:noinspection GoNameStartsWithPackageName
 type ClusterName string
 
@@ -13,28 +8,27 @@ type IClusterSettings interface {
 }
 
 type ImportParams struct {
-	Name                string
-	InstanceIds         []string
-	Username            string
-	Password            string
-	TagsList            []string
-	PrivateSubnet       bool
-	AdditionalAlbSubnet string
-	DnsAlias            string
-	DnsZoneId           string
-	UseDynamoDBEndpoint bool
+	Name                   string
+	InstanceIds            []string
+	Username               string
+	Password               string
+	TagsList               []string
+	TagsFile               string
+	PrivateSubnet          bool
+	AdditionalAlbSubnet    string
+	DnsAlias               string
+	DnsZoneId              string
+	UseDynamoDBEndpoint    bool
+	FailureDomain          string
+	FailureDomainCmd       string
+	Subnets                []string
+	IamRolesPath           string
+	IamRolesNamePrefix     string
+	IamPermissionsBoundary string
+	IamRoles               []string // NAME=ARN existing roles used instead of the ones wekactl creates
+	SkipPreflight          bool
 }
 
-func (params ImportParams) TagsMap() Tags {
-	tags := make(Tags)
-	if len(params.TagsList) > 0 {
-		for _, tag := range params.TagsList {
-			keyVal := strings.Split(tag, "=")
-			if len(keyVal) != 2 {
-				log.Fatal().Msgf("=(equal sign) is not allowed both in keys and values")
-			}
-			tags[keyVal[0]] = keyVal[1]
-		}
-	}
-	return tags
+func (params ImportParams) TagsMap() (Tags, error) {
+	return BuildTags(params.TagsList, params.TagsFile)
 }
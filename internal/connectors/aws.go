@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/apigateway"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/ec2"
@@ -16,6 +17,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sfn"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"sync"
@@ -23,7 +25,6 @@ import (
 )
 
 type SAwsSession struct {
-	sync.RWMutex
 	Session          *session.Session
 	CF               *cloudformation.CloudFormation
 	EC2              *ec2.EC2
@@ -35,42 +36,55 @@ type SAwsSession struct {
 	ApiGateway       *apigateway.APIGateway
 	STS              *sts.STS
 	SFN              *sfn.SFN
+	CloudWatch       *cloudwatch.CloudWatch
 	CloudWatchEvents *cloudwatchevents.CloudWatchEvents
 	ELB              *elb.ELB
 	ELBV2            *elbv2.ELBV2
 	Route53          *route53.Route53
+	S3               *s3.S3
 }
 
-var awsSession SAwsSession
+// awsSessions are the sessions by region, created once per region
+var awsSessions = struct {
+	sync.Mutex
+	sessions map[string]*SAwsSession
+}{sessions: map[string]*SAwsSession{}}
 
+// GetAWSSession returns the session of the region wekactl was invoked with
 func GetAWSSession() *SAwsSession {
-	if awsSession.Session != nil {
-		return &awsSession
-	}
-	awsSession.Lock()
-	defer awsSession.Unlock()
-	if awsSession.Session == nil {
-		awsSession.Session = newSession(env.Config.Region)
-		awsSession.CF = cloudformation.New(awsSession.Session)
-		awsSession.EC2 = ec2.New(awsSession.Session)
-		awsSession.ASG = autoscaling.New(awsSession.Session)
-		awsSession.KMS = kms.New(awsSession.Session)
-		awsSession.DynamoDB = dynamodb.New(awsSession.Session)
-		awsSession.IAM = iam.New(awsSession.Session)
-		awsSession.Lambda = lambda.New(awsSession.Session)
-		awsSession.ApiGateway = apigateway.New(awsSession.Session)
-		awsSession.STS = sts.New(awsSession.Session)
-		awsSession.SFN = sfn.New(awsSession.Session)
-		awsSession.CloudWatchEvents = cloudwatchevents.New(awsSession.Session)
-		awsSession.ELB = elb.New(awsSession.Session)
-		awsSession.ELBV2 = elbv2.New(awsSession.Session)
-		awsSession.Route53 = route53.New(awsSession.Session)
+	return GetRegionAWSSession(env.Config.Region)
+}
+
+// GetRegionAWSSession returns the region session, it is safe for concurrent use by multiple regions
+func GetRegionAWSSession(region string) *SAwsSession {
+	awsSessions.Lock()
+	defer awsSessions.Unlock()
+	if awsSession, ok := awsSessions.sessions[region]; ok {
+		return awsSession
 	}
-	return &awsSession
+	awsSession := &SAwsSession{}
+	awsSession.Session = newSession(region)
+	awsSession.CF = cloudformation.New(awsSession.Session)
+	awsSession.EC2 = ec2.New(awsSession.Session)
+	awsSession.ASG = autoscaling.New(awsSession.Session)
+	awsSession.KMS = kms.New(awsSession.Session)
+	awsSession.DynamoDB = dynamodb.New(awsSession.Session)
+	awsSession.IAM = iam.New(awsSession.Session)
+	awsSession.Lambda = lambda.New(awsSession.Session)
+	awsSession.ApiGateway = apigateway.New(awsSession.Session)
+	awsSession.STS = sts.New(awsSession.Session)
+	awsSession.SFN = sfn.New(awsSession.Session)
+	awsSession.CloudWatch = cloudwatch.New(awsSession.Session)
+	awsSession.CloudWatchEvents = cloudwatchevents.New(awsSession.Session)
+	awsSession.ELB = elb.New(awsSession.Session)
+	awsSession.ELBV2 = elbv2.New(awsSession.Session)
+	awsSession.Route53 = route53.New(awsSession.Session)
+	awsSession.S3 = s3.New(awsSession.Session)
+	awsSessions.sessions[region] = awsSession
+	return awsSession
 }
 
 func newSession(region string) *session.Session {
-	// TODO: Double-check if works profile
 	config := aws.NewConfig()
 	config = config.WithRegion(region)
 	config = config.WithCredentialsChainVerboseErrors(true)
@@ -79,6 +93,7 @@ func newSession(region string) *session.Session {
 	opts := session.Options{
 		Config:                  *config,
 		SharedConfigState:       session.SharedConfigEnable,
+		Profile:                 env.Config.AwsProfile,
 		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
 	}
 
@@ -1,8 +1,19 @@
 package env
 
+const (
+	OutputTable = "table"
+	OutputJson  = "json"
+)
+
+var Outputs = []string{OutputTable, OutputJson}
+
 var Config struct {
-	Provider string
-	Region   string
+	Provider   string
+	Region     string
+	Profile    string // wekactl config profile, see profiles.go
+	AwsProfile string // AWS shared config profile
+	Output     string
+	LogLevel   string
 }
 
 type VersionInfo struct {
@@ -0,0 +1,126 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DefaultProfile is used when no profile was selected
+const DefaultProfile = "default"
+
+// DefaultClusterAnnotation marks command groups whose "name" flag defaults to the profile cluster
+const DefaultClusterAnnotation = "wekactl.io/default-cluster"
+
+// Profile holds defaults for flags, applied when neither the flag nor its WEKACTL_* environment variable is set
+type Profile struct {
+	Region     string `yaml:"region,omitempty"`
+	AwsProfile string `yaml:"aws-profile,omitempty"`
+	Cluster    string `yaml:"cluster,omitempty"`
+	Output     string `yaml:"output,omitempty"`
+	LogLevel   string `yaml:"log-level,omitempty"`
+}
+
+// ProfileKeys are the profile settings names, each matches a wekactl flag
+var ProfileKeys = []string{"region", "aws-profile", "cluster", "output", "log-level"}
+
+func (p *Profile) field(key string) (*string, error) {
+	switch key {
+	case "region":
+		return &p.Region, nil
+	case "aws-profile":
+		return &p.AwsProfile, nil
+	case "cluster":
+		return &p.Cluster, nil
+	case "output":
+		return &p.Output, nil
+	case "log-level":
+		return &p.LogLevel, nil
+	}
+	return nil, errors.New(fmt.Sprintf("unknown setting %s, supported settings: %v", key, ProfileKeys))
+}
+
+func (p *Profile) Get(key string) (string, error) {
+	field, err := p.field(key)
+	if err != nil {
+		return "", err
+	}
+	return *field, nil
+}
+
+func (p *Profile) Set(key, value string) error {
+	field, err := p.field(key)
+	if err != nil {
+		return err
+	}
+	*field = value
+	return nil
+}
+
+type ConfigFile struct {
+	CurrentProfile string             `yaml:"current-profile,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+func (c ConfigFile) ProfileNames() (names []string) {
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// ConfigFilePath is WEKACTL_CONFIG, or wekactl/config.yaml under XDG_CONFIG_HOME (~/.config by default)
+func ConfigFilePath() (string, error) {
+	if path := os.Getenv("WEKACTL_CONFIG"); path != "" {
+		return path, nil
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "wekactl", "config.yaml"), nil
+}
+
+// LoadConfigFile returns an empty config if the file doesn't exist
+func LoadConfigFile() (configFile ConfigFile, err error) {
+	path, err := ConfigFilePath()
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	err = yaml.Unmarshal(data, &configFile)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("failed parsing config file %s: %v", path, err))
+	}
+	return
+}
+
+func SaveConfigFile(configFile ConfigFile) error {
+	path, err := ConfigFilePath()
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(configFile)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,98 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lines returns a line diff of from and to, unchanged lines further than context lines from a change are elided.
+// Removed lines are prefixed with "-", added lines with "+", an empty string is returned when there is no change
+func Lines(from, to string, context int) string {
+	if from == to {
+		return ""
+	}
+	a := splitLines(from)
+	b := splitLines(to)
+
+	// lcs[i][j] is the longest common subsequence length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	// distance of every line from the closest change
+	distance := make([]int, len(lines))
+	last := -1
+	for k := range lines {
+		if lines[k].op != ' ' {
+			last = k
+		}
+		distance[k] = len(lines)
+		if last >= 0 {
+			distance[k] = k - last
+		}
+	}
+	last = -1
+	for k := len(lines) - 1; k >= 0; k-- {
+		if lines[k].op != ' ' {
+			last = k
+		}
+		if last >= 0 && last-k < distance[k] {
+			distance[k] = last - k
+		}
+	}
+
+	var builder strings.Builder
+	elided := false
+	for k, l := range lines {
+		if distance[k] > context {
+			if !elided {
+				builder.WriteString("...\n")
+				elided = true
+			}
+			continue
+		}
+		elided = false
+		builder.WriteString(fmt.Sprintf("%c %s\n", l.op, l.text))
+	}
+	return builder.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
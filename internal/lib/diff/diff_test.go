@@ -0,0 +1,25 @@
+package diff
+
+import "testing"
+
+func TestLines(t *testing.T) {
+	cases := []struct {
+		name     string
+		from, to string
+		context  int
+		expected string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", 1, ""},
+		{"added", "", "a\nb\n", 1, "+ a\n+ b\n"},
+		{"removed", "a\nb\n", "", 1, "- a\n- b\n"},
+		{"changed", "a\nb\nc\n", "a\nx\nc\n", 1, "  a\n- b\n+ x\n  c\n"},
+		{"elided", "a\nb\nc\nd\ne\n", "a\nb\nc\nd\nx\n", 1, "...\n  d\n- e\n+ x\n"},
+		{"elided between changes", "x\nb\nc\nd\ny\n", "b\nc\nd\n", 0, "- x\n...\n- y\n"},
+	}
+	for _, c := range cases {
+		result := Lines(c.from, c.to, c.context)
+		if result != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, result)
+		}
+	}
+}
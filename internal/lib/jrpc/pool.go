@@ -2,12 +2,15 @@ package jrpc
 
 import (
 	"context"
+	"errors"
 	"github.com/rs/zerolog/log"
 	"sync"
 	strings2 "wekactl/internal/lib/strings"
 	"wekactl/internal/lib/weka"
 )
 
+var NoClientsInPool = errors.New("no weka backends left in jrpc pool")
+
 type ClientBuilder func(ip string) *BaseClient
 type Pool struct {
 	sync.RWMutex
@@ -38,6 +41,10 @@ func (c *Pool) Drop(toDrop string) {
 func (c *Pool) Call(method weka.JrpcMethod, params, result interface{}) (err error) {
 	if c.Active == "" {
 		c.Lock()
+		if len(c.Ips) == 0 {
+			c.Unlock()
+			return NoClientsInPool
+		}
 		c.Active = c.Ips[0]
 		c.Clients[c.Active] = c.Builder(c.Active)
 		c.Unlock()
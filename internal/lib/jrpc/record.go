@@ -0,0 +1,174 @@
+package jrpc
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"wekactl/internal/lib/jsonrpc2"
+)
+
+const (
+	RecordingVersion = 1
+	redacted         = "<redacted>"
+)
+
+var authMethods = []string{"user_login", "user_refresh_token"}
+var sensitiveKeys = []string{"password", "token", "secret", "username"}
+
+// Exchange is a single recorded jrpc request/response pair
+type Exchange struct {
+	Time     time.Time       `json:"time"`
+	Endpoint string          `json:"endpoint"`
+	Method   string          `json:"method"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    *jsonrpc2.Error `json:"error,omitempty"`
+}
+
+// Recording is the on-disk format written by Recorder and served by ReplayHandler.
+// Input optionally holds the (redacted) input of the operation that generated the traffic, e.g. the scale lambda event.
+type Recording struct {
+	Version   int             `json:"version"`
+	Created   time.Time       `json:"created"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	Exchanges []Exchange      `json:"exchanges"`
+}
+
+func ReadRecording(r io.Reader) (recording Recording, err error) {
+	err = json.NewDecoder(r).Decode(&recording)
+	return
+}
+
+func (r Recording) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Recorder collects redacted jrpc exchanges, it is safe for concurrent use.
+// Credentials and tokens never reach the recording: auth calls are kept only as method names,
+// and values of sensitive keys anywhere in params or results are replaced.
+type Recorder struct {
+	mu sync.Mutex
+	// pending requests by endpoint and id, ids are only unique per client
+	pending   map[string]*Exchange
+	recording Recording
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		pending:   map[string]*Exchange{},
+		recording: Recording{Version: RecordingVersion, Created: time.Now().UTC()},
+	}
+}
+
+func (r *Recorder) SetInput(input interface{}) error {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording.Input = redactJson(data)
+	return nil
+}
+
+func (r *Recorder) RecordRequest(endpoint string, request *jsonrpc2.WireRequest) {
+	if request.ID == nil {
+		return
+	}
+	exchange := &Exchange{
+		Time:     time.Now().UTC(),
+		Endpoint: endpoint,
+		Method:   request.Method,
+	}
+	if request.Params != nil {
+		exchange.Params = redactExchangeValue(request.Method, *request.Params)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending[pendingKey(endpoint, request.ID)] = exchange
+}
+
+func (r *Recorder) RecordResponse(endpoint string, response *jsonrpc2.WireResponse) {
+	if response.ID == nil {
+		return
+	}
+	key := pendingKey(endpoint, response.ID)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exchange, ok := r.pending[key]
+	if !ok {
+		return
+	}
+	delete(r.pending, key)
+	if response.Result != nil {
+		exchange.Result = redactExchangeValue(exchange.Method, *response.Result)
+	}
+	exchange.Error = response.Error
+	r.recording.Exchanges = append(r.recording.Exchanges, *exchange)
+}
+
+// Recording returns a snapshot of all completed exchanges
+func (r *Recorder) Recording() Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recording := r.recording
+	recording.Exchanges = append([]Exchange(nil), r.recording.Exchanges...)
+	return recording
+}
+
+func pendingKey(endpoint string, id *jsonrpc2.ID) string {
+	return endpoint + " " + id.String()
+}
+
+func redactExchangeValue(method string, data json.RawMessage) json.RawMessage {
+	for _, authMethod := range authMethods {
+		if method == authMethod {
+			return json.RawMessage(`"` + redacted + `"`)
+		}
+	}
+	return redactJson(data)
+}
+
+func redactJson(data json.RawMessage) json.RawMessage {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return json.RawMessage(`"` + redacted + `"`)
+	}
+	redactedData, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return json.RawMessage(`"` + redacted + `"`)
+	}
+	return redactedData
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			if isSensitiveKey(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(inner)
+			}
+		}
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = redactValue(inner)
+		}
+	}
+	return value
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package jrpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"wekactl/internal/lib/jrpc"
+	"wekactl/internal/lib/jsonrpc2"
+	"wekactl/internal/lib/weka"
+	"wekactl/internal/lib/weka/fake"
+)
+
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Printf(format string, v ...interface{}) {
+	l.t.Logf(format, v...)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	cluster := fake.NewCluster()
+	cluster.AddBackend("10.0.0.1", "i-1", 2)
+	server := fake.NewServer(cluster, "admin", "top-secret")
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	recorder := jrpc.NewRecorder()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+	proxy := httptest.NewServer(jrpc.NewRecordingProxy(host, portNumber, recorder))
+	defer proxy.Close()
+	proxyEndpoint, _ := url.Parse(proxy.URL + "/api/v1")
+
+	opt := jrpc.ClientOptions{}
+	opt.AuthenticatedClient("admin", "top-secret", "")
+	client := jrpc.NewClient(ctx, testLogger{t}, proxyEndpoint, &http.Transport{}, &opt)
+
+	recorded := json.RawMessage{}
+	if err := client.Call(ctx, string(weka.JrpcHostList), struct{}{}, &recorded); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := recorder.Recording().Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "top-secret") {
+		t.Fatal("recording contains password")
+	}
+
+	recording, err := jrpc.ReadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := jrpc.NewReplayHandler(recording)
+	opt = jrpc.ClientOptions{}
+	opt.AuthenticatedClient("admin", "anything", "")
+	endpoint := &url.URL{Scheme: "http", Host: "replay", Path: "/api/v1"}
+	replayClient := jrpc.NewClient(ctx, testLogger{t}, endpoint, jrpc.NewReplayTransport(replay), &opt)
+
+	replayed := weka.HostListResponse{}
+	if err := replayClient.Call(ctx, string(weka.JrpcHostList), struct{}{}, &replayed); err != nil {
+		t.Fatal(err)
+	}
+	original := weka.HostListResponse{}
+	if err := json.Unmarshal(recorded, &original); err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 1 || len(replayed) != len(original) {
+		t.Fatalf("replayed %d hosts, recorded %d", len(replayed), len(original))
+	}
+	for hostId, host := range replayed {
+		if original[hostId].Aws.InstanceId != host.Aws.InstanceId {
+			t.Errorf("replayed host %s differs from recorded", hostId.String())
+		}
+	}
+
+	if err := replayClient.Call(ctx, string(weka.JrpcDeactivateHosts), struct{}{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(replay.Unmatched) != 1 || replay.Unmatched[0] != string(weka.JrpcDeactivateHosts) {
+		t.Errorf("expected unrecorded call to be reported, got %v", replay.Unmatched)
+	}
+}
+
+func TestRecorderPairsByEndpoint(t *testing.T) {
+	recorder := jrpc.NewRecorder()
+	id := &jsonrpc2.ID{Number: 1}
+	for _, endpoint := range []string{"http://a/api/v1", "http://b/api/v1"} {
+		params := json.RawMessage(`{}`)
+		recorder.RecordRequest(endpoint, &jsonrpc2.WireRequest{Method: endpoint, Params: &params, ID: id})
+	}
+	for _, endpoint := range []string{"http://b/api/v1", "http://a/api/v1"} {
+		result := json.RawMessage(`"` + endpoint + `"`)
+		recorder.RecordResponse(endpoint, &jsonrpc2.WireResponse{Result: &result, ID: id})
+	}
+
+	exchanges := recorder.Recording().Exchanges
+	if len(exchanges) != 2 {
+		t.Fatalf("expected 2 exchanges, got %d", len(exchanges))
+	}
+	for _, exchange := range exchanges {
+		if exchange.Method != exchange.Endpoint || string(exchange.Result) != `"`+exchange.Endpoint+`"` {
+			t.Errorf("request of %s paired with response %s", exchange.Endpoint, exchange.Result)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package jrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"sync"
+	"wekactl/internal/lib/jsonrpc2"
+	strings2 "wekactl/internal/lib/strings"
+)
+
+// ReplayHandler serves a Recording as a weka management api.
+// Calls of each method are answered with the recorded responses of that method in order, the last one is repeated
+// once they run out. Methods never recorded (e.g. a deactivation the original run did not do) succeed with a null
+// result and are kept in Unmatched, so the caller can tell where the replayed run diverged from the recorded one.
+type ReplayHandler struct {
+	mu        sync.Mutex
+	responses map[string][]Exchange
+	served    map[string]int
+	Unmatched []string
+}
+
+func NewReplayHandler(recording Recording) *ReplayHandler {
+	h := &ReplayHandler{
+		responses: map[string][]Exchange{},
+		served:    map[string]int{},
+	}
+	for _, exchange := range recording.Exchanges {
+		h.responses[exchange.Method] = append(h.responses[exchange.Method], exchange)
+	}
+	return h
+}
+
+func (h *ReplayHandler) next(method string) (exchange Exchange, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	responses := h.responses[method]
+	if len(responses) == 0 {
+		h.Unmatched = append(h.Unmatched, method)
+		return
+	}
+	i := h.served[method]
+	if i >= len(responses) {
+		i = len(responses) - 1
+	}
+	h.served[method]++
+	return responses[i], true
+}
+
+func (h *ReplayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request jsonrpc2.WireRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response := jsonrpc2.WireResponse{ID: request.ID}
+	result := json.RawMessage("null")
+	if strings2.AnyOf(request.Method, authMethods...) {
+		// tokens are never recorded, any token will do as nothing checks it
+		result = json.RawMessage(`{"access_token": "replay", "refresh_token": "replay", "expires_in": 3600, "token_type": "Bearer"}`)
+	} else if exchange, ok := h.next(request.Method); ok {
+		response.Error = exchange.Error
+		if len(exchange.Result) > 0 {
+			result = exchange.Result
+		}
+	}
+	if response.Error == nil {
+		response.Result = &result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+type replayTransport struct {
+	handler http.Handler
+}
+
+// NewReplayTransport returns a RoundTripper answering every request from handler without any network access,
+// it can be passed to NewClient as is
+func NewReplayTransport(handler http.Handler) http.RoundTripper {
+	return &replayTransport{handler: handler}
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, req)
+	response := recorder.Result()
+	response.Request = req
+	return response, nil
+}
+
+// ServeLoopback listens on a distinct loopback address (127.0.1.1, 127.0.1.2, ...) and the given port for each ip,
+// serving it with handlerFor(ip). It returns the loopback addresses in the same order as ips.
+// This lets code that builds its own jrpc clients from a list of ips and a fixed port (like the go-cloud-lib scale down)
+// talk to proxies or replays while keeping one endpoint per backend. Relies on the whole 127/8 being routed to
+// loopback, which is the case on linux (and aws lambda) but not by default on macOS.
+func ServeLoopback(ips []string, port int, handlerFor func(ip string) http.Handler) (addresses []string, stop func(), err error) {
+	var servers []*http.Server
+	stop = func() {
+		for _, server := range servers {
+			_ = server.Close()
+		}
+	}
+	for i, ip := range ips {
+		address := net.IPv4(127, 0, byte(1+(i+1)/256), byte((i+1)%256)).String()
+		listener, listenErr := net.Listen("tcp", net.JoinHostPort(address, strconv.Itoa(port)))
+		if listenErr != nil {
+			stop()
+			err = listenErr
+			return
+		}
+		server := &http.Server{Handler: handlerFor(ip)}
+		servers = append(servers, server)
+		go func() {
+			_ = server.Serve(listener)
+		}()
+		addresses = append(addresses, address)
+	}
+	return
+}
+
+// NewRecordingProxy returns a reverse proxy to the management api of ip that records all traffic into recorder
+func NewRecordingProxy(ip string, port int, recorder *Recorder) http.Handler {
+	target := &url.URL{Scheme: "http", Host: net.JoinHostPort(ip, strconv.Itoa(port))}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := target.String() + r.URL.Path
+		var request jsonrpc2.WireRequest
+		body, err := readBody(r)
+		if err == nil && json.Unmarshal(body, &request) == nil {
+			recorder.RecordRequest(endpoint, &request)
+		}
+		captured := &captureWriter{ResponseWriter: w}
+		proxy.ServeHTTP(captured, r)
+		var response jsonrpc2.WireResponse
+		if (captured.status == 0 || captured.status == http.StatusOK) && json.Unmarshal(captured.body, &response) == nil {
+			recorder.RecordResponse(endpoint, &response)
+		}
+	})
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, err
+}
+
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body = append(w.body, b...)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,62 @@
+package version
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parse returns the numeric parts of versions like 1.0.5, dev builds suffixes (1.0.5-<commit>) and package ID
+// prefixes (release/1.0.5) are ignored
+func parse(version string) (parts []int, err error) {
+	version = version[strings.LastIndex(version, "/")+1:]
+	version = strings.SplitN(version, "-", 2)[0]
+	if version == "" {
+		err = errors.New("empty version")
+		return
+	}
+	for _, part := range strings.Split(version, ".") {
+		number, convErr := strconv.Atoi(part)
+		if convErr != nil {
+			err = errors.New(fmt.Sprintf("invalid version %s", version))
+			return
+		}
+		parts = append(parts, number)
+	}
+	return
+}
+
+// Compare returns -1, 0 or 1 when a is older, the same or newer than b, it fails when either is not a dotted version
+func Compare(a, b string) (result int, err error) {
+	aParts, err := parse(a)
+	if err != nil {
+		return
+	}
+	bParts, err := parse(b)
+	if err != nil {
+		return
+	}
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if aPart < bPart {
+			return -1, nil
+		}
+		if aPart > bPart {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// IsDowngrade tells whether moving from version to targetVersion goes back, versions that can't be compared never are
+func IsDowngrade(version, targetVersion string) bool {
+	result, err := Compare(targetVersion, version)
+	return err == nil && result < 0
+}
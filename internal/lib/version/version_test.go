@@ -0,0 +1,38 @@
+package version
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.5", "1.0.5", 0},
+		{"1.0.4", "1.0.5", -1},
+		{"1.1.0", "1.0.12", 1},
+		{"1.0", "1.0.0", 0},
+		{"1.0.5-0f3e2a1", "1.0.5", 0},
+		{"release/1.0.6", "dev/1.0.5-0f3e2a1", 1},
+	}
+	for _, c := range cases {
+		result, err := Compare(c.a, c.b)
+		if err != nil {
+			t.Fatalf("%s %s: %s", c.a, c.b, err)
+		}
+		if result != c.expected {
+			t.Errorf("%s %s: expected %d, got %d", c.a, c.b, c.expected, result)
+		}
+	}
+}
+
+func TestIsDowngrade(t *testing.T) {
+	if !IsDowngrade("1.0.6", "1.0.5") {
+		t.Error("1.0.6 to 1.0.5 should be a downgrade")
+	}
+	if IsDowngrade("1.0.5", "1.0.6") {
+		t.Error("1.0.5 to 1.0.6 should not be a downgrade")
+	}
+	if IsDowngrade("", "1.0.5") || IsDowngrade("1.0.5", "custom") {
+		t.Error("versions that can't be compared should not be a downgrade")
+	}
+}
@@ -16,15 +16,53 @@ const (
 	JrpcDeactivateDrives JrpcMethod = "cluster_deactivate_drives"
 	JrpcDeactivateHosts  JrpcMethod = "cluster_deactivate_hosts"
 	JrpcStatus           JrpcMethod = "status"
+	JrpcFilesystemsList  JrpcMethod = "filesystems_list"
+	JrpcEmitCustomEvent  JrpcMethod = "events_trigger_custom"
 )
 
 type HostListResponse map[HostId]Host
 type DriveListResponse map[DriveId]Drive
 type NodeListResponse map[NodeId]Node
+type FilesystemListResponse map[string]Filesystem
+
+type ProtectionState struct {
+	NumFailures int     `json:"numFailures"`
+	Percent     float64 `json:"percent"`
+	MiB         int64   `json:"MiB"`
+}
 
 type StatusResponse struct {
 	IoStatus string `json:"io_status"`
 	Upgrade  string `json:"upgrade"`
+	Rebuild  struct {
+		ProtectionState []ProtectionState `json:"protectionState"`
+	} `json:"rebuild"`
+	Capacity struct {
+		TotalBytes         int64 `json:"total_bytes"`
+		UnprovisionedBytes int64 `json:"unprovisioned_bytes"`
+	} `json:"capacity"`
+	// Activity holds per second rates
+	Activity struct {
+		NumOps          float64 `json:"num_ops"`
+		SumBytesRead    float64 `json:"sum_bytes_read"`
+		SumBytesWritten float64 `json:"sum_bytes_written"`
+	} `json:"activity"`
+	Hosts struct {
+		Backends struct {
+			Active int `json:"active"`
+			Total  int `json:"total"`
+		} `json:"backends"`
+	} `json:"hosts"`
+}
+
+// FullyProtected returns true when no data is left degraded by failures, i.e. there is nothing to rebuild
+func (s StatusResponse) FullyProtected() bool {
+	for _, state := range s.Rebuild.ProtectionState {
+		if state.NumFailures > 0 && state.MiB > 0 {
+			return false
+		}
+	}
+	return true
 }
 
 type Host struct {
@@ -33,11 +71,17 @@ type Host struct {
 	State            string    `json:"state"`
 	Status           string    `json:"status"`
 	HostIp           string    `json:"host_ip"`
+	Mode             string    `json:"mode"`
 	Aws              struct {
 		InstanceId string `json:"instance_id"`
 	} `json:"aws"`
 }
 
+type Filesystem struct {
+	Name    string `json:"name"`
+	UsedSSD int64  `json:"used_ssd"`
+}
+
 type Drive struct {
 	HostId         HostId    `json:"host_id"`
 	Status         string    `json:"status"`
@@ -112,3 +112,68 @@ func TestDriveListUnmarshalling(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestStatusFullyProtected(t *testing.T) {
+	input := []byte(`{
+  "io_status": "STARTED",
+  "upgrade": "",
+  "rebuild": {
+    "progressPercent": 40,
+    "protectionState": [
+      {"numFailures": 0, "percent": 90.5, "MiB": 905},
+      {"numFailures": 1, "percent": 9.5, "MiB": 95},
+      {"numFailures": 2, "percent": 0, "MiB": 0}
+    ]
+  }}`)
+
+	response := StatusResponse{}
+	err := json.Unmarshal(input, &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.FullyProtected() {
+		t.Error("status with degraded data reported as fully protected")
+	}
+
+	response.Rebuild.ProtectionState[1].MiB = 0
+	if !response.FullyProtected() {
+		t.Error("status without degraded data reported as not fully protected")
+	}
+}
+
+func TestClusterMetrics(t *testing.T) {
+	statusInput := []byte(`{
+  "io_status": "STARTED",
+  "capacity": {"total_bytes": 4000, "unprovisioned_bytes": 1000, "hot_spare_bytes": 0},
+  "activity": {"num_ops": 1500.5, "num_reads": 1000, "num_writes": 500.5, "sum_bytes_read": 2048, "sum_bytes_written": 1024},
+  "hosts": {"backends": {"active": 5, "total": 6}, "clients": {"active": 2, "total": 2}, "total_count": 8},
+  "rebuild": {"protectionState": [{"numFailures": 0, "percent": 100, "MiB": 1000}]}}`)
+	filesystemsInput := []byte(`{
+  "FSId<0>": {"name": "default", "used_ssd": 600, "available_ssd": 2000},
+  "FSId<1>": {"name": "scratch", "used_ssd": 400, "available_ssd": 1000}}`)
+
+	status := StatusResponse{}
+	if err := json.Unmarshal(statusInput, &status); err != nil {
+		t.Fatal(err)
+	}
+	filesystems := FilesystemListResponse{}
+	if err := json.Unmarshal(filesystemsInput, &filesystems); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := GetClusterMetrics(status, filesystems)
+	expected := ClusterMetrics{
+		CapacityTotalBytes:       4000,
+		CapacityUsedBytes:        1000,
+		CapacityFreeBytes:        3000,
+		CapacityUsedPercent:      25,
+		ThroughputBytesPerSecond: 3072,
+		Iops:                     1500.5,
+		ActiveBackends:           5,
+		InactiveBackends:         1,
+		FullyProtected:           true,
+	}
+	if metrics != expected {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+}
@@ -0,0 +1,341 @@
+package fake
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hostIdPrefix  = "HostId<"
+	driveIdPrefix = "DiskId<"
+	nodeIdPrefix  = "NodeId<"
+)
+
+type Host struct {
+	Id               int
+	State            string
+	Status           string
+	Mode             string
+	ContainerName    string
+	HostIp           string
+	InstanceId       string
+	AddedTime        time.Time
+	StateChangedTime time.Time
+}
+
+type Drive struct {
+	Id             int
+	HostId         int
+	Uuid           uuid.UUID
+	Status         string
+	ShouldBeActive bool
+}
+
+type Node struct {
+	Id              int
+	HostId          int
+	Status          string
+	Roles           []string
+	UpSince         *time.Time
+	LastFencingTime *time.Time
+}
+
+// Cluster is an in-memory model of a weka cluster, shared by all fake servers that front it.
+// Tests may mutate it directly (under lock) or through the helper methods.
+type Cluster struct {
+	sync.Mutex
+	IoStatus string
+	Upgrade  string
+	// UnprotectedMiB is reported by status as data that lost one failure domain and waits for rebuild
+	UnprotectedMiB int64
+	// CapacityBytes is the cluster total capacity, UsedBytes is reported as used by a single filesystem
+	CapacityBytes int64
+	UsedBytes     int64
+	Hosts         map[int]*Host
+	Drives        map[int]*Drive
+	Nodes         map[int]*Node
+	// Events holds the messages of custom events emitted through the api, in order
+	Events []string
+
+	nextHostId  int
+	nextDriveId int
+	nextNodeId  int
+}
+
+func NewCluster() *Cluster {
+	return &Cluster{
+		IoStatus: "STARTED",
+		Hosts:    map[int]*Host{},
+		Drives:   map[int]*Drive{},
+		Nodes:    map[int]*Node{},
+	}
+}
+
+// AddBackend adds an active backend host with a management node, a compute node and the given number of drives
+func (c *Cluster) AddBackend(ip, instanceId string, drives int) int {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now().UTC()
+	hostId := c.nextHostId
+	c.nextHostId++
+	c.Hosts[hostId] = &Host{
+		Id:               hostId,
+		State:            "ACTIVE",
+		Status:           "UP",
+		Mode:             "backend",
+		ContainerName:    "default",
+		HostIp:           ip,
+		InstanceId:       instanceId,
+		AddedTime:        now,
+		StateChangedTime: now,
+	}
+	for _, role := range []string{"MANAGEMENT", "COMPUTE"} {
+		c.Nodes[c.nextNodeId] = &Node{
+			Id:      c.nextNodeId,
+			HostId:  hostId,
+			Status:  "UP",
+			Roles:   []string{role},
+			UpSince: &now,
+		}
+		c.nextNodeId++
+	}
+	for i := 0; i < drives; i++ {
+		c.Drives[c.nextDriveId] = &Drive{
+			Id:             c.nextDriveId,
+			HostId:         hostId,
+			Uuid:           uuid.New(),
+			Status:         "ACTIVE",
+			ShouldBeActive: true,
+		}
+		c.nextDriveId++
+	}
+	return hostId
+}
+
+// SetHostStatus changes status of host and all of its nodes, e.g. "DOWN" to simulate an unreachable backend
+func (c *Cluster) SetHostStatus(hostId int, status string) {
+	c.Lock()
+	defer c.Unlock()
+	if host, ok := c.Hosts[hostId]; ok {
+		host.Status = status
+	}
+	for _, node := range c.Nodes {
+		if node.HostId == hostId {
+			node.Status = status
+		}
+	}
+}
+
+// CompleteDeactivation moves every host and drive in DEACTIVATING state to INACTIVE,
+// simulating the background work a real cluster does between deactivate and remove
+func (c *Cluster) CompleteDeactivation() {
+	c.Lock()
+	defer c.Unlock()
+	for _, host := range c.Hosts {
+		if host.State == "DEACTIVATING" {
+			host.State = "INACTIVE"
+			host.StateChangedTime = time.Now().UTC()
+		}
+	}
+	for _, drive := range c.Drives {
+		if drive.Status == "DEACTIVATING" {
+			drive.Status = "INACTIVE"
+		}
+	}
+}
+
+func (c *Cluster) status() map[string]interface{} {
+	active, total := 0, 0
+	for _, host := range c.Hosts {
+		if host.Mode != "backend" {
+			continue
+		}
+		total++
+		if host.State == "ACTIVE" && host.Status == "UP" {
+			active++
+		}
+	}
+	return map[string]interface{}{
+		"io_status": c.IoStatus,
+		"upgrade":   c.Upgrade,
+		"rebuild": map[string]interface{}{
+			"protectionState": []map[string]interface{}{
+				{"numFailures": 0, "percent": 100, "MiB": 1024},
+				{"numFailures": 1, "percent": 0, "MiB": c.UnprotectedMiB},
+			},
+		},
+		"capacity": map[string]interface{}{
+			"total_bytes": c.CapacityBytes,
+		},
+		"hosts": map[string]interface{}{
+			"backends": map[string]interface{}{
+				"active": active,
+				"total":  total,
+			},
+		},
+	}
+}
+
+func (c *Cluster) filesystemsList() map[string]interface{} {
+	return map[string]interface{}{
+		"FSId<0>": map[string]interface{}{
+			"name":     "default",
+			"used_ssd": c.UsedBytes,
+		},
+	}
+}
+
+func (c *Cluster) hostsList() map[string]interface{} {
+	result := map[string]interface{}{}
+	for id, host := range c.Hosts {
+		result[formatId(hostIdPrefix, id)] = map[string]interface{}{
+			"added_time":         host.AddedTime,
+			"state_changed_time": host.StateChangedTime,
+			"state":              host.State,
+			"status":             host.Status,
+			"mode":               host.Mode,
+			"container_name":     host.ContainerName,
+			"host_ip":            host.HostIp,
+			"mgmt_port":          14000,
+			"aws": map[string]interface{}{
+				"instance_id": host.InstanceId,
+			},
+		}
+	}
+	return result
+}
+
+func (c *Cluster) drivesList() map[string]interface{} {
+	result := map[string]interface{}{}
+	for id, drive := range c.Drives {
+		result[formatId(driveIdPrefix, id)] = map[string]interface{}{
+			"host_id":          formatId(hostIdPrefix, drive.HostId),
+			"status":           drive.Status,
+			"uuid":             drive.Uuid,
+			"should_be_active": drive.ShouldBeActive,
+		}
+	}
+	return result
+}
+
+func (c *Cluster) nodesList() map[string]interface{} {
+	result := map[string]interface{}{}
+	for id, node := range c.Nodes {
+		result[formatId(nodeIdPrefix, id)] = map[string]interface{}{
+			"host_id":           formatId(hostIdPrefix, node.HostId),
+			"status":            node.Status,
+			"roles":             node.Roles,
+			"up_since":          node.UpSince,
+			"last_fencing_time": node.LastFencingTime,
+		}
+	}
+	return result
+}
+
+func (c *Cluster) deactivateHosts(hostIds []int) error {
+	for _, id := range hostIds {
+		if _, ok := c.Hosts[id]; !ok {
+			return errors.New(fmt.Sprintf("host %s not found", formatId(hostIdPrefix, id)))
+		}
+	}
+	for _, id := range hostIds {
+		host := c.Hosts[id]
+		if host.State == "ACTIVE" {
+			host.State = "DEACTIVATING"
+			host.StateChangedTime = time.Now().UTC()
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) deactivateDrives(uuids []uuid.UUID) error {
+	drives, err := c.drivesByUuid(uuids)
+	if err != nil {
+		return err
+	}
+	for _, drive := range drives {
+		drive.ShouldBeActive = false
+		if drive.Status == "ACTIVE" {
+			drive.Status = "DEACTIVATING"
+		}
+	}
+	return nil
+}
+
+func (c *Cluster) removeDrives(uuids []uuid.UUID) error {
+	drives, err := c.drivesByUuid(uuids)
+	if err != nil {
+		return err
+	}
+	for _, drive := range drives {
+		if drive.Status != "INACTIVE" {
+			return errors.New(fmt.Sprintf("drive %s is %s, can't remove active drive", drive.Uuid, drive.Status))
+		}
+	}
+	for _, drive := range drives {
+		delete(c.Drives, drive.Id)
+	}
+	return nil
+}
+
+func (c *Cluster) removeHost(hostId int) error {
+	host, ok := c.Hosts[hostId]
+	if !ok {
+		return errors.New(fmt.Sprintf("host %s not found", formatId(hostIdPrefix, hostId)))
+	}
+	if host.State != "INACTIVE" {
+		return errors.New(fmt.Sprintf("host %s is %s, can't remove active host", formatId(hostIdPrefix, hostId), host.State))
+	}
+	for id, drive := range c.Drives {
+		if drive.HostId == hostId {
+			delete(c.Drives, id)
+		}
+	}
+	for id, node := range c.Nodes {
+		if node.HostId == hostId {
+			delete(c.Nodes, id)
+		}
+	}
+	delete(c.Hosts, hostId)
+	return nil
+}
+
+func (c *Cluster) drivesByUuid(uuids []uuid.UUID) (drives []*Drive, err error) {
+	ids := make([]int, 0, len(c.Drives))
+	for id := range c.Drives {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, u := range uuids {
+		var found *Drive
+		for _, id := range ids {
+			if c.Drives[id].Uuid == u {
+				found = c.Drives[id]
+				break
+			}
+		}
+		if found == nil {
+			err = errors.New(fmt.Sprintf("drive %s not found", u))
+			return
+		}
+		drives = append(drives, found)
+	}
+	return
+}
+
+func formatId(prefix string, id int) string {
+	return fmt.Sprintf("%s%d>", prefix, id)
+}
+
+// parseId accepts both weka formatted ids ("HostId<3>") and plain integers
+func parseId(prefix string, value string) (int, error) {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, prefix), ">")
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,94 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"github.com/weka/go-cloud-lib/protocol"
+	"github.com/weka/go-cloud-lib/scale_down"
+	"net/http"
+	"sync"
+	"testing"
+	"wekactl/internal/lib/jrpc"
+	"wekactl/internal/lib/weka"
+)
+
+// TestScaleDownPartialOutage runs the scale down lambda logic against a cluster where one of the backends
+// doesn't answer management requests
+func TestScaleDownPartialOutage(t *testing.T) {
+	cluster := NewCluster()
+	info := protocol.HostGroupInfoResponse{
+		Username:        "admin",
+		Password:        "secret",
+		DesiredCapacity: 2,
+		Role:            "backend",
+	}
+	var ips []string
+	servers := map[string]*Server{}
+	for i := 1; i <= 3; i++ {
+		ip := fmt.Sprintf("10.0.0.%d", i)
+		instanceId := fmt.Sprintf("i-%d", i)
+		hostId := cluster.AddBackend(ip, instanceId, 2)
+		cluster.Hosts[hostId].ContainerName = "drives0"
+		info.Instances = append(info.Instances, protocol.HgInstance{Id: instanceId, PrivateIp: ip})
+
+		server := NewServer(cluster, "admin", "secret")
+		defer server.Close()
+		ips = append(ips, ip)
+		servers[ip] = server
+	}
+
+	// scale down shuffles the backends, the first one it contacts accepts the login and then stops answering
+	var down *Server
+	var once sync.Once
+	// scale down connects to the management port of every backend ip, so the servers are exposed on loopback addresses
+	addresses, stop, err := jrpc.ServeLoopback(ips, weka.ManagementJrpcPort, func(ip string) http.Handler {
+		server := servers[ip]
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			once.Do(func() {
+				down = server
+				server.InjectFault(Fault{Method: string(weka.JrpcStatus), Kind: FaultTimeout})
+			})
+			server.Config.Handler.ServeHTTP(w, r)
+		})
+	})
+	if err != nil {
+		t.Skipf("can't listen on loopback management port: %v", err)
+	}
+	defer stop()
+	info.BackendIps = addresses
+
+	response, err := scale_down.ScaleDown(context.Background(), info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.TransientErrors) != 0 {
+		t.Errorf("unexpected transient errors: %v", response.TransientErrors)
+	}
+	if len(response.Hosts) != 3 {
+		t.Fatalf("expected 3 hosts in response, got %d", len(response.Hosts))
+	}
+	if calls := down.Calls(); len(calls) != 2 {
+		t.Errorf("expected the unresponsive backend to be dropped after its first call, got %v", calls)
+	}
+
+	cluster.Lock()
+	defer cluster.Unlock()
+	deactivating := 0
+	for _, host := range cluster.Hosts {
+		if host.State != "DEACTIVATING" {
+			continue
+		}
+		deactivating++
+		for _, drive := range cluster.Drives {
+			if drive.HostId == host.Id && drive.ShouldBeActive {
+				t.Errorf("drive %s of deactivated host %s is still expected to be active", drive.Uuid, host.HostIp)
+			}
+		}
+	}
+	if deactivating != 1 {
+		t.Errorf("expected a single backend to be deactivated, got %d", deactivating)
+	}
+	if len(cluster.Events) != 1 {
+		t.Errorf("expected a single scale down event, got %v", cluster.Events)
+	}
+}
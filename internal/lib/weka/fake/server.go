@@ -0,0 +1,351 @@
+// Package fake provides an in-process weka management API (JSON-RPC over HTTP) for tests.
+//
+// A single Cluster model may be fronted by several Servers, which allows simulating partial outages
+// where only some of the backends answer management requests.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"wekactl/internal/lib/jsonrpc2"
+	strings2 "wekactl/internal/lib/strings"
+	"wekactl/internal/lib/weka"
+)
+
+const (
+	userLogin        = "user_login"
+	userRefreshToken = "user_refresh_token"
+	tokenExpiresIn   = 300
+)
+
+type FaultKind int
+
+const (
+	// FaultTimeout holds the request until the client gives up (or the server is closed)
+	FaultTimeout FaultKind = iota
+	// FaultUnauthorized answers with HTTP 401
+	FaultUnauthorized
+	// FaultMethodNotFound answers with a JSON-RPC "Method not found" error
+	FaultMethodNotFound
+	// FaultUnavailable answers with HTTP 503, which the client retries
+	FaultUnavailable
+	// FaultError answers with a JSON-RPC error carrying Fault.Message
+	FaultError
+)
+
+// Fault is applied to requests of Method (any method if empty), Count times (forever if 0)
+type Fault struct {
+	Method  string
+	Kind    FaultKind
+	Count   int
+	Message string
+}
+
+type Server struct {
+	*httptest.Server
+	Cluster  *Cluster
+	Username string
+	Password string
+	// TokenExpiresIn is the expires_in of issued access tokens, in seconds.
+	// Clients refresh tokens that are about to expire, so a few seconds makes every call go through user_refresh_token.
+	TokenExpiresIn int
+
+	mu     sync.Mutex
+	faults []*Fault
+	tokens map[string]bool
+	// refreshTokens holds the issued refresh tokens that were not used yet, each may be used once
+	refreshTokens map[string]bool
+	calls         []string
+	stop          chan struct{}
+}
+
+// NewServer starts a fake management API for cluster, accepting the given credentials
+func NewServer(cluster *Cluster, username, password string) *Server {
+	s := &Server{
+		Cluster:        cluster,
+		Username:       username,
+		Password:       password,
+		TokenExpiresIn: tokenExpiresIn,
+		tokens:         map[string]bool{},
+		refreshTokens:  map[string]bool{},
+		stop:           make(chan struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Endpoint returns the jrpc endpoint url, as expected by jrpc.NewClient
+func (s *Server) Endpoint() *url.URL {
+	u, _ := url.Parse(s.URL)
+	u.Path = "/api/v1"
+	return u
+}
+
+// Close releases requests held by FaultTimeout and shuts the server down, subsequent connections are refused
+func (s *Server) Close() {
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	s.mu.Unlock()
+	s.Server.Close()
+}
+
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault)
+}
+
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// ExpireTokens invalidates all issued access tokens, next authenticated call will get 401.
+// Issued refresh tokens stay valid.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = map[string]bool{}
+}
+
+// Calls returns the methods received so far, in order
+func (s *Server) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+func (s *Server) takeFault(method string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, fault := range s.faults {
+		if fault.Method != "" && fault.Method != method {
+			continue
+		}
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[token]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/api/v1" {
+		http.NotFound(w, r)
+		return
+	}
+	var request jsonrpc2.WireRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.reply(w, nil, nil, jsonrpc2.NewErrorf(jsonrpc2.CodeParseError, "%v", err))
+		return
+	}
+	s.mu.Lock()
+	s.calls = append(s.calls, request.Method)
+	s.mu.Unlock()
+
+	if fault := s.takeFault(request.Method); fault != nil {
+		switch fault.Kind {
+		case FaultTimeout:
+			select {
+			case <-r.Context().Done():
+			case <-s.stop:
+			}
+			return
+		case FaultUnauthorized:
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		case FaultMethodNotFound:
+			s.reply(w, request.ID, nil, jsonrpc2.NewErrorf(jsonrpc2.CodeMethodNotFound, "Method not found"))
+			return
+		case FaultUnavailable:
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		case FaultError:
+			s.reply(w, request.ID, nil, jsonrpc2.NewErrorf(jsonrpc2.CodeUnknownError, "%s", fault.Message))
+			return
+		}
+	}
+
+	if !strings2.AnyOf(request.Method, userLogin, userRefreshToken) && !s.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var params json.RawMessage
+	if request.Params != nil {
+		params = *request.Params
+	}
+	result, err := s.handle(request.Method, params)
+	s.reply(w, request.ID, result, err)
+}
+
+func (s *Server) reply(w http.ResponseWriter, id *jsonrpc2.ID, result interface{}, rpcErr *jsonrpc2.Error) {
+	response := jsonrpc2.WireResponse{ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		raw := json.RawMessage(data)
+		response.Result = &raw
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (s *Server) handle(method string, params json.RawMessage) (interface{}, *jsonrpc2.Error) {
+	switch method {
+	case userLogin:
+		var creds []string
+		if err := json.Unmarshal(params, &creds); err != nil || len(creds) != 2 {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "expected [username, password]")
+		}
+		if creds[0] != s.Username || creds[1] != s.Password {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeUnknownError, "Invalid username or password")
+		}
+		return s.issueToken(), nil
+	case userRefreshToken:
+		var tokens []string
+		if err := json.Unmarshal(params, &tokens); err != nil || len(tokens) != 1 {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "expected [refresh_token]")
+		}
+		s.mu.Lock()
+		valid := s.refreshTokens[tokens[0]]
+		delete(s.refreshTokens, tokens[0])
+		s.mu.Unlock()
+		if !valid {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeUnknownError, "Invalid refresh token")
+		}
+		return s.issueToken(), nil
+	}
+
+	c := s.Cluster
+	c.Lock()
+	defer c.Unlock()
+
+	switch weka.JrpcMethod(method) {
+	case weka.JrpcStatus:
+		return c.status(), nil
+	case weka.JrpcHostList:
+		return c.hostsList(), nil
+	case weka.JrpcFilesystemsList:
+		return c.filesystemsList(), nil
+	case weka.JrpcEmitCustomEvent:
+		var p struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%v", err)
+		}
+		c.Events = append(c.Events, p.Message)
+		return nil, nil
+	case weka.JrpcDrivesList:
+		return c.drivesList(), nil
+	case weka.JrpcNodeList:
+		return c.nodesList(), nil
+	case weka.JrpcDeactivateHosts:
+		var p struct {
+			HostIds []json.RawMessage `json:"host_ids"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%v", err)
+		}
+		hostIds, err := parseIds(hostIdPrefix, p.HostIds)
+		if err != nil {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%v", err)
+		}
+		if err := c.deactivateHosts(hostIds); err != nil {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeUnknownError, "%v", err)
+		}
+		return nil, nil
+	case weka.JrpcDeactivateDrives, weka.JrpcRemoveDrive:
+		var p struct {
+			DriveUuids []uuid.UUID `json:"drive_uuids"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%v", err)
+		}
+		var err error
+		if weka.JrpcMethod(method) == weka.JrpcDeactivateDrives {
+			err = c.deactivateDrives(p.DriveUuids)
+		} else {
+			err = c.removeDrives(p.DriveUuids)
+		}
+		if err != nil {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeUnknownError, "%v", err)
+		}
+		return nil, nil
+	case weka.JrpcRemoveHost:
+		var p struct {
+			HostId json.RawMessage `json:"host_id"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%v", err)
+		}
+		hostIds, err := parseIds(hostIdPrefix, []json.RawMessage{p.HostId})
+		if err != nil {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeInvalidParams, "%v", err)
+		}
+		if err := c.removeHost(hostIds[0]); err != nil {
+			return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeUnknownError, "%v", err)
+		}
+		return nil, nil
+	}
+	return nil, jsonrpc2.NewErrorf(jsonrpc2.CodeMethodNotFound, "Method not found")
+}
+
+func (s *Server) issueToken() map[string]interface{} {
+	accessToken := strings2.RandSeq(32)
+	refreshToken := strings2.RandSeq(32)
+	s.mu.Lock()
+	s.tokens[accessToken] = true
+	s.refreshTokens[refreshToken] = true
+	s.mu.Unlock()
+	return map[string]interface{}{
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    s.TokenExpiresIn,
+		"token_type":    "Bearer",
+	}
+}
+
+func parseIds(prefix string, values []json.RawMessage) (ids []int, err error) {
+	for _, value := range values {
+		var id int
+		if err = json.Unmarshal(value, &id); err != nil {
+			var s string
+			if err = json.Unmarshal(value, &s); err != nil {
+				return
+			}
+			if id, err = parseId(prefix, s); err != nil {
+				err = fmt.Errorf("invalid id %q: %w", s, err)
+				return
+			}
+		}
+		ids = append(ids, id)
+	}
+	return
+}
@@ -0,0 +1,184 @@
+package fake
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"wekactl/internal/lib/jrpc"
+	"wekactl/internal/lib/weka"
+)
+
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Printf(format string, v ...interface{}) {
+	l.t.Logf(format, v...)
+}
+
+func newPool(t *testing.T, ctx context.Context, servers ...*Server) *jrpc.Pool {
+	byIp := map[string]*Server{}
+	var ips []string
+	for i, server := range servers {
+		ip := string(rune('a' + i))
+		byIp[ip] = server
+		ips = append(ips, ip)
+	}
+	return &jrpc.Pool{
+		Ips:     ips,
+		Clients: map[string]*jrpc.BaseClient{},
+		Ctx:     ctx,
+		Builder: func(ip string) *jrpc.BaseClient {
+			opt := jrpc.ClientOptions{}
+			opt.AuthenticatedClient("admin", "secret", "")
+			opt.RequestTimeout(time.Second)
+			return jrpc.NewClient(ctx, testLogger{t}, byIp[ip].Endpoint(), &http.Transport{}, &opt)
+		},
+	}
+}
+
+func TestRemoveBackendFlow(t *testing.T) {
+	cluster := NewCluster()
+	cluster.AddBackend("10.0.0.1", "i-1", 2)
+	hostId := cluster.AddBackend("10.0.0.2", "i-2", 2)
+	server := NewServer(cluster, "admin", "secret")
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newPool(t, ctx, server)
+
+	hosts := weka.HostListResponse{}
+	if err := pool.Call(weka.JrpcHostList, struct{}{}, &hosts); err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 {
+		t.Fatalf("expected 2 hosts, got %d", len(hosts))
+	}
+
+	drives := weka.DriveListResponse{}
+	if err := pool.Call(weka.JrpcDrivesList, struct{}{}, &drives); err != nil {
+		t.Fatal(err)
+	}
+	var toRemove []string
+	for _, drive := range drives {
+		if drive.HostId.Int() == hostId {
+			toRemove = append(toRemove, drive.Uuid.String())
+		}
+	}
+
+	params := map[string]interface{}{"drive_uuids": toRemove}
+	if err := pool.Call(weka.JrpcDeactivateDrives, params, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Call(weka.JrpcDeactivateHosts, map[string]interface{}{"host_ids": []string{formatId(hostIdPrefix, hostId)}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	err := pool.Call(weka.JrpcRemoveHost, map[string]interface{}{"host_id": hostId, "no_wait": true}, nil)
+	if err == nil || !strings.Contains(err.Error(), "can't remove active host") {
+		t.Fatalf("expected removal of deactivating host to fail, got %v", err)
+	}
+
+	cluster.CompleteDeactivation()
+	if err := pool.Call(weka.JrpcRemoveDrive, params, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Call(weka.JrpcRemoveHost, map[string]interface{}{"host_id": hostId, "no_wait": true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(cluster.Hosts) != 1 || len(cluster.Drives) != 2 || len(cluster.Nodes) != 2 {
+		t.Fatalf("unexpected cluster state after removal: %d hosts, %d drives, %d nodes", len(cluster.Hosts), len(cluster.Drives), len(cluster.Nodes))
+	}
+}
+
+func TestPoolFailover(t *testing.T) {
+	cluster := NewCluster()
+	cluster.AddBackend("10.0.0.1", "i-1", 1)
+
+	for _, kind := range []FaultKind{FaultMethodNotFound, FaultTimeout, -1} {
+		faulty := NewServer(cluster, "admin", "secret")
+		if kind < 0 {
+			// connection refused
+			faulty.Close()
+		} else {
+			faulty.InjectFault(Fault{Method: string(weka.JrpcStatus), Kind: kind})
+		}
+		healthy := NewServer(cluster, "admin", "secret")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		pool := newPool(t, ctx, faulty, healthy)
+		status := weka.StatusResponse{}
+		if err := pool.Call(weka.JrpcStatus, struct{}{}, &status); err != nil {
+			t.Errorf("fault %d: %v", kind, err)
+		} else if status.IoStatus != "STARTED" {
+			t.Errorf("fault %d: unexpected io status %s", kind, status.IoStatus)
+		}
+		if len(pool.Ips) != 1 || pool.Active != pool.Ips[0] {
+			t.Errorf("fault %d: expected faulty backend to be dropped, pool: %v", kind, pool.Ips)
+		}
+		cancel()
+		faulty.Close()
+		healthy.Close()
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	server := NewServer(NewCluster(), "admin", "secret")
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opt := jrpc.ClientOptions{}
+	opt.AuthenticatedClient("admin", "wrong", "")
+	opt.RequestTimeout(time.Second)
+	client := jrpc.NewClient(ctx, testLogger{t}, server.Endpoint(), &http.Transport{}, &opt)
+	if err := client.Call(ctx, string(weka.JrpcStatus), struct{}{}, nil); err == nil {
+		t.Fatal("expected login with wrong password to fail")
+	}
+
+	server.InjectFault(Fault{Kind: FaultUnauthorized, Count: 1})
+	opt.AuthenticatedClient("admin", "secret", "")
+	client = jrpc.NewClient(ctx, testLogger{t}, server.Endpoint(), &http.Transport{}, &opt)
+	if err := client.Call(ctx, string(weka.JrpcStatus), struct{}{}, nil); err == nil {
+		t.Fatal("expected injected 401 to fail the call")
+	}
+	if err := client.Call(ctx, string(weka.JrpcStatus), struct{}{}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	server := NewServer(NewCluster(), "admin", "secret")
+	defer server.Close()
+	server.TokenExpiresIn = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opt := jrpc.ClientOptions{}
+	opt.AuthenticatedClient("admin", "secret", "")
+	opt.RequestTimeout(time.Second)
+	client := jrpc.NewClient(ctx, testLogger{t}, server.Endpoint(), &http.Transport{}, &opt)
+	for i := 0; i < 3; i++ {
+		if err := client.Call(ctx, string(weka.JrpcStatus), struct{}{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refreshes := 0
+	for _, method := range server.Calls() {
+		if method == userRefreshToken {
+			refreshes++
+		}
+	}
+	if refreshes != 2 {
+		t.Fatalf("expected 2 token refreshes, got calls %v", server.Calls())
+	}
+
+	opt.AuthenticatedClient("", "", "not-issued")
+	client = jrpc.NewClient(ctx, testLogger{t}, server.Endpoint(), &http.Transport{}, &opt)
+	err := client.Call(ctx, string(weka.JrpcStatus), struct{}{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "Invalid refresh token") {
+		t.Fatalf("expected refresh with a token that was not issued to fail, got %v", err)
+	}
+}
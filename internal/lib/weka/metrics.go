@@ -0,0 +1,34 @@
+package weka
+
+// ClusterMetrics are the storage signals published for capacity based scaling
+type ClusterMetrics struct {
+	CapacityTotalBytes       int64
+	CapacityUsedBytes        int64
+	CapacityFreeBytes        int64
+	CapacityUsedPercent      float64
+	ThroughputBytesPerSecond float64
+	Iops                     float64
+	ActiveBackends           int
+	InactiveBackends         int
+	FullyProtected           bool
+}
+
+func GetClusterMetrics(status StatusResponse, filesystems FilesystemListResponse) (metrics ClusterMetrics) {
+	metrics.CapacityTotalBytes = status.Capacity.TotalBytes
+	for _, filesystem := range filesystems {
+		metrics.CapacityUsedBytes += filesystem.UsedSSD
+	}
+	metrics.CapacityFreeBytes = metrics.CapacityTotalBytes - metrics.CapacityUsedBytes
+	if metrics.CapacityFreeBytes < 0 {
+		metrics.CapacityFreeBytes = 0
+	}
+	if metrics.CapacityTotalBytes > 0 {
+		metrics.CapacityUsedPercent = float64(metrics.CapacityUsedBytes) * 100 / float64(metrics.CapacityTotalBytes)
+	}
+	metrics.ThroughputBytesPerSecond = status.Activity.SumBytesRead + status.Activity.SumBytesWritten
+	metrics.Iops = status.Activity.NumOps
+	metrics.ActiveBackends = status.Hosts.Backends.Active
+	metrics.InactiveBackends = status.Hosts.Backends.Total - status.Hosts.Backends.Active
+	metrics.FullyProtected = status.FullyProtected()
+	return
+}